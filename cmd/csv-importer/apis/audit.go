@@ -0,0 +1,72 @@
+package apis
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type IAuditRepo interface {
+	ListAudit(ctx context.Context, filter model.AuditFilter) ([]model.AuditLog, error)
+}
+
+type AuditAPI struct {
+	auditRepo IAuditRepo
+	policy    auth.Policy
+}
+
+func NewAuditAPI(auditRepo IAuditRepo) *AuditAPI {
+
+	return &AuditAPI{
+		auditRepo: auditRepo,
+		policy:    auth.DefaultPolicy,
+	}
+}
+
+func (a *AuditAPI) Setup(g *echo.Group) {
+	g.GET("/audit", a.listAudit, auth.Authorize(a.policy, auth.ActionReadAudit))
+}
+
+func (a *AuditAPI) listAudit(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	var filter model.AuditFilter
+	var action string
+
+	err := echo.QueryParamsBinder(c).
+		String("actor", &filter.Actor).
+		String("action", &action).
+		String("target_id", &filter.TargetID).
+		String("request_id", &filter.RequestID).
+		Time("from", &filter.From, time.RFC3339).
+		Time("to", &filter.To, time.RFC3339).
+		Int("limit", &filter.Limit).
+		Int("offset", &filter.Offset).
+		BindError()
+
+	if err != nil {
+		return apperr.New(apperr.CodeBadRequest, "invalid query parameters").
+			WithDetails(map[string]string{"reason": err.Error()})
+	}
+
+	filter.Action = model.AuditAction(action)
+
+	logs, err := a.auditRepo.ListAudit(ctx, filter)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    logs,
+		},
+	)
+}
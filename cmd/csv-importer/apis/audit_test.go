@@ -0,0 +1,104 @@
+package apis
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditRepo struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepo) ListAudit(ctx context.Context, filter model.AuditFilter) ([]model.AuditLog, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]model.AuditLog), args.Error(1)
+}
+
+func TestAuditAPI_ListAudit_Filters(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(
+		http.MethodGet,
+		"/api/v1/audit?actor=alice&action=event.delete&target_id=event-1&request_id=req-1&from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00Z&limit=50&offset=10",
+		nil,
+	)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockRepo := new(MockAuditRepo)
+	api := NewAuditAPI(mockRepo)
+
+	expectedFilter := model.AuditFilter{
+		Actor:     "alice",
+		Action:    model.AuditEventDelete,
+		TargetID:  "event-1",
+		RequestID: "req-1",
+		From:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		Limit:     50,
+		Offset:    10,
+	}
+
+	mockRepo.On("ListAudit", mock.Anything, expectedFilter).Return([]model.AuditLog{
+		{ID: "audit-1", Actor: "alice", Action: model.AuditEventDelete, TargetType: "event", TargetID: "event-1"},
+	}, nil)
+
+	err := api.listAudit(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Data []model.AuditLog `json:"data"`
+	}
+	err = json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, "audit-1", response.Data[0].ID)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuditAPI_ListAudit_InvalidTime(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/audit?from=yesterday", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockRepo := new(MockAuditRepo)
+	api := NewAuditAPI(mockRepo)
+
+	err := handle(c, api.listAudit)
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "ListAudit", mock.Anything, mock.Anything)
+}
+
+func TestAuditAPI_ListAudit_RepositoryError(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockRepo := new(MockAuditRepo)
+	api := NewAuditAPI(mockRepo)
+
+	mockRepo.On("ListAudit", mock.Anything, model.AuditFilter{}).Return([]model.AuditLog{}, errors.New("database connection failed"))
+
+	err := handle(c, api.listAudit)
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	mockRepo.AssertExpectations(t)
+}
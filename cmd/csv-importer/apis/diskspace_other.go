@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package apis
+
+import "errors"
+
+func diskSpace(path string) (free uint64, total uint64, err error) {
+	return 0, 0, errors.New("disk space is not reported on this platform")
+}
@@ -0,0 +1,85 @@
+// Renders the OpenAPI contract of the server without any third-party code.
+// Every value from the spec is set as text, never parsed as HTML.
+(function () {
+  var root = document.getElementById("docs");
+
+  function el(tag, text, className) {
+    var node = document.createElement(tag);
+    if (text) node.textContent = text;
+    if (className) node.className = className;
+    return node;
+  }
+
+  function resolve(spec, obj) {
+    if (!obj || !obj.$ref) return obj || {};
+    return obj.$ref.replace(/^#\//, "").split("/").reduce(function (node, key) {
+      return node && node[key];
+    }, spec) || {};
+  }
+
+  function table(headings, rows) {
+    var t = el("table");
+    var head = el("tr");
+    headings.forEach(function (h) { head.appendChild(el("th", h)); });
+    t.appendChild(head);
+    rows.forEach(function (row) {
+      var tr = el("tr");
+      row.forEach(function (cell) { tr.appendChild(el("td", cell)); });
+      t.appendChild(tr);
+    });
+    return t;
+  }
+
+  function operation(spec, path, method, item, op) {
+    var section = el("section");
+    var h = el("h3");
+    h.appendChild(el("span", method, "method"));
+    h.appendChild(el("code", path));
+    section.appendChild(h);
+    if (op.summary) section.appendChild(el("strong", op.summary));
+    if (op.description) section.appendChild(el("p", op.description));
+
+    var params = (item.parameters || []).concat(op.parameters || []).map(function (p) {
+      p = resolve(spec, p);
+      return [p.name, p.in, p.required ? "yes" : "no", p.description || ""];
+    });
+    if (params.length) section.appendChild(table(["Parameter", "In", "Required", "Description"], params));
+
+    var body = resolve(spec, op.requestBody);
+    if (body.content) {
+      section.appendChild(el("p", "Request body: " + Object.keys(body.content).join(", ")));
+    }
+
+    var responses = Object.keys(op.responses || {}).map(function (code) {
+      var r = resolve(spec, op.responses[code]);
+      return [code, r.description || "", Object.keys(r.content || {}).join(", ")];
+    });
+    section.appendChild(table(["Status", "Description", "Content"], responses));
+    return section;
+  }
+
+  function render(spec) {
+    root.textContent = "";
+    var info = spec.info || {};
+    root.appendChild(el("h1", (info.title || "API") + " " + (info.version || "")));
+    if (info.description) root.appendChild(el("p", info.description));
+
+    var methods = ["get", "put", "post", "patch", "delete", "head", "options"];
+    Object.keys(spec.paths || {}).sort().forEach(function (path) {
+      var item = spec.paths[path];
+      methods.forEach(function (method) {
+        if (item[method]) root.appendChild(operation(spec, path, method, item, item[method]));
+      });
+    });
+  }
+
+  fetch(root.getAttribute("data-spec-url"))
+    .then(function (res) {
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      return res.json();
+    })
+    .then(render)
+    .catch(function (err) {
+      root.textContent = "The API contract could not be loaded: " + err.message;
+    });
+})();
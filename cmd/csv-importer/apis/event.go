@@ -1,36 +1,102 @@
 package apis
 
 import (
+	"bytes"
 	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/importer"
 	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"csv-importer-backend/cmd/csv-importer/transform"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"time"
+	"strconv"
+	"strings"
 
-	"github.com/gocarina/gocsv"
-	"github.com/goforj/godump"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
 )
 
 type IEventRepo interface {
 	ListEvents(ctx context.Context) ([]model.Event, error)
-	CreateEvent(ctx context.Context, event model.Event) error
+	GetEvent(ctx context.Context, id string) (*model.Event, error)
+	CreateEvent(ctx context.Context, event model.Event, todos ...model.TodoEvent) error
+	ListTodos(ctx context.Context, eventID string, filter model.TodoFilter) ([]model.TodoEvent, error)
+	UpdateEventStatus(ctx context.Context, id string, status model.EventStatus) error
+	DeleteEvent(ctx context.Context, id string) error
 }
 
 type EventAPI struct {
 	eventRepo IEventRepo
+	importer  *importer.Importer
+	schemas   *schema.Registry
+	records   importer.IRecordRepo
+	sources   blobstore.Store
+	policy    auth.Policy
 }
 
 func NewEventAPI(eventRepo IEventRepo) *EventAPI {
 
 	return &EventAPI{
 		eventRepo: eventRepo,
+		importer:  importer.New(eventRepo),
+		policy:    auth.DefaultPolicy,
 	}
 }
 
+// WithImportObserver reports every import handled by the API to o.
+func (a *EventAPI) WithImportObserver(o importer.Observer) *EventAPI {
+	a.importer.SetObserver(o)
+	return a
+}
+
+// WithSchemas accepts uploads of the declarative import types of registry,
+// whose rows are stored through records.
+func (a *EventAPI) WithSchemas(registry *schema.Registry, records importer.IRecordRepo) *EventAPI {
+	a.schemas = registry
+	a.records = records
+	a.importer.SetSchemas(registry, records)
+	return a
+}
+
+// WithSources keeps the uploaded file of every import in store.
+func (a *EventAPI) WithSources(store blobstore.Store) *EventAPI {
+	a.sources = store
+	return a
+}
+
 func (a *EventAPI) Setup(g *echo.Group) {
-	g.GET("/events", a.listEvents)
-	g.POST("/event", a.createEvent)
+	can := func(action auth.Action) echo.MiddlewareFunc {
+		return auth.Authorize(a.policy, action)
+	}
+
+	g.GET("/events", a.listEvents, can(auth.ActionReadEvents))
+	g.GET("/events/:id", a.getEvent, can(auth.ActionReadEvents))
+	g.GET("/events/:id/todos", a.listTodos, can(auth.ActionReadEvents))
+	g.GET("/events/:id/records", a.listRecords, can(auth.ActionReadEvents))
+	g.GET("/events/:id/export", a.exportEvent, can(auth.ActionExportEvent))
+	g.PATCH("/events/:id/status", a.updateEventStatus, can(auth.ActionChangeStatus))
+	g.DELETE("/events/:id", a.deleteEvent, can(auth.ActionDeleteEvent))
+	g.POST("/event", a.createEvent, can(auth.ActionImportCSV))
+}
+
+// IsImportRequest reports whether c uploads a CSV file, to import or to
+// inspect it, or starts a resumable upload. Uploads have their own rate and
+// concurrency budgets.
+func IsImportRequest(c echo.Context) bool {
+	if c.Request().Method != http.MethodPost {
+		return false
+	}
+
+	path := c.Path()
+	return strings.HasSuffix(path, "/event") || strings.HasSuffix(path, "/csv/inspect") || strings.HasSuffix(path, "/uploads")
 }
 
 func (a *EventAPI) listEvents(c echo.Context) error {
@@ -39,12 +105,7 @@ func (a *EventAPI) listEvents(c echo.Context) error {
 
 	events, err := a.eventRepo.ListEvents(ctx)
 	if err != nil {
-		return c.JSON(
-			http.StatusInternalServerError,
-			model.BaseResponse{
-				Message: err.Error(),
-			},
-		)
+		return apperr.Internal(err)
 	}
 
 	return c.JSON(
@@ -56,81 +117,198 @@ func (a *EventAPI) listEvents(c echo.Context) error {
 	)
 }
 
-func (a *EventAPI) createEvent(c echo.Context) error {
+func (a *EventAPI) getEvent(c echo.Context) error {
 
 	ctx := c.Request().Context()
 
-	eventName := c.FormValue("name")
-	csvfile, err := c.FormFile("csvfile")
+	event, err := a.eventRepo.GetEvent(ctx, c.Param("id"))
+	if err != nil {
+		return eventLookupError(err)
+	}
 
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    event,
+		},
+	)
+}
+
+func (a *EventAPI) listTodos(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	filter, err := todoFilter(c)
 	if err != nil {
-		return c.JSON(
-			http.StatusBadRequest,
-			model.BaseResponse{
-				Message: err.Error(),
-			},
-		)
+		return err
 	}
 
-	cf, err := csvfile.Open()
+	event, err := a.eventRepo.GetEvent(ctx, c.Param("id"))
 	if err != nil {
-		return c.JSON(
-			http.StatusBadRequest,
-			model.BaseResponse{
-				Message: err.Error(),
-			},
-		)
+		return eventLookupError(err)
 	}
 
-	defer cf.Close()
+	todos, err := a.eventRepo.ListTodos(ctx, event.ID, filter)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    todos,
+		},
+	)
+}
+
+// todoFilter reads the todo listing's query parameters, which use the same
+// values as the import columns.
+func todoFilter(c echo.Context) (model.TodoFilter, error) {
+
+	var filter model.TodoFilter
+	var status, priority, dueBefore, dueAfter string
+
+	err := echo.QueryParamsBinder(c).
+		String("status", &status).
+		String("priority", &priority).
+		String("assignee_email", &filter.AssigneeEmail).
+		String("tag", &filter.Tag).
+		String("due_before", &dueBefore).
+		String("due_after", &dueAfter).
+		BindError()
 
-	var todos []model.TodoCSV
-	err = gocsv.Unmarshal(cf, &todos)
 	if err != nil {
-		return c.JSON(
-			http.StatusInternalServerError,
-			model.BaseResponse{
-				Message: err.Error(),
-			},
-		)
+		return filter, apperr.New(apperr.CodeBadRequest, "invalid query parameters").
+			WithDetails(map[string]string{"reason": err.Error()})
+	}
 
+	invalid := func(field string, err error) error {
+		return apperr.Validation(field + " " + err.Error()).
+			WithDetails(map[string]string{"field": field})
 	}
 
-	godump.Dump(todos)
+	if status != "" {
+		filter.Status, err = model.ParseTodoStatus(status)
+		if err != nil {
+			return filter, invalid("status", err)
+		}
+	}
 
-	id, err := uuid.NewV7()
+	filter.Priority, err = model.ParseTodoPriority(priority)
 	if err != nil {
-		return c.JSON(
-			http.StatusInternalServerError,
-			model.BaseResponse{
-				Message: err.Error(),
-			},
-		)
+		return filter, invalid("priority", err)
+	}
+
+	filter.DueBefore, err = model.ParseDueDate(dueBefore)
+	if err != nil {
+		return filter, invalid("due_before", err)
+	}
+
+	filter.DueAfter, err = model.ParseDueDate(dueAfter)
+	if err != nil {
+		return filter, invalid("due_after", err)
+	}
+
+	for _, field := range c.QueryParams()["custom_field"] {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || key == "" {
+			return filter, invalid("custom_field", errors.New("must be <name>:<value>"))
+		}
+
+		if filter.CustomFields == nil {
+			filter.CustomFields = map[string]string{}
+		}
+		filter.CustomFields[key] = value
+	}
+
+	return filter, nil
+}
+
+// listRecords returns the rows of an event of a declarative import type.
+func (a *EventAPI) listRecords(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	event, err := a.eventRepo.GetEvent(ctx, c.Param("id"))
+	if err != nil {
+		return eventLookupError(err)
+	}
+
+	if event.ImportType == "" || event.ImportType == model.ImportTypeTodos {
+		return apperr.New(apperr.CodeBadRequest, "event holds todos, list them at /todos")
 	}
 
-	req := model.EventCreateRequest{
-		Name: eventName,
+	s, ok := a.schemas.Get(event.ImportType)
+	if !ok || a.records == nil {
+		return apperr.Internal(fmt.Errorf("event %s has unknown import type %s", event.ID, event.ImportType))
+	}
+
+	records, err := a.records.ListRecords(ctx, event.ID, s.Table)
+	if err != nil {
+		return apperr.Internal(err)
 	}
 
-	event := model.Event{
-		ID:         id.String(),
-		Name:       req.Name,
-		Status:     model.Created,
-		CreateDate: time.Now(),
-		UpdateDate: time.Now(),
+	for _, record := range records {
+		s.Normalize(record)
 	}
-	err = a.eventRepo.CreateEvent(
-		ctx,
-		event,
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    records,
+		},
 	)
+}
+
+func (a *EventAPI) exportEvent(c echo.Context) error {
+
+	ctx := c.Request().Context()
 
+	var buf bytes.Buffer
+	event, err := a.importer.Export(ctx, c.Param("id"), &buf)
 	if err != nil {
-		return c.JSON(
-			http.StatusInternalServerError,
-			model.BaseResponse{
-				Message: err.Error(),
-			},
-		)
+		return eventLookupError(err)
+	}
+
+	c.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=%q", event.ID+".csv"),
+	)
+
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func (a *EventAPI) updateEventStatus(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	var req model.EventStatusUpdateRequest
+	err := c.Bind(&req)
+	if err != nil {
+		return apperr.New(apperr.CodeBadRequest, "invalid request body")
+	}
+
+	if !req.Status.Valid() {
+		return apperr.Validation(fmt.Sprintf("invalid status %q", req.Status)).
+			WithDetails(map[string]any{
+				"field":   "status",
+				"allowed": []model.EventStatus{model.Created, model.Start, model.End},
+			})
+	}
+
+	id := c.Param("id")
+
+	err = a.eventRepo.UpdateEventStatus(ctx, id, req.Status)
+	if err != nil {
+		return eventLookupError(err)
+	}
+
+	event, err := a.eventRepo.GetEvent(ctx, id)
+	if err != nil {
+		return eventLookupError(err)
 	}
 
 	return c.JSON(
@@ -141,3 +319,213 @@ func (a *EventAPI) createEvent(c echo.Context) error {
 		},
 	)
 }
+
+func (a *EventAPI) deleteEvent(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	err := a.eventRepo.DeleteEvent(ctx, c.Param("id"))
+	if err != nil {
+		return eventLookupError(err)
+	}
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+		},
+	)
+}
+
+// eventLookupError reports events of other tenants exactly like missing ones
+// so that callers cannot probe for foreign IDs.
+func eventLookupError(err error) error {
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.NotFound("event not found")
+	}
+
+	return apperr.Internal(err)
+}
+
+func (a *EventAPI) createEvent(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	// Reading the form consumes the whole multipart body
+	_, span := tracing.Start(ctx, "upload.read")
+	eventName := c.FormValue("name")
+	csvfile, err := c.FormFile("csvfile")
+	if csvfile != nil {
+		span.SetAttributes(attribute.Int64("upload.size", csvfile.Size))
+	}
+	tracing.End(span, err)
+
+	if err != nil {
+		return apperr.Validation("csvfile is required").
+			WithDetails(map[string]string{"field": "csvfile"})
+	}
+
+	opts, err := importOptions(c.FormValue)
+	if err != nil {
+		return err
+	}
+
+	result, err := a.runImport(ctx, eventName, upload{
+		open:        func() (io.ReadCloser, error) { return csvfile.Open() },
+		size:        csvfile.Size,
+		filename:    csvfile.Filename,
+		contentType: csvfile.Header.Get(echo.HeaderContentType),
+	}, opts)
+	if err != nil {
+		return err
+	}
+
+	if !opts.DryRun {
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.String("event.id", result.Event.ID),
+			attribute.Int("import.rows_imported", result.RowsImported),
+			attribute.Int("import.rows_rejected", result.RowsRejected),
+			attribute.Int("import.rows_duplicated", result.RowsDuplicated),
+		)
+	}
+
+	return c.JSON(http.StatusOK, importResponse(result, opts))
+}
+
+// importOptions reads the options of an import from the values of its
+// request, the form fields of createEvent or the metadata of an upload.
+func importOptions(value func(name string) string) (importer.Options, error) {
+
+	opts := importer.Options{
+		Type: value("type"),
+		Mode: value("mode"),
+	}
+
+	var err error
+	if keep := value("keep_custom_fields"); keep != "" {
+		opts.KeepCustomFields, err = strconv.ParseBool(keep)
+		if err != nil {
+			return opts, apperr.Validation("keep_custom_fields must be true or false").
+				WithDetails(map[string]string{"field": "keep_custom_fields"})
+		}
+	}
+
+	if dryRun := value("dry_run"); dryRun != "" {
+		opts.DryRun, err = strconv.ParseBool(dryRun)
+		if err != nil {
+			return opts, apperr.Validation("dry_run must be true or false").
+				WithDetails(map[string]string{"field": "dry_run"})
+		}
+	}
+
+	if rules := value("transforms"); rules != "" {
+		opts.Transforms, err = transform.Parse([]byte(rules))
+		if err != nil {
+			return opts, apperr.Validation("transforms are invalid").
+				WithDetails(map[string]string{"field": "transforms", "reason": err.Error()})
+		}
+	}
+
+	opts.Duplicates = importer.DuplicateOptions{
+		Columns: splitList(value("duplicate_columns")),
+		Match:   value("duplicate_match"),
+		Policy:  value("duplicate_policy"),
+	}
+	if across := value("duplicates_across_events"); across != "" {
+		opts.Duplicates.AcrossEvents, err = strconv.ParseBool(across)
+		if err != nil {
+			return opts, apperr.Validation("duplicates_across_events must be true or false").
+				WithDetails(map[string]string{"field": "duplicates_across_events"})
+		}
+	}
+
+	return opts, nil
+}
+
+// runImport imports an upload. Unless it is a dry run, the file is kept
+// first, and dropped again when the import fails.
+func (a *EventAPI) runImport(ctx context.Context, name string, u upload, opts importer.Options) (*importer.Result, error) {
+
+	var err error
+	if !opts.DryRun {
+		opts.Source, err = keepSource(ctx, a.sources, u)
+		if err != nil {
+			return nil, apperr.Internal(err)
+		}
+	}
+
+	r, err := u.open()
+	if err != nil {
+		dropSource(ctx, a.sources, opts.Source)
+		return nil, apperr.Internal(err)
+	}
+
+	defer r.Close()
+
+	result, err := a.importer.Import(ctx, name, r, opts)
+	if err != nil {
+		dropSource(ctx, a.sources, opts.Source)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// importResponse is the response to an import: the preview of a dry run,
+// or the created event with a summary of the rows.
+func importResponse(result *importer.Result, opts importer.Options) model.BaseResponse {
+
+	if opts.DryRun {
+		return model.BaseResponse{
+			Message: "success",
+			Data:    result.Preview,
+		}
+	}
+
+	return model.BaseResponse{
+		Message: "success",
+		Data:    result.Event,
+		Details: importSummary{
+			RowsImported:   result.RowsImported,
+			RowsRejected:   result.RowsRejected,
+			RowsDuplicated: result.RowsDuplicated,
+			RowsSkipped:    result.RowsSkipped,
+			Duplicates:     result.Duplicates,
+			ErrorReport:    errorReport(result),
+		},
+	}
+}
+
+// importSummary is reported with the created event.
+type importSummary struct {
+	RowsImported   int                  `json:"rows_imported"`
+	RowsRejected   int                  `json:"rows_rejected"`
+	RowsDuplicated int                  `json:"rows_duplicated"`
+	RowsSkipped    int                  `json:"rows_skipped"`
+	Duplicates     []importer.Duplicate `json:"duplicates,omitempty"`
+	// ErrorReport links the error report of imports that left rows out.
+	ErrorReport string `json:"error_report,omitempty"`
+}
+
+func errorReport(result *importer.Result) string {
+
+	if result.RowsRejected == 0 {
+		return ""
+	}
+
+	return reportPath(result.Event.ID)
+}
+
+// splitList splits a comma-separated form value, dropping empty items.
+func splitList(v string) []string {
+
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -3,7 +3,10 @@ package apis
 import (
 	"bytes"
 	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
 	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
 	"encoding/json"
 	"errors"
 	"mime/multipart"
@@ -11,12 +14,15 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 // MockEventRepo implements IEventRepo interface for testing
@@ -29,11 +35,49 @@ func (m *MockEventRepo) ListEvents(ctx context.Context) ([]model.Event, error) {
 	return args.Get(0).([]model.Event), args.Error(1)
 }
 
-func (m *MockEventRepo) CreateEvent(ctx context.Context, event model.Event) error {
-	args := m.Called(ctx, event)
+func (m *MockEventRepo) GetEvent(ctx context.Context, id string) (*model.Event, error) {
+	args := m.Called(ctx, id)
+	event, _ := args.Get(0).(*model.Event)
+	return event, args.Error(1)
+}
+
+func (m *MockEventRepo) CreateEvent(ctx context.Context, event model.Event, todos ...model.TodoEvent) error {
+	args := m.Called(ctx, event, todos)
+	return args.Error(0)
+}
+
+func (m *MockEventRepo) ListTodos(ctx context.Context, eventID string, filter model.TodoFilter) ([]model.TodoEvent, error) {
+	args := m.Called(ctx, eventID, filter)
+	return args.Get(0).([]model.TodoEvent), args.Error(1)
+}
+
+func (m *MockEventRepo) UpdateEventStatus(ctx context.Context, id string, status model.EventStatus) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+func (m *MockEventRepo) DeleteEvent(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockEventRepo) CreateEventRecords(ctx context.Context, event model.Event, table string, records []map[string]any) error {
+	args := m.Called(ctx, event, table, records)
+	return args.Error(0)
+}
+
+func (m *MockEventRepo) ListRecords(ctx context.Context, eventID string, table string) ([]map[string]any, error) {
+	args := m.Called(ctx, eventID, table)
+	return args.Get(0).([]map[string]any), args.Error(1)
+}
+
+func newSchemaAPI(t *testing.T, mockRepo *MockEventRepo) *EventAPI {
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+
+	return NewEventAPI(mockRepo).WithSchemas(schemas, mockRepo)
+}
+
 func TestEventAPI_ListEvents_Success(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil)
@@ -97,15 +141,17 @@ func TestEventAPI_ListEvents_RepositoryError(t *testing.T) {
 
 	mockRepo.On("ListEvents", mock.Anything).Return([]model.Event{}, errors.New("database connection failed"))
 
-	err := api.listEvents(c)
+	err := handle(c, api.listEvents)
 
-	assert.NoError(t, err) // Echo doesn't return error for JSON responses
+	assert.Error(t, err)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 
 	var response model.BaseResponse
 	err = json.Unmarshal(rec.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response.Message, "database connection failed")
+	assert.Equal(t, string(apperr.CodeInternal), response.Code)
+	// Storage errors must not leak to clients
+	assert.NotContains(t, response.Message, "database connection failed")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -140,7 +186,7 @@ func TestEventAPI_CreateEvent_ValidCSV(t *testing.T) {
 	mockRepo := new(MockEventRepo)
 	api := NewEventAPI(mockRepo)
 
-	mockRepo.On("CreateEvent", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	err = api.createEvent(c)
 
@@ -177,15 +223,16 @@ func TestEventAPI_CreateEvent_MissingFile(t *testing.T) {
 	mockRepo := new(MockEventRepo)
 	api := NewEventAPI(mockRepo)
 
-	err = api.createEvent(c)
+	err = handle(c, api.createEvent)
 
-	assert.NoError(t, err) // Echo doesn't return error for JSON responses
+	assert.Error(t, err)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	var response model.BaseResponse
 	err = json.Unmarshal(rec.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response.Message, "no such file")
+	assert.Equal(t, string(apperr.CodeValidation), response.Code)
+	assert.Contains(t, response.Message, "csvfile")
 
 	// Don't assert expectations as repo shouldn't be called
 }
@@ -220,7 +267,7 @@ func TestEventAPI_CreateEvent_InvalidCSV(t *testing.T) {
 
 	// Even with invalid CSV structure, the API currently processes it
 	// This test shows current behavior - you might want to add validation
-	mockRepo.On("CreateEvent", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	err = api.createEvent(c)
 
@@ -259,16 +306,18 @@ func TestEventAPI_CreateEvent_MalformedCSV(t *testing.T) {
 	mockRepo := new(MockEventRepo)
 	api := NewEventAPI(mockRepo)
 
-	err = api.createEvent(c)
+	err = handle(c, api.createEvent)
 
-	assert.NoError(t, err) // Echo doesn't return error for JSON responses
-	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	var response model.BaseResponse
 	err = json.Unmarshal(rec.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	// Should contain CSV parsing error
 	assert.NotEqual(t, "success", response.Message)
+	assert.Equal(t, string(apperr.CodeValidation), response.Code)
+	assert.NotNil(t, response.Details)
 
 	// Don't assert expectations as repo shouldn't be called due to CSV error
 }
@@ -301,17 +350,18 @@ func TestEventAPI_CreateEvent_RepositoryError(t *testing.T) {
 	mockRepo := new(MockEventRepo)
 	api := NewEventAPI(mockRepo)
 
-	mockRepo.On("CreateEvent", mock.Anything, mock.Anything).Return(errors.New("database connection failed"))
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("database connection failed"))
 
-	err = api.createEvent(c)
+	err = handle(c, api.createEvent)
 
-	assert.NoError(t, err) // Echo doesn't return error for JSON responses
+	assert.Error(t, err)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 
 	var response model.BaseResponse
 	err = json.Unmarshal(rec.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response.Message, "database connection failed")
+	assert.Equal(t, string(apperr.CodeInternal), response.Code)
+	assert.NotContains(t, response.Message, "database connection failed")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -344,7 +394,7 @@ func TestEventAPI_CreateEvent_EmptyCSV(t *testing.T) {
 	mockRepo := new(MockEventRepo)
 	api := NewEventAPI(mockRepo)
 
-	mockRepo.On("CreateEvent", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	err = api.createEvent(c)
 
@@ -384,7 +434,7 @@ func TestEventAPI_CreateEvent_WithTestDataFiles(t *testing.T) {
 		{
 			name:           "Malformed CSV file",
 			fileName:       "malformed.csv",
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusBadRequest,
 			shouldCallRepo: false,
 		},
 	}
@@ -420,12 +470,11 @@ func TestEventAPI_CreateEvent_WithTestDataFiles(t *testing.T) {
 			api := NewEventAPI(mockRepo)
 
 			if tc.shouldCallRepo {
-				mockRepo.On("CreateEvent", mock.Anything, mock.Anything).Return(nil)
+				mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 			}
 
-			err = api.createEvent(c)
+			handle(c, api.createEvent)
 
-			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedStatus, rec.Code)
 
 			if tc.shouldCallRepo {
@@ -433,4 +482,636 @@ func TestEventAPI_CreateEvent_WithTestDataFiles(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestEventAPI_CreateEvent_PersistsTodos(t *testing.T) {
+	e := echo.New()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	nameField, err := writer.CreateFormField("name")
+	assert.NoError(t, err)
+	_, err = nameField.Write([]byte("Test Event"))
+	assert.NoError(t, err)
+
+	csvField, err := writer.CreateFormFile("csvfile", "test.csv")
+	assert.NoError(t, err)
+	_, err = csvField.Write([]byte("todo_name,note\nBuy groceries,Get milk and bread\nCall dentist,Schedule appointment"))
+	assert.NoError(t, err)
+
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{Subject: "alice", TenantID: "tenant-a"}))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.MatchedBy(func(todos []model.TodoEvent) bool {
+		return len(todos) == 2 &&
+			todos[0].Name == "Buy groceries" &&
+			todos[0].Note == "Get milk and bread" &&
+			todos[1].Name == "Call dentist" &&
+			todos[0].EventID != "" &&
+			todos[0].ID != todos[1].ID
+	})).Return(nil)
+
+	err = api.createEvent(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Data model.Event `json:"data"`
+	}
+	err = json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant-a", response.Data.TenantID)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_CreateEvent_KeepCustomFields(t *testing.T) {
+	upload := func(keep string) (*httptest.ResponseRecorder, echo.Context) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		assert.NoError(t, writer.WriteField("name", "Offsite"))
+		assert.NoError(t, writer.WriteField("keep_custom_fields", keep))
+		csvField, err := writer.CreateFormFile("csvfile", "todos.csv")
+		assert.NoError(t, err)
+		_, err = csvField.Write([]byte("todo_name,note,region\nBook venue,Downtown,north\n"))
+		assert.NoError(t, err)
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		return rec, echo.New().NewContext(req, rec)
+	}
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.MatchedBy(func(todos []model.TodoEvent) bool {
+		return len(todos) == 1 && todos[0].CustomFields["region"] == "north"
+	})).Return(nil).Once()
+
+	rec, c := upload("true")
+	assert.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec, c = upload("sometimes")
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"keep_custom_fields"`)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_CreateEvent_Type(t *testing.T) {
+	upload := func(importType string) (*httptest.ResponseRecorder, echo.Context) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		assert.NoError(t, writer.WriteField("name", "Summit"))
+		assert.NoError(t, writer.WriteField("type", importType))
+		csvField, err := writer.CreateFormFile("csvfile", "attendees.csv")
+		assert.NoError(t, err)
+		_, err = csvField.Write([]byte("name,email\nAda,ada@example.com\n"))
+		assert.NoError(t, err)
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		return rec, echo.New().NewContext(req, rec)
+	}
+
+	mockRepo := new(MockEventRepo)
+	api := newSchemaAPI(t, mockRepo)
+
+	mockRepo.On("CreateEventRecords", mock.Anything, mock.Anything, "attendees", mock.MatchedBy(func(records []map[string]any) bool {
+		return len(records) == 1 && records[0]["email"] == "ada@example.com"
+	})).Return(nil).Once()
+
+	rec, c := upload("attendees")
+	assert.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"import_type":"attendees"`)
+
+	rec, c = upload("inventory")
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"type":"inventory"`)
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEventAPI_CreateEvent_DryRun(t *testing.T) {
+	upload := func(fields map[string]string) (*httptest.ResponseRecorder, echo.Context) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		for name, value := range fields {
+			require.NoError(t, writer.WriteField(name, value))
+		}
+		csvField, err := writer.CreateFormFile("csvfile", "attendees.csv")
+		require.NoError(t, err)
+		_, err = csvField.Write([]byte("name,email\n  ada  lovelace ,ada@example.com\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		return rec, echo.New().NewContext(req, rec)
+	}
+
+	mockRepo := new(MockEventRepo)
+	api := newSchemaAPI(t, mockRepo)
+
+	rec, c := upload(map[string]string{
+		"name":       "Summit",
+		"type":       "attendees",
+		"dry_run":    "true",
+		"transforms": `[{"op": "collapse_whitespace", "column": "name"}, {"op": "title_case", "column": "name"}]`,
+	})
+	require.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"rows":1`)
+	assert.Contains(t, rec.Body.String(), `"sample":[["Ada Lovelace","ada@example.com"]]`)
+	assert.Contains(t, rec.Body.String(), `{"op":"title_case","column":"name","cells_changed":1}`)
+
+	rec, c = upload(map[string]string{"dry_run": "maybe"})
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"dry_run"`)
+
+	rec, c = upload(map[string]string{"transforms": `[{"op": "trim"}]`})
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "transform 1 (trim): column is required")
+
+	mockRepo.AssertNotCalled(t, "CreateEventRecords", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEventAPI_CreateEvent_Duplicates(t *testing.T) {
+	upload := func(fields map[string]string) (*httptest.ResponseRecorder, echo.Context) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		for name, value := range fields {
+			require.NoError(t, writer.WriteField(name, value))
+		}
+		csvField, err := writer.CreateFormFile("csvfile", "todos.csv")
+		require.NoError(t, err)
+		_, err = csvField.Write([]byte("todo_name,note\nBook venue,Downtown\nbook venue,Uptown\nSend invites,\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		return rec, echo.New().NewContext(req, rec)
+	}
+
+	mockRepo := new(MockEventRepo)
+	var stored []model.TodoEvent
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]model.TodoEvent) }).
+		Return(nil)
+	api := NewEventAPI(mockRepo)
+
+	rec, c := upload(map[string]string{
+		"name":              "Offsite",
+		"duplicate_columns": " todo_name ,",
+		"duplicate_match":   "normalized",
+	})
+	require.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"details":{"rows_imported":2,"rows_rejected":0,"rows_duplicated":1,"rows_skipped":1,"duplicates":[{"row":2,"duplicate_of":1}]}`)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "Send invites", stored[1].Name)
+
+	rec, c = upload(map[string]string{"duplicate_columns": "todo_name", "duplicate_match": "normalized", "duplicate_policy": "fail"})
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "csvfile has duplicate rows")
+
+	rec, c = upload(map[string]string{"duplicates_across_events": "sometimes"})
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"duplicates_across_events"`)
+
+	mockRepo.AssertNumberOfCalls(t, "CreateEvent", 1)
+}
+
+func TestEventAPI_CreateEvent_Partial(t *testing.T) {
+	upload := func(mode string) (*httptest.ResponseRecorder, echo.Context) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		require.NoError(t, writer.WriteField("mode", mode))
+		csvField, err := writer.CreateFormFile("csvfile", "todos.csv")
+		require.NoError(t, err)
+		_, err = csvField.Write([]byte("todo_name,note,status\nBook venue,,done\nSend invites,,paused\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		return rec, echo.New().NewContext(req, rec)
+	}
+
+	mockRepo := new(MockEventRepo)
+	var event model.Event
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { event = args.Get(1).(model.Event) }).
+		Return(nil)
+	api := NewEventAPI(mockRepo)
+
+	rec, c := upload("partial")
+	require.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"details":{"rows_imported":1,"rows_rejected":1,`)
+	assert.Contains(t, rec.Body.String(), `"error_report":"/api/v1/imports/`+event.ID+`/report"`)
+	require.Len(t, event.Rejected, 1)
+	require.NotNil(t, event.Report)
+	assert.Equal(t, "Send invites,,paused", event.Rejected[0].Line)
+
+	rec, c = upload("strict")
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "csvfile has invalid rows")
+
+	rec, c = upload("lenient")
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "mode must be one of strict, partial")
+
+	mockRepo.AssertNumberOfCalls(t, "CreateEvent", 1)
+}
+
+func TestEventAPI_ListRecords(t *testing.T) {
+	request := func(id string) (*httptest.ResponseRecorder, echo.Context) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events/"+id+"/records", nil)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return rec, c
+	}
+
+	mockRepo := new(MockEventRepo)
+	api := newSchemaAPI(t, mockRepo)
+
+	mockRepo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1", ImportType: "attendees"}, nil)
+	mockRepo.On("GetEvent", mock.Anything, "event-2").Return(&model.Event{ID: "event-2", ImportType: model.ImportTypeTodos}, nil)
+	mockRepo.On("ListRecords", mock.Anything, "event-1", "attendees").Return([]map[string]any{
+		{"id": "row-1", "name": "Ada", "checked_in": int64(1)},
+	}, nil)
+
+	rec, c := request("event-1")
+	assert.NoError(t, api.listRecords(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"checked_in":true`, "Values have the type of their column")
+
+	rec, c = request("event-2")
+	assert.Error(t, handle(c, api.listRecords))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_GetEvent_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/event-1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("event-1")
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1", Name: "Test Event 1"}, nil)
+
+	err := api.getEvent(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"event-1"`)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_GetEvent_NotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/other-tenant-event", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("other-tenant-event")
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("GetEvent", mock.Anything, "other-tenant-event").Return(nil, gorm.ErrRecordNotFound)
+
+	err := handle(c, api.getEvent)
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var response model.BaseResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, string(apperr.CodeNotFound), response.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_ListTodos_UnknownEvent(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/other-tenant-event/todos", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("other-tenant-event")
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("GetEvent", mock.Anything, "other-tenant-event").Return(nil, gorm.ErrRecordNotFound)
+
+	err := handle(c, api.listTodos)
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// ListTodos must not be reached for an event outside the caller's tenant
+	mockRepo.AssertNotCalled(t, "ListTodos", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEventAPI_ListTodos_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/event-1/todos", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("event-1")
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	mockRepo.On("ListTodos", mock.Anything, "event-1", model.TodoFilter{}).Return([]model.TodoEvent{
+		{ID: "todo-1", EventID: "event-1", Name: "Buy groceries"},
+	}, nil)
+
+	err := api.listTodos(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"Buy groceries"`)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_ListTodos_Filters(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/event-1/todos?status=Done&priority=high&assignee_email=alice@example.com&tag=venue&due_before=2025-04-01&due_after=3+Mar+2025&custom_field=region:north&custom_field=time:09:30", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("event-1")
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	before := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	mockRepo.On("ListTodos", mock.Anything, "event-1", model.TodoFilter{
+		Status:        model.TodoDone,
+		Priority:      model.PriorityHigh,
+		AssigneeEmail: "alice@example.com",
+		Tag:           "venue",
+		DueBefore:     &before,
+		DueAfter:      &after,
+		CustomFields:  map[string]string{"region": "north", "time": "09:30"},
+	}).Return([]model.TodoEvent{}, nil)
+
+	err := api.listTodos(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_ListTodos_InvalidFilter(t *testing.T) {
+	testCases := []struct {
+		query string
+		field string
+	}{
+		{"status=paused", "status"},
+		{"priority=urgent", "priority"},
+		{"due_before=soon", "due_before"},
+		{"due_after=31/12/2025", "due_after"},
+		{"custom_field=region", "custom_field"},
+		{"custom_field=:north", "custom_field"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/events/event-1/todos?"+tc.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("event-1")
+
+			mockRepo := new(MockEventRepo)
+			api := NewEventAPI(mockRepo)
+
+			err := handle(c, api.listTodos)
+
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"field":"`+tc.field+`"`)
+			mockRepo.AssertNotCalled(t, "GetEvent", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestEventAPI_ExportEvent(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/event-1/export", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("event-1")
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	mockRepo.On("ListTodos", mock.Anything, "event-1", model.TodoFilter{}).Return([]model.TodoEvent{
+		{ID: "todo-1", EventID: "event-1", Name: "Buy groceries", Note: "Milk, bread"},
+		{ID: "todo-2", EventID: "event-1", Name: "Call dentist"},
+	}, nil)
+
+	err := api.exportEvent(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/csv")
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "event-1.csv")
+	assert.Equal(t, "todo_name,note\nBuy groceries,\"Milk, bread\"\nCall dentist,\n", rec.Body.String())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_UpdateEventStatus(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		repoErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "Valid status",
+			body:           `{"status":"start"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unknown status",
+			body:           `{"status":"archived"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Malformed body",
+			body:           `{"status":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Event of another tenant",
+			body:           `{"status":"end"}`,
+			repoErr:        gorm.ErrRecordNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/events/event-1/status", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("event-1")
+
+			mockRepo := new(MockEventRepo)
+			api := NewEventAPI(mockRepo)
+
+			mockRepo.On("UpdateEventStatus", mock.Anything, "event-1", mock.Anything).Return(tc.repoErr)
+			mockRepo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1", Status: model.Start}, nil)
+
+			handle(c, api.updateEventStatus)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestEventAPI_DeleteEvent(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/events/event-1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("event-1")
+
+	mockRepo := new(MockEventRepo)
+	api := NewEventAPI(mockRepo)
+
+	mockRepo.On("DeleteEvent", mock.Anything, "event-1").Return(nil)
+
+	err := api.deleteEvent(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventAPI_Setup_Authorization(t *testing.T) {
+	testCases := []struct {
+		name           string
+		role           auth.Role
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"Viewer can list events", auth.RoleViewer, http.MethodGet, "/api/v1/events", http.StatusOK},
+		{"Viewer cannot export", auth.RoleViewer, http.MethodGet, "/api/v1/events/event-1/export", http.StatusForbidden},
+		{"Viewer cannot upload", auth.RoleViewer, http.MethodPost, "/api/v1/event", http.StatusForbidden},
+		{"Importer cannot change status", auth.RoleImporter, http.MethodPatch, "/api/v1/events/event-1/status", http.StatusForbidden},
+		{"Editor cannot delete", auth.RoleEditor, http.MethodDelete, "/api/v1/events/event-1", http.StatusForbidden},
+		{"Admin can delete", auth.RoleAdmin, http.MethodDelete, "/api/v1/events/event-1", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockEventRepo)
+			mockRepo.On("ListEvents", mock.Anything).Return([]model.Event{}, nil)
+			mockRepo.On("DeleteEvent", mock.Anything, "event-1").Return(nil)
+
+			principal := auth.Principal{Subject: "alice", TenantID: "tenant-a", Role: tc.role}
+
+			e := echo.New()
+			e.HTTPErrorHandler = apperr.HTTPErrorHandler
+			v1g := e.Group("/api/v1", func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), principal)))
+					return next(c)
+				}
+			})
+			NewEventAPI(mockRepo).Setup(v1g)
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			if tc.expectedStatus == http.StatusForbidden {
+				var response struct {
+					Code    string      `json:"code"`
+					Details auth.Denial `json:"details"`
+				}
+				err := json.Unmarshal(rec.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, string(apperr.CodeForbidden), response.Code)
+				assert.Equal(t, auth.ReasonRoleNotAllowed, response.Details.Reason)
+				assert.Equal(t, tc.role, response.Details.Role)
+			}
+		})
+	}
+}
+
+// handle runs h the way echo would, passing a returned error to the central
+// error handler so tests can assert on the written response.
+func handle(c echo.Context, h echo.HandlerFunc) error {
+	err := h(c)
+	if err != nil {
+		apperr.HTTPErrorHandler(err, c)
+	}
+	return err
+}
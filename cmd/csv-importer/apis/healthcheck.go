@@ -1,45 +1,122 @@
 package apis
 
 import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/lifecycle"
+	"csv-importer-backend/cmd/csv-importer/migration"
 	"csv-importer-backend/cmd/csv-importer/model"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
+const (
+	healthCheckTimeout = 2 * time.Second
+
+	// Below this much free space in the upload directory, multipart uploads
+	// start failing.
+	minFreeUploadSpace = 512 << 20
+)
+
 type HealthCheckAPI struct {
-	db *gorm.DB
+	db        *gorm.DB
+	drainer   *lifecycle.Drainer
+	uploadDir string
 }
 
-func NewHealthCheckAPI(db *gorm.DB) *HealthCheckAPI {
+// NewHealthCheckAPI reports readiness as failing once drainer starts
+// draining. drainer may be nil.
+func NewHealthCheckAPI(db *gorm.DB, drainer *lifecycle.Drainer) *HealthCheckAPI {
 	return &HealthCheckAPI{
-		db: db,
+		db:        db,
+		drainer:   drainer,
+		uploadDir: os.TempDir(),
 	}
 }
 
 func (a *HealthCheckAPI) Setup(g *echo.Group) {
+	g.GET("/livez", a.liveness)
+	g.GET("/readyz", a.readiness)
 	g.GET("/healthz", a.healthCheck)
 }
 
+// check is one component of a health report. Only failing critical checks
+// make the report fail.
+type check struct {
+	name     string
+	critical bool
+	run      func(ctx context.Context) model.HealthCheck
+}
+
+func (a *HealthCheckAPI) readinessChecks() []check {
+	return []check{
+		{"shutdown", true, a.checkShutdown},
+		{"database", true, a.checkDatabase},
+		{"migrations", true, a.checkMigrations},
+	}
+}
+
+func (a *HealthCheckAPI) liveness(c echo.Context) error {
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Data:    model.HealthReport{Status: model.HealthOK},
+			Message: "alive",
+		},
+	)
+}
+
+func (a *HealthCheckAPI) readiness(c echo.Context) error {
+	return a.report(c, a.readinessChecks())
+}
+
 func (a *HealthCheckAPI) healthCheck(c echo.Context) error {
 
-	db, err := a.db.DB()
-	if err != nil {
-		return c.JSON(
-			http.StatusInternalServerError,
-			model.BaseResponse{
-				Message: err.Error(),
-			},
-		)
+	checks := append(a.readinessChecks(),
+		check{"pool", false, a.checkPool},
+		check{"imports", false, a.checkImports},
+		check{"disk", false, a.checkDisk},
+	)
+
+	return a.report(c, checks)
+}
+
+// report runs checks and answers 503 if a critical one fails. The individual
+// results are only included with ?verbose=1.
+func (a *HealthCheckAPI) report(c echo.Context, checks []check) error {
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), healthCheckTimeout)
+	defer cancel()
+
+	report := model.HealthReport{
+		Status: model.HealthOK,
+		Checks: make(map[string]model.HealthCheck, len(checks)),
 	}
 
-	err = db.Ping()
-	if err != nil {
+	for _, chk := range checks {
+		result := chk.run(ctx)
+		report.Checks[chk.name] = result
+
+		if chk.critical && result.Status == model.HealthFail {
+			report.Status = model.HealthFail
+		}
+	}
+
+	if c.QueryParam("verbose") != "1" {
+		report.Checks = nil
+	}
+
+	if report.Status == model.HealthFail {
 		return c.JSON(
-			http.StatusInternalServerError,
+			http.StatusServiceUnavailable,
 			model.BaseResponse{
-				Message: err.Error(),
+				Data:    report,
+				Message: "unhealthy",
 			},
 		)
 	}
@@ -47,7 +124,155 @@ func (a *HealthCheckAPI) healthCheck(c echo.Context) error {
 	return c.JSON(
 		http.StatusOK,
 		model.BaseResponse{
+			Data:    report,
 			Message: "healthy",
 		},
 	)
 }
+
+// failed reports reason as the error of a check. The reports are public, so
+// err, which may name hosts or paths, is only logged.
+func failed(ctx context.Context, reason string, err error, details map[string]any) model.HealthCheck {
+
+	if err != nil {
+		slog.WarnContext(ctx, "health check failed", "reason", reason, "error", err.Error())
+	}
+
+	return model.HealthCheck{
+		Status:  model.HealthFail,
+		Error:   reason,
+		Details: details,
+	}
+}
+
+func (a *HealthCheckAPI) checkShutdown(ctx context.Context) model.HealthCheck {
+
+	if a.drainer != nil && a.drainer.Draining() {
+		return failed(ctx, lifecycle.ErrDraining.Error(), nil, nil)
+	}
+
+	return model.HealthCheck{Status: model.HealthOK}
+}
+
+func (a *HealthCheckAPI) checkDatabase(ctx context.Context) model.HealthCheck {
+
+	db, err := a.db.DB()
+	if err != nil {
+		return failed(ctx, "database unreachable", err, nil)
+	}
+
+	start := time.Now()
+	err = db.PingContext(ctx)
+	details := map[string]any{
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		return failed(ctx, "database unreachable", err, details)
+	}
+
+	return model.HealthCheck{
+		Status:  model.HealthOK,
+		Details: details,
+	}
+}
+
+// checkMigrations fails while migrations are pending, since the handlers
+// expect the latest schema.
+func (a *HealthCheckAPI) checkMigrations(ctx context.Context) model.HealthCheck {
+
+	migrator, err := migration.New(a.db)
+	if err != nil {
+		return failed(ctx, "schema version unknown", err, nil)
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return failed(ctx, "schema version unknown", err, nil)
+	}
+
+	result := model.HealthCheck{
+		Status: model.HealthOK,
+		Details: map[string]any{
+			"version": version,
+			"latest":  migrator.Latest(),
+		},
+	}
+
+	switch {
+	case version < migrator.Latest():
+		result.Status = model.HealthFail
+		result.Error = "schema migrations are pending"
+	case version > migrator.Latest():
+		result.Status = model.HealthWarn
+		result.Error = "schema is newer than this build"
+	}
+
+	return result
+}
+
+func (a *HealthCheckAPI) checkPool(ctx context.Context) model.HealthCheck {
+
+	db, err := a.db.DB()
+	if err != nil {
+		return failed(ctx, "database unreachable", err, nil)
+	}
+
+	stats := db.Stats()
+
+	return model.HealthCheck{
+		Status: model.HealthOK,
+		Details: map[string]any{
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		},
+	}
+}
+
+func (a *HealthCheckAPI) checkImports(ctx context.Context) model.HealthCheck {
+
+	inFlight := 0
+	if a.drainer != nil {
+		inFlight = a.drainer.InFlight()
+	}
+
+	return model.HealthCheck{
+		Status: model.HealthOK,
+		Details: map[string]any{
+			"in_flight": inFlight,
+		},
+	}
+}
+
+// checkDisk warns when the directory multipart uploads are spooled to is
+// running out of space.
+func (a *HealthCheckAPI) checkDisk(ctx context.Context) model.HealthCheck {
+
+	free, total, err := diskSpace(a.uploadDir)
+	if err != nil {
+		slog.WarnContext(ctx, "health check failed", "reason", "free space unknown", "error", err.Error())
+		return model.HealthCheck{
+			Status: model.HealthWarn,
+			Error:  "free space unknown",
+		}
+	}
+
+	result := model.HealthCheck{
+		Status: model.HealthOK,
+		Details: map[string]any{
+			"free_bytes":  free,
+			"total_bytes": total,
+		},
+	}
+
+	if free < minFreeUploadSpace {
+		result.Status = model.HealthWarn
+		result.Error = "little free space left for uploads"
+	}
+
+	return result
+}
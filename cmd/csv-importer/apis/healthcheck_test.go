@@ -0,0 +1,143 @@
+package apis
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/lifecycle"
+	"csv-importer-backend/cmd/csv-importer/migration"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupHealthDB(t *testing.T, migrate bool) *gorm.DB {
+	db, err := repository.Open(repository.DriverSQLite, ":memory:", &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	if migrate {
+		migrator, err := migration.New(db)
+		require.NoError(t, err)
+		_, err = migrator.Up(context.Background())
+		require.NoError(t, err)
+	}
+
+	return db
+}
+
+type healthResponse struct {
+	Data    model.HealthReport `json:"data"`
+	Message string             `json:"message"`
+}
+
+func getHealth(t *testing.T, api *HealthCheckAPI, target string) (int, healthResponse) {
+	e := echo.New()
+	api.Setup(e.Group(""))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+	var response healthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+
+	return rec.Code, response
+}
+
+func TestHealthCheckAPI_Healthy(t *testing.T) {
+	api := NewHealthCheckAPI(setupHealthDB(t, true), lifecycle.New())
+
+	for _, target := range []string{"/livez", "/readyz", "/healthz"} {
+		code, response := getHealth(t, api, target)
+		assert.Equal(t, http.StatusOK, code, target)
+		assert.Equal(t, model.HealthOK, response.Data.Status, target)
+		assert.Empty(t, response.Data.Checks, "Checks are only listed when verbose")
+	}
+}
+
+func TestHealthCheckAPI_Verbose(t *testing.T) {
+	api := NewHealthCheckAPI(setupHealthDB(t, true), lifecycle.New())
+
+	code, response := getHealth(t, api, "/healthz?verbose=1")
+	require.Equal(t, http.StatusOK, code)
+
+	checks := response.Data.Checks
+	assert.ElementsMatch(t,
+		[]string{"shutdown", "database", "migrations", "pool", "imports", "disk"},
+		keys(checks),
+	)
+
+	assert.Equal(t, model.HealthOK, checks["database"].Status)
+	assert.Contains(t, checks["database"].Details, "latency_ms")
+	assert.Equal(t, checks["migrations"].Details["latest"], checks["migrations"].Details["version"])
+	assert.Contains(t, checks["pool"].Details, "open_connections")
+	assert.Equal(t, float64(0), checks["imports"].Details["in_flight"])
+	assert.NotEqual(t, model.HealthFail, checks["disk"].Status, "Disk space only warns")
+	assert.NotContains(t, checks["disk"].Details, "path", "Reports are public")
+
+	_, response = getHealth(t, api, "/readyz?verbose=1")
+	assert.ElementsMatch(t, []string{"shutdown", "database", "migrations"}, keys(response.Data.Checks))
+}
+
+func TestHealthCheckAPI_PendingMigrations(t *testing.T) {
+	api := NewHealthCheckAPI(setupHealthDB(t, false), nil)
+
+	code, response := getHealth(t, api, "/readyz?verbose=1")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unhealthy", response.Message)
+	assert.Equal(t, model.HealthFail, response.Data.Checks["migrations"].Status)
+	assert.Equal(t, float64(0), response.Data.Checks["migrations"].Details["version"])
+
+	code, _ = getHealth(t, api, "/livez")
+	assert.Equal(t, http.StatusOK, code, "Liveness does not depend on the database")
+}
+
+func TestHealthCheckAPI_Draining(t *testing.T) {
+	drainer := lifecycle.New()
+	api := NewHealthCheckAPI(setupHealthDB(t, true), drainer)
+
+	require.NoError(t, drainer.Drain(context.Background()))
+
+	code, response := getHealth(t, api, "/readyz?verbose=1")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, model.HealthFail, response.Data.Checks["shutdown"].Status)
+
+	code, _ = getHealth(t, api, "/livez")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestHealthCheckAPI_DatabaseDown(t *testing.T) {
+	db := setupHealthDB(t, true)
+	api := NewHealthCheckAPI(db, nil)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	code, response := getHealth(t, api, "/healthz?verbose=1")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, model.HealthFail, response.Data.Status)
+	assert.Equal(t, model.HealthFail, response.Data.Checks["database"].Status)
+	assert.Equal(t, "database unreachable", response.Data.Checks["database"].Error, "Driver errors are only logged")
+}
+
+func keys(m map[string]model.HealthCheck) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
@@ -0,0 +1,184 @@
+package apis
+
+import (
+	"bytes"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/importer"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// IImportRepo reads what imports left behind. An import is known by the ID
+// of the event it created.
+type IImportRepo interface {
+	GetEvent(ctx context.Context, id string) (*model.Event, error)
+	ListRejectedRows(ctx context.Context, eventID string) ([]model.RejectedRow, error)
+	GetImportReport(ctx context.Context, eventID string) (*model.ImportReport, error)
+	GetImportSource(ctx context.Context, eventID string) (*model.ImportSource, error)
+}
+
+// ImportAPI serves the rows partial imports left out, their error reports
+// and the files imports read.
+type ImportAPI struct {
+	importRepo IImportRepo
+	sources    blobstore.Store
+	policy     auth.Policy
+}
+
+func NewImportAPI(importRepo IImportRepo) *ImportAPI {
+
+	return &ImportAPI{
+		importRepo: importRepo,
+		policy:     auth.DefaultPolicy,
+	}
+}
+
+// WithSources serves the uploaded files kept in store.
+func (a *ImportAPI) WithSources(store blobstore.Store) *ImportAPI {
+	a.sources = store
+	return a
+}
+
+func (a *ImportAPI) Setup(g *echo.Group) {
+	can := func(action auth.Action) echo.MiddlewareFunc {
+		return auth.Authorize(a.policy, action)
+	}
+
+	g.GET("/imports/:id/rejects", a.listRejects, can(auth.ActionReadEvents))
+	g.GET("/imports/:id/rejects/export", a.exportRejects, can(auth.ActionExportEvent))
+	g.GET("/imports/:id/report", a.exportReport, can(auth.ActionExportEvent))
+	g.GET("/imports/:id/source", a.exportSource, can(auth.ActionExportEvent))
+}
+
+// rejects are the rows an import left out, with the header of the file.
+type rejects struct {
+	Header string              `json:"header"`
+	Rows   []model.RejectedRow `json:"rows"`
+}
+
+func (a *ImportAPI) rejectedRows(c echo.Context) (*rejects, error) {
+
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	_, err := a.importRepo.GetEvent(ctx, id)
+	if err != nil {
+		return nil, eventLookupError(err)
+	}
+
+	rows, err := a.importRepo.ListRejectedRows(ctx, id)
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	result := &rejects{Rows: rows}
+	if len(rows) > 0 {
+		result.Header = rows[0].Header
+	}
+
+	return result, nil
+}
+
+func (a *ImportAPI) listRejects(c echo.Context) error {
+
+	result, err := a.rejectedRows(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    result,
+		},
+	)
+}
+
+// exportRejects writes the rejected rows as they were uploaded, below the
+// header of the file, to be corrected and uploaded again. Imports without
+// rejected rows export an empty file.
+func (a *ImportAPI) exportRejects(c echo.Context) error {
+
+	result, err := a.rejectedRows(c)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if len(result.Rows) > 0 {
+		buf.WriteString(result.Header + "\n")
+		for _, row := range result.Rows {
+			buf.WriteString(row.Line + "\n")
+		}
+	}
+
+	c.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=%q", c.Param("id")+"-rejects.csv"),
+	)
+
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// reportPath is where the error report of the import of an event is
+// downloaded from.
+func reportPath(eventID string) string {
+	return "/api/v1/imports/" + eventID + "/report"
+}
+
+// exportReport writes the uploaded file annotated with the problems of every
+// row, or with failed_only=true only the rows with problems. Imports without
+// rejected rows export an empty file.
+func (a *ImportAPI) exportReport(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	var failedOnly bool
+	err := echo.QueryParamsBinder(c).
+		Bool("failed_only", &failedOnly).
+		BindError()
+
+	if err != nil {
+		return apperr.New(apperr.CodeBadRequest, "invalid query parameters").
+			WithDetails(map[string]string{"reason": err.Error()})
+	}
+
+	_, err = a.importRepo.GetEvent(ctx, id)
+	if err != nil {
+		return eventLookupError(err)
+	}
+
+	var content string
+	report, err := a.importRepo.GetImportReport(ctx, id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return apperr.Internal(err)
+	default:
+		content = report.Content
+	}
+
+	if failedOnly && content != "" {
+		content, err = importer.FailedRows(content)
+		if err != nil {
+			return apperr.Internal(err)
+		}
+	}
+
+	c.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=%q", id+"-report.csv"),
+	)
+
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", []byte(content))
+}
@@ -0,0 +1,170 @@
+package apis
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockImportRepo struct {
+	mock.Mock
+}
+
+func (m *MockImportRepo) GetEvent(ctx context.Context, id string) (*model.Event, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Event), args.Error(1)
+}
+
+func (m *MockImportRepo) ListRejectedRows(ctx context.Context, eventID string) ([]model.RejectedRow, error) {
+	args := m.Called(ctx, eventID)
+	return args.Get(0).([]model.RejectedRow), args.Error(1)
+}
+
+func (m *MockImportRepo) GetImportReport(ctx context.Context, eventID string) (*model.ImportReport, error) {
+	args := m.Called(ctx, eventID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ImportReport), args.Error(1)
+}
+
+func (m *MockImportRepo) GetImportSource(ctx context.Context, eventID string) (*model.ImportSource, error) {
+	args := m.Called(ctx, eventID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ImportSource), args.Error(1)
+}
+
+func TestImportAPI_Rejects(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+
+	role := auth.RoleViewer
+	v1g := e.Group("/api/v1", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.Principal{Subject: "ivy", TenantID: "tenant-a", Role: role}
+			c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+
+	repo := new(MockImportRepo)
+	repo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	repo.On("GetEvent", mock.Anything, "event-2").Return(&model.Event{ID: "event-2"}, nil)
+	repo.On("GetEvent", mock.Anything, "missing").Return(nil, gorm.ErrRecordNotFound)
+	repo.On("ListRejectedRows", mock.Anything, "event-1").Return([]model.RejectedRow{
+		{Row: 2, Header: "todo_name,note,priority", Line: "Send invites,\"Guests,\nplus ones\",urgent", Reasons: model.RejectReasons{{Column: "priority", Reason: "priority must be one of low, medium, high"}}},
+		{Row: 3, Header: "todo_name,note,priority", Line: "Order food,Lunch", Reasons: model.RejectReasons{{Reason: "row has 2 fields, the header has 3"}}},
+	}, nil)
+	repo.On("ListRejectedRows", mock.Anything, "event-2").Return([]model.RejectedRow{}, nil)
+	NewImportAPI(repo).Setup(v1g)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/imports/event-1/rejects")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{
+		"message": "success",
+		"data": {
+			"header": "todo_name,note,priority",
+			"rows": [
+				{"row": 2, "line": "Send invites,\"Guests,\nplus ones\",urgent", "reasons": [{"column": "priority", "reason": "priority must be one of low, medium, high"}]},
+				{"row": 3, "line": "Order food,Lunch", "reasons": [{"reason": "row has 2 fields, the header has 3"}]}
+			]
+		}
+	}`, rec.Body.String())
+
+	rec = get("/api/v1/imports/event-1/rejects/export")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "Viewers may not download rows")
+
+	role = auth.RoleImporter
+	rec = get("/api/v1/imports/event-1/rejects/export")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="event-1-rejects.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "todo_name,note,priority\nSend invites,\"Guests,\nplus ones\",urgent\nOrder food,Lunch\n", rec.Body.String())
+
+	rec = get("/api/v1/imports/event-2/rejects/export")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = get("/api/v1/imports/missing/rejects")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestImportAPI_Report(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+
+	role := auth.RoleViewer
+	v1g := e.Group("/api/v1", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.Principal{Subject: "ivy", TenantID: "tenant-a", Role: role}
+			c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+
+	repo := new(MockImportRepo)
+	repo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	repo.On("GetEvent", mock.Anything, "event-2").Return(&model.Event{ID: "event-2"}, nil)
+	repo.On("GetEvent", mock.Anything, "missing").Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetImportReport", mock.Anything, "event-1").Return(&model.ImportReport{
+		EventID: "event-1",
+		Content: "todo_name,note,priority,_errors\n" +
+			"Book venue,Downtown,high,\n" +
+			"Send invites,\"Guests,\nplus ones\",urgent,\"priority must be one of low, medium, high\"\n" +
+			"Order food,Lunch,,\"row has 2 fields, the header has 3\"\n",
+	}, nil)
+	repo.On("GetImportReport", mock.Anything, "event-2").Return(nil, gorm.ErrRecordNotFound)
+	NewImportAPI(repo).Setup(v1g)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/imports/event-1/report")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "Viewers may not download rows")
+
+	role = auth.RoleImporter
+	rec = get("/api/v1/imports/event-1/report")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="event-1-report.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Contains(t, rec.Body.String(), "Book venue,Downtown,high,\n")
+
+	rec = get("/api/v1/imports/event-1/report?failed_only=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "todo_name,note,priority,_errors\n"+
+		"Send invites,\"Guests,\nplus ones\",urgent,\"priority must be one of low, medium, high\"\n"+
+		"Order food,Lunch,,\"row has 2 fields, the header has 3\"\n", rec.Body.String())
+
+	rec = get("/api/v1/imports/event-1/report?failed_only=maybe")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = get("/api/v1/imports/event-2/report?failed_only=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = get("/api/v1/imports/missing/report")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
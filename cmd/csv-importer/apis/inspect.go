@@ -0,0 +1,57 @@
+package apis
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/importer"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// InspectAPI profiles CSV files of unknown layout without importing them,
+// to help writing an import type for a new partner.
+type InspectAPI struct {
+	policy auth.Policy
+}
+
+func NewInspectAPI() *InspectAPI {
+
+	return &InspectAPI{
+		policy: auth.DefaultPolicy,
+	}
+}
+
+func (a *InspectAPI) Setup(g *echo.Group) {
+	g.POST("/csv/inspect", a.inspectCSV, auth.Authorize(a.policy, auth.ActionImportCSV))
+}
+
+func (a *InspectAPI) inspectCSV(c echo.Context) error {
+
+	csvfile, err := c.FormFile("csvfile")
+	if err != nil {
+		return apperr.Validation("csvfile is required").
+			WithDetails(map[string]string{"field": "csvfile"})
+	}
+
+	cf, err := csvfile.Open()
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	defer cf.Close()
+
+	report, err := importer.Inspect(c.Request().Context(), cf)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    report,
+		},
+	)
+}
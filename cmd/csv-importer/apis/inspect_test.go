@@ -0,0 +1,64 @@
+package apis
+
+import (
+	"bytes"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectAPI(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+
+	role := auth.RoleImporter
+	v1g := e.Group("/api/v1", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.Principal{Subject: "ivy", TenantID: "tenant-a", Role: role}
+			c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+	NewInspectAPI().Setup(v1g)
+
+	post := func(csv string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if csv != "" {
+			part, err := writer.CreateFormFile("csvfile", "partner.csv")
+			require.NoError(t, err)
+			_, err = part.Write([]byte(csv))
+			require.NoError(t, err)
+		}
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/csv/inspect", body)
+		req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("sku,price\nABC-1,9.5\nABC-2,\n")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"rows":2`)
+	assert.Contains(t, rec.Body.String(), `"name":"price","type":"decimal","schema_type":"number","nulls":1,"null_ratio":0.5`)
+
+	rec = post("")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "csvfile is required")
+
+	rec = post("a,b\n\"unclosed")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	role = auth.RoleViewer
+	rec = post("a\n1\n")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,53 @@
+package apis
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// OpenAPISpec is the contract of every route the server registers. The
+// contract tests check live responses against it, so it has to change
+// together with the handlers.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+//go:embed docs.js
+var docsScript []byte
+
+// docsPolicy lets the docs page run its own script only, so the page needs
+// nothing from outside the server.
+const docsPolicy = "default-src 'none'; script-src 'self'; connect-src 'self'; style-src 'unsafe-inline'"
+
+type DocsAPI struct{}
+
+func NewDocsAPI() *DocsAPI {
+	return &DocsAPI{}
+}
+
+// Setup registers the spec and a page rendering it. Both are public, so g
+// should not carry the authentication middleware.
+func (a *DocsAPI) Setup(g *echo.Group) {
+	g.GET("/openapi.json", a.spec)
+	g.GET("/docs", a.docs)
+	g.GET("/docs.js", a.script)
+}
+
+func (a *DocsAPI) spec(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, OpenAPISpec)
+}
+
+func (a *DocsAPI) docs(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentSecurityPolicy, docsPolicy)
+	return c.HTMLBlob(http.StatusOK, docsPage)
+}
+
+func (a *DocsAPI) script(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderXContentTypeOptions, "nosniff")
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJavaScriptCharsetUTF8, docsScript)
+}
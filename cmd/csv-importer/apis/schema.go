@@ -0,0 +1,59 @@
+package apis
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SchemaAPI lists the import types uploads may name.
+type SchemaAPI struct {
+	registry *schema.Registry
+	policy   auth.Policy
+}
+
+func NewSchemaAPI(registry *schema.Registry) *SchemaAPI {
+
+	return &SchemaAPI{
+		registry: registry,
+		policy:   auth.DefaultPolicy,
+	}
+}
+
+func (a *SchemaAPI) Setup(g *echo.Group) {
+	can := auth.Authorize(a.policy, auth.ActionReadSchemas)
+
+	g.GET("/schemas", a.listSchemas, can)
+	g.GET("/schemas/:name", a.getSchema, can)
+}
+
+func (a *SchemaAPI) listSchemas(c echo.Context) error {
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    a.registry.List(),
+		},
+	)
+}
+
+func (a *SchemaAPI) getSchema(c echo.Context) error {
+
+	s, ok := a.registry.Get(c.Param("name"))
+	if !ok {
+		return apperr.NotFound("schema not found")
+	}
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    s,
+		},
+	)
+}
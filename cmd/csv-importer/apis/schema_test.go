@@ -0,0 +1,48 @@
+package apis
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaAPI(t *testing.T) {
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	v1g := e.Group("/api/v1", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.Principal{Subject: "victor", TenantID: "tenant-a", Role: auth.RoleViewer}
+			c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+	NewSchemaAPI(schemas).Setup(v1g)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/schemas")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"attendees"`)
+	assert.Contains(t, rec.Body.String(), `"name":"todos"`)
+
+	rec = get("/api/v1/schemas/attendees")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"name":"ticket_type","type":"string","enum":["standard","vip","speaker","staff"]}`)
+
+	rec = get("/api/v1/schemas/inventory")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
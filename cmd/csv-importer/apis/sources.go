@@ -0,0 +1,160 @@
+package apis
+
+import (
+	"context"
+	"crypto/sha256"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/importer"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// upload is a file to import as it was received. open may be called more
+// than once.
+type upload struct {
+	open        func() (io.ReadCloser, error)
+	size        int64
+	filename    string
+	contentType string
+}
+
+// keepSource copies an upload to store and describes it, to be stored with
+// the event of its import. Without a store nothing is kept.
+func keepSource(ctx context.Context, store blobstore.Store, u upload) (*model.ImportSource, error) {
+
+	if store == nil {
+		return nil, nil
+	}
+
+	ctx, span := tracing.Start(ctx, "upload.keep", attribute.Int64("upload.size", u.size))
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	source := &model.ImportSource{
+		BlobKey:     "sources/" + id.String(),
+		Filename:    u.filename,
+		ContentType: u.contentType,
+		CreateDate:  time.Now(),
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		source.UploadedBy = p.Subject
+	}
+	source.Clip()
+
+	r, err := u.open()
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	defer r.Close()
+
+	hash := sha256.New()
+	counter := &importer.CountingReader{R: io.TeeReader(r, hash)}
+
+	err = store.Put(ctx, source.BlobKey, counter, u.size)
+	if err == nil && counter.N != u.size {
+		err = fmt.Errorf("upload has %d bytes, expected %d", counter.N, u.size)
+	}
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	source.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	source.Size = counter.N
+
+	tracing.End(span, nil)
+	return source, nil
+}
+
+// dropSource removes the kept upload of a failed import.
+func dropSource(ctx context.Context, store blobstore.Store, source *model.ImportSource) {
+
+	if source == nil {
+		return
+	}
+
+	err := store.Delete(context.WithoutCancel(ctx), source.BlobKey)
+	if err != nil {
+		slog.WarnContext(ctx, "deleting upload of failed import", "key", source.BlobKey, "error", err.Error())
+	}
+}
+
+// exportSource writes the file an import read, as it was uploaded. Imports
+// made before uploads were kept, or from the command line, have none.
+func (a *ImportAPI) exportSource(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	_, err := a.importRepo.GetEvent(ctx, id)
+	if err != nil {
+		return eventLookupError(err)
+	}
+
+	notFound := apperr.NotFound("source file not found")
+	if a.sources == nil {
+		return notFound
+	}
+
+	source, err := a.importRepo.GetImportSource(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	blob, err := a.sources.Get(ctx, source.BlobKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return notFound
+	}
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	defer blob.Close()
+
+	sum, err := hex.DecodeString(source.SHA256)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	// The name and content type are the uploader's, so the file is always
+	// served as CSV, never as a type the browser might render
+	filename := source.Filename
+	if filename == "" {
+		filename = id + ".csv"
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set(echo.HeaderContentLength, fmt.Sprint(source.Size))
+	header.Set(echo.HeaderLastModified, source.CreateDate.UTC().Format(http.TimeFormat))
+	header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
+	header.Set("X-Uploaded-By", source.UploadedBy)
+
+	return c.Stream(http.StatusOK, "text/csv", blob)
+}
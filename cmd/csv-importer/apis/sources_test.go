@@ -0,0 +1,205 @@
+package apis
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEventAPI_CreateEvent_KeepsSource(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blobstore.NewFS(dir)
+	require.NoError(t, err)
+
+	upload := func(csv string, fields map[string]string) (*httptest.ResponseRecorder, echo.Context) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		require.NoError(t, writer.WriteField("name", "Offsite"))
+		for name, value := range fields {
+			require.NoError(t, writer.WriteField(name, value))
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": {`form-data; name="csvfile"; filename="offsite.csv"`},
+			"Content-Type":        {"text/csv"},
+		})
+		require.NoError(t, err)
+		_, err = part.Write([]byte(csv))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{Subject: "alice", TenantID: "tenant-a"}))
+		rec := httptest.NewRecorder()
+		return rec, echo.New().NewContext(req, rec)
+	}
+
+	mockRepo := new(MockEventRepo)
+	var event model.Event
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { event = args.Get(1).(model.Event) }).
+		Return(nil)
+	api := NewEventAPI(mockRepo).WithSources(store)
+
+	csv := "todo_name,note\nBook venue,Downtown\n"
+	rec, c := upload(csv, nil)
+	require.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	source := event.Source
+	require.NotNil(t, source)
+	sum := sha256.Sum256([]byte(csv))
+	assert.Equal(t, hex.EncodeToString(sum[:]), source.SHA256)
+	assert.Equal(t, int64(len(csv)), source.Size)
+	assert.Equal(t, "offsite.csv", source.Filename)
+	assert.Equal(t, "text/csv", source.ContentType)
+	assert.Equal(t, "alice", source.UploadedBy)
+
+	blob, err := store.Get(context.Background(), source.BlobKey)
+	require.NoError(t, err)
+	data, err := io.ReadAll(blob)
+	require.NoError(t, err)
+	require.NoError(t, blob.Close())
+	assert.Equal(t, csv, string(data))
+
+	rec, c = upload("todo_name,note,priority\nBook venue,,urgent\n", nil)
+	assert.Error(t, handle(c, api.createEvent))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, c = upload(csv, map[string]string{"dry_run": "true"})
+	require.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "sources"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "Failed imports and dry runs keep no file")
+	mockRepo.AssertNumberOfCalls(t, "CreateEvent", 1)
+}
+
+func TestEventAPI_CreateEvent_ClipsLongSourceNames(t *testing.T) {
+	store, err := blobstore.NewFS(t.TempDir())
+	require.NoError(t, err)
+
+	filename := strings.Repeat("é", 300) + ".csv"
+	contentType := "text/csv; x=" + strings.Repeat("a", 300)
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	require.NoError(t, writer.WriteField("name", "Offsite"))
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="csvfile"; filename="` + filename + `"`},
+		"Content-Type":        {contentType},
+	})
+	require.NoError(t, err)
+	_, err = part.Write([]byte("todo_name,note\nBook venue,Downtown\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	mockRepo := new(MockEventRepo)
+	var event model.Event
+	mockRepo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { event = args.Get(1).(model.Event) }).
+		Return(nil)
+	api := NewEventAPI(mockRepo).WithSources(store)
+
+	require.NoError(t, api.createEvent(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	require.NotNil(t, event.Source)
+	assert.Equal(t, strings.Repeat("é", model.MaxSourceFilename), event.Source.Filename)
+	assert.Equal(t, contentType[:model.MaxSourceContentType], event.Source.ContentType)
+}
+
+func TestImportAPI_Source(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+
+	role := auth.RoleViewer
+	v1g := e.Group("/api/v1", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.Principal{Subject: "ivy", TenantID: "tenant-a", Role: role}
+			c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+
+	store, err := blobstore.NewFS(t.TempDir())
+	require.NoError(t, err)
+	csv := "todo_name,note\nBook venue,Downtown\n"
+	require.NoError(t, store.Put(context.Background(), "sources/one", strings.NewReader(csv), int64(len(csv))))
+	sum := sha256.Sum256([]byte(csv))
+
+	repo := new(MockImportRepo)
+	for _, id := range []string{"event-1", "event-2", "event-3"} {
+		repo.On("GetEvent", mock.Anything, id).Return(&model.Event{ID: id}, nil)
+	}
+	repo.On("GetEvent", mock.Anything, "missing").Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetImportSource", mock.Anything, "event-1").Return(&model.ImportSource{
+		EventID:     "event-1",
+		BlobKey:     "sources/one",
+		SHA256:      hex.EncodeToString(sum[:]),
+		Size:        int64(len(csv)),
+		Filename:    `off"site ü.csv`,
+		ContentType: "text/html",
+		UploadedBy:  "alice",
+		CreateDate:  time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC),
+	}, nil)
+	repo.On("GetImportSource", mock.Anything, "event-2").Return(nil, gorm.ErrRecordNotFound)
+	repo.On("GetImportSource", mock.Anything, "event-3").Return(&model.ImportSource{EventID: "event-3", BlobKey: "sources/gone"}, nil)
+	NewImportAPI(repo).WithSources(store).Setup(v1g)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/imports/event-1/source")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "Viewers may not download rows")
+
+	role = auth.RoleImporter
+	rec = get("/api/v1/imports/event-1/source")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, csv, rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get(echo.HeaderContentType), "Never the type the uploader claimed")
+	assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+	_, params, err := mime.ParseMediaType(rec.Header().Get(echo.HeaderContentDisposition))
+	require.NoError(t, err)
+	assert.Equal(t, `off"site ü.csv`, params["filename"])
+	assert.Equal(t, "Mon, 03 Mar 2025 10:00:00 GMT", rec.Header().Get(echo.HeaderLastModified))
+	assert.Equal(t, "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":", rec.Header().Get("Content-Digest"))
+	assert.Equal(t, "alice", rec.Header().Get("X-Uploaded-By"))
+
+	for _, id := range []string{"event-2", "event-3", "missing"} {
+		rec = get("/api/v1/imports/" + id + "/source")
+		assert.Equal(t, http.StatusNotFound, rec.Code, id)
+	}
+}
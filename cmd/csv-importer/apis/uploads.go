@@ -0,0 +1,689 @@
+package apis
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/importer"
+	"csv-importer-backend/cmd/csv-importer/lifecycle"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/ratelimit"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// The tus resumable upload protocol, see https://tus.io/protocols/resumable-upload.
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,checksum,termination"
+
+	HeaderTusResumable         = "Tus-Resumable"
+	HeaderTusVersion           = "Tus-Version"
+	HeaderTusExtension         = "Tus-Extension"
+	HeaderTusMaxSize           = "Tus-Max-Size"
+	HeaderTusChecksumAlgorithm = "Tus-Checksum-Algorithm"
+	HeaderUploadLength         = "Upload-Length"
+	HeaderUploadOffset         = "Upload-Offset"
+	HeaderUploadMetadata       = "Upload-Metadata"
+	HeaderUploadChecksum       = "Upload-Checksum"
+	HeaderUploadDeferLength    = "Upload-Defer-Length"
+
+	MIMEOffsetOctetStream = "application/offset+octet-stream"
+
+	// StatusChecksumMismatch is the status tus defines for chunks that do
+	// not match their Upload-Checksum.
+	StatusChecksumMismatch = 460
+
+	DefaultUploadMaxSize = 256 << 20
+)
+
+// uploadImportTimeout bounds the import of a complete upload. Uploads still
+// importing after it were abandoned, by a crash or a failure to record the
+// outcome, and may be deleted.
+const uploadImportTimeout = 30 * time.Minute
+
+// uploadFinishTimeout bounds recording the outcome of an import, which is
+// done even when the import was cancelled.
+const uploadFinishTimeout = 5 * time.Second
+
+// checksumAlgorithms are the Upload-Checksum algorithms accepted.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// IUploadRepo stores the uploads in progress.
+type IUploadRepo interface {
+	CreateUpload(ctx context.Context, upload model.Upload) error
+	GetUpload(ctx context.Context, id string) (*model.Upload, error)
+	AppendChunk(ctx context.Context, upload *model.Upload, key string, size int64) error
+	FinishUpload(ctx context.Context, upload *model.Upload) error
+	DeleteUpload(ctx context.Context, id string) error
+}
+
+// UploadAPI receives files in chunks over the tus protocol. The chunks are
+// kept in the blob store of the event API, and the complete file goes
+// through the same import as createEvent.
+type UploadAPI struct {
+	uploadRepo IUploadRepo
+	events     *EventAPI
+	maxSize    int64
+	policy     auth.Policy
+	base       context.Context
+	slots      *ratelimit.Slots
+}
+
+func NewUploadAPI(uploadRepo IUploadRepo, events *EventAPI) *UploadAPI {
+
+	return &UploadAPI{
+		uploadRepo: uploadRepo,
+		events:     events,
+		maxSize:    DefaultUploadMaxSize,
+		policy:     auth.DefaultPolicy,
+		base:       context.Background(),
+	}
+}
+
+// WithMaxSize refuses uploads of more than n bytes.
+func (a *UploadAPI) WithMaxSize(n int64) *UploadAPI {
+	a.maxSize = n
+	return a
+}
+
+// WithBaseContext cancels the imports of complete uploads once ctx is done.
+// They outlive the request that completes the upload, so that a client
+// giving up does not stop them, but not ctx.
+func (a *UploadAPI) WithBaseContext(ctx context.Context) *UploadAPI {
+	a.base = ctx
+	return a
+}
+
+// WithImportSlots has the chunk that completes an upload hold one of slots
+// while the file is imported. Other chunks import nothing and need none.
+func (a *UploadAPI) WithImportSlots(slots *ratelimit.Slots) *UploadAPI {
+	a.slots = slots
+	return a
+}
+
+// Setup registers nothing without a blob store to keep the chunks in.
+func (a *UploadAPI) Setup(g *echo.Group) {
+	if a.events.sources == nil {
+		return
+	}
+
+	can := auth.Authorize(a.policy, auth.ActionImportCSV)
+
+	g.OPTIONS("/uploads", a.options, can, tusResumable)
+	g.POST("/uploads", a.createUpload, can, tusResumable)
+	g.HEAD("/uploads/:id", a.headUpload, can, tusResumable)
+	g.GET("/uploads/:id", a.getUpload, can, tusResumable)
+	g.PATCH("/uploads/:id", a.patchUpload, can, tusResumable)
+	g.DELETE("/uploads/:id", a.deleteUpload, can, tusResumable)
+}
+
+// IsUploadChunk reports whether c sends a chunk of an upload. Chunks do not
+// count as uploads of their own; the last one imports the file, and takes an
+// import slot for it, see WithImportSlots.
+func IsUploadChunk(c echo.Context) bool {
+	return c.Request().Method == http.MethodPatch && strings.HasSuffix(c.Path(), "/uploads/:id")
+}
+
+// tusResumable marks every response with the protocol version, and refuses
+// requests for other versions. OPTIONS and the JSON status need none.
+func tusResumable(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+
+		c.Response().Header().Set(HeaderTusResumable, TusVersion)
+
+		switch c.Request().Method {
+		case http.MethodOptions, http.MethodGet:
+			return next(c)
+		}
+
+		if c.Request().Header.Get(HeaderTusResumable) != TusVersion {
+			c.Response().Header().Set(HeaderTusVersion, TusVersion)
+			return &apperr.Error{
+				Code:    apperr.CodeBadRequest,
+				Message: "unsupported tus version",
+				Details: map[string]string{"supported": TusVersion},
+				Status:  http.StatusPreconditionFailed,
+			}
+		}
+
+		return next(c)
+	}
+}
+
+func uploadPath(id string) string {
+	return "/api/v1/uploads/" + id
+}
+
+func (a *UploadAPI) options(c echo.Context) error {
+
+	header := c.Response().Header()
+	header.Set(HeaderTusVersion, TusVersion)
+	header.Set(HeaderTusExtension, TusExtensions)
+	header.Set(HeaderTusMaxSize, strconv.FormatInt(a.maxSize, 10))
+	header.Set(HeaderTusChecksumAlgorithm, "sha1,sha256")
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// createUpload starts an upload of Upload-Length bytes. Upload-Metadata
+// carries the name of the event, the filename and filetype of the file and
+// the form fields of createEvent, which are checked right away rather than
+// once the whole file is in.
+func (a *UploadAPI) createUpload(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	header := c.Request().Header
+
+	if header.Get(HeaderUploadDeferLength) != "" {
+		return apperr.New(apperr.CodeBadRequest, "Upload-Defer-Length is not supported")
+	}
+
+	length, err := strconv.ParseInt(header.Get(HeaderUploadLength), 10, 64)
+	if err != nil || length <= 0 {
+		return apperr.New(apperr.CodeBadRequest, "Upload-Length must be a positive integer")
+	}
+
+	if length > a.maxSize {
+		return tooLarge(a.maxSize)
+	}
+
+	metadata, err := parseMetadata(header.Get(HeaderUploadMetadata))
+	if err != nil {
+		return err
+	}
+
+	_, err = importOptions(metadata.Get)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	now := time.Now()
+	up := model.Upload{
+		ID:         id.String(),
+		Length:     length,
+		Metadata:   metadata,
+		Status:     model.UploadReceiving,
+		CreateDate: now,
+		UpdateDate: now,
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		up.CreatedBy = p.Subject
+	}
+
+	err = a.uploadRepo.CreateUpload(ctx, up)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, uploadPath(up.ID))
+	return c.NoContent(http.StatusCreated)
+}
+
+func (a *UploadAPI) lookup(c echo.Context) (*model.Upload, error) {
+
+	up, err := a.uploadRepo.GetUpload(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperr.NotFound("upload not found")
+	}
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	return up, nil
+}
+
+func (a *UploadAPI) headUpload(c echo.Context) error {
+
+	up, err := a.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	header := c.Response().Header()
+	header.Set(HeaderUploadOffset, strconv.FormatInt(up.Offset, 10))
+	header.Set(HeaderUploadLength, strconv.FormatInt(up.Length, 10))
+	header.Set(echo.HeaderCacheControl, "no-store")
+
+	return c.NoContent(http.StatusOK)
+}
+
+// getUpload reports the progress of an upload and, once it is complete, the
+// outcome of its import.
+func (a *UploadAPI) getUpload(c echo.Context) error {
+
+	up, err := a.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(
+		http.StatusOK,
+		model.BaseResponse{
+			Message: "success",
+			Data:    up,
+		},
+	)
+}
+
+// patchUpload appends the chunk in the body at Upload-Offset. A chunk only
+// counts once it is received in full, and matches Upload-Checksum when
+// there is one; otherwise the client resumes from the previous offset. The
+// last chunk imports the file before the response is sent.
+func (a *UploadAPI) patchUpload(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	header := c.Request().Header
+
+	if header.Get(echo.HeaderContentType) != MIMEOffsetOctetStream {
+		return &apperr.Error{
+			Code:    apperr.CodeBadRequest,
+			Message: "Content-Type must be " + MIMEOffsetOctetStream,
+			Status:  http.StatusUnsupportedMediaType,
+		}
+	}
+
+	offset, err := strconv.ParseInt(header.Get(HeaderUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		return apperr.New(apperr.CodeBadRequest, "Upload-Offset must be a non-negative integer")
+	}
+
+	sum, err := parseChecksum(header.Get(HeaderUploadChecksum))
+	if err != nil {
+		return err
+	}
+
+	up, err := a.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	if up.Status != model.UploadReceiving {
+		return apperr.Conflict("upload is complete").
+			WithDetails(map[string]string{"status": up.Status})
+	}
+
+	if offset != up.Offset {
+		return offsetMismatch(up.Offset)
+	}
+
+	chunk, size, err := spool(ctx, c.Request().Body, up.Length-up.Offset, sum)
+	if err != nil {
+		return err
+	}
+
+	defer removeSpool(chunk)
+
+	// Refused before it is appended, so the client can send it again
+	if a.slots != nil && up.Offset+size == up.Length {
+		if !a.slots.TryAcquire() {
+			return ratelimit.Busy(c)
+		}
+		defer a.slots.Release()
+	}
+
+	if size > 0 {
+		err = a.appendChunk(ctx, up, chunk, size)
+		if err != nil {
+			return err
+		}
+	}
+
+	if up.Status == model.UploadImporting {
+		// The file is complete, it is imported even if the client gives up,
+		// but not once the server does
+		importCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), uploadImportTimeout)
+		stop := context.AfterFunc(a.base, cancel)
+		err = a.importUpload(importCtx, up)
+		stop()
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Response().Header().Set(HeaderUploadOffset, strconv.FormatInt(up.Offset, 10))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// appendChunk keeps a chunk in the blob store and records it on up.
+func (a *UploadAPI) appendChunk(ctx context.Context, up *model.Upload, chunk io.Reader, size int64) error {
+
+	store := a.events.sources
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return apperr.Internal(err)
+	}
+	key := "uploads/" + up.ID + "/" + id.String()
+
+	err = store.Put(ctx, key, chunk, size)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	err = a.uploadRepo.AppendChunk(ctx, up, key, size)
+	if err != nil {
+		dropChunks(ctx, store, []string{key})
+	}
+	if errors.Is(err, model.ErrUploadMoved) {
+		// Another request appended at the same offset first
+		current, err := a.uploadRepo.GetUpload(ctx, up.ID)
+		if err != nil {
+			return apperr.Internal(err)
+		}
+		return offsetMismatch(current.Offset)
+	}
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
+
+// importUpload imports a complete upload the way createEvent imports a form
+// upload, and records the response on up. The chunks are dropped once the
+// outcome is stored.
+func (a *UploadAPI) importUpload(ctx context.Context, up *model.Upload) error {
+
+	ctx, span := tracing.Start(ctx, "upload.import",
+		attribute.String("upload.id", up.ID),
+		attribute.Int64("upload.size", up.Length),
+		attribute.Int("upload.chunks", len(up.Chunks)),
+	)
+
+	store := a.events.sources
+	chunks := up.Chunks
+
+	opts, err := importOptions(up.Metadata.Get)
+	var result *importer.Result
+	if err == nil {
+		result, err = a.events.runImport(ctx, up.Metadata.Get("name"), upload{
+			open: func() (io.ReadCloser, error) {
+				return &chunkReader{ctx: ctx, store: store, keys: chunks}, nil
+			},
+			size:        up.Length,
+			filename:    up.Metadata.Get("filename"),
+			contentType: up.Metadata.Get("filetype"),
+		}, opts)
+	}
+
+	if err != nil && a.base.Err() != nil {
+		err = apperr.Unavailable(lifecycle.ErrDraining.Error())
+	}
+
+	if err != nil {
+		appErr := apperr.From(err)
+		if appErr.Code == apperr.CodeInternal {
+			slog.ErrorContext(ctx, "importing upload", "upload_id", up.ID, "error", err.Error())
+		}
+
+		up.Status = model.UploadFailed
+		up.Result = &model.UploadResult{
+			Code:    string(appErr.Code),
+			Message: appErr.Message,
+			Details: appErr.Details,
+		}
+	} else {
+		response := model.UploadResult(importResponse(result, opts))
+		up.Status = model.UploadImported
+		up.Result = &response
+		if !opts.DryRun {
+			up.EventID = &result.Event.ID
+		}
+	}
+	span.SetAttributes(attribute.String("upload.status", up.Status))
+
+	// Cancelled imports are recorded as failed too, so that the upload can be
+	// deleted and sent again
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), uploadFinishTimeout)
+	defer cancel()
+
+	err = a.uploadRepo.FinishUpload(finishCtx, up)
+	tracing.End(span, err)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	dropChunks(ctx, store, chunks)
+	return nil
+}
+
+// deleteUpload terminates an upload. Imports are kept, only the upload and
+// its chunks go. Uploads importing for longer than uploadImportTimeout are
+// stuck and may be deleted too.
+func (a *UploadAPI) deleteUpload(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	up, err := a.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	if up.Status == model.UploadImporting && time.Since(up.UpdateDate) < uploadImportTimeout {
+		return apperr.Conflict("upload is being imported")
+	}
+
+	err = a.uploadRepo.DeleteUpload(ctx, up.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.NotFound("upload not found")
+	}
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	dropChunks(ctx, a.events.sources, up.Chunks)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func tooLarge(maxSize int64) error {
+	return &apperr.Error{
+		Code:    apperr.CodeBadRequest,
+		Message: "upload is too large",
+		Details: map[string]int64{"max_size": maxSize},
+		Status:  http.StatusRequestEntityTooLarge,
+	}
+}
+
+func offsetMismatch(offset int64) error {
+	return apperr.Conflict("Upload-Offset does not match the upload").
+		WithDetails(map[string]int64{"offset": offset})
+}
+
+// parseMetadata decodes Upload-Metadata: comma-separated pairs of a key and
+// a base64 value, which may be left out.
+func parseMetadata(v string) (model.UploadMetadata, error) {
+
+	metadata := model.UploadMetadata{}
+	if strings.TrimSpace(v) == "" {
+		return metadata, nil
+	}
+
+	invalid := apperr.New(apperr.CodeBadRequest, "Upload-Metadata must be comma-separated pairs of a key and a base64 value")
+
+	for _, pair := range strings.Split(v, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if key == "" || err != nil {
+			return nil, invalid
+		}
+
+		if _, ok := metadata[key]; ok {
+			return nil, invalid.WithDetails(map[string]string{"duplicate_key": key})
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+// checksum is an Upload-Checksum, with the hash to check it against.
+type checksum struct {
+	hash hash.Hash
+	sum  []byte
+}
+
+func parseChecksum(v string) (*checksum, error) {
+
+	if v == "" {
+		return nil, nil
+	}
+
+	name, encoded, ok := strings.Cut(v, " ")
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if !ok || err != nil {
+		return nil, apperr.New(apperr.CodeBadRequest, "Upload-Checksum must be an algorithm and a base64 digest")
+	}
+
+	newHash, ok := checksumAlgorithms[name]
+	if !ok {
+		return nil, apperr.New(apperr.CodeBadRequest, "unsupported checksum algorithm").
+			WithDetails(map[string]any{"algorithm": name, "supported": []string{"sha1", "sha256"}})
+	}
+
+	return &checksum{hash: newHash(), sum: sum}, nil
+}
+
+// spool copies a chunk of at most limit bytes to a temporary file, checking
+// sum on the way, so that nothing is kept of chunks that do not arrive
+// whole.
+func spool(ctx context.Context, r io.Reader, limit int64, sum *checksum) (*os.File, int64, error) {
+
+	_, span := tracing.Start(ctx, "upload.receive")
+
+	f, err := os.CreateTemp("", "upload-chunk-*")
+	if err != nil {
+		err = apperr.Internal(err)
+		tracing.End(span, err)
+		return nil, 0, err
+	}
+
+	w := io.Writer(f)
+	if sum != nil {
+		w = io.MultiWriter(f, sum.hash)
+	}
+
+	n, err := io.Copy(w, io.LimitReader(r, limit+1))
+	if err != nil {
+		err = &apperr.Error{Code: apperr.CodeBadRequest, Message: "chunk was not received in full", Err: err}
+	}
+	if err == nil && n > limit {
+		err = &apperr.Error{
+			Code:    apperr.CodeBadRequest,
+			Message: "chunk goes past Upload-Length",
+			Details: map[string]int64{"remaining": limit},
+			Status:  http.StatusRequestEntityTooLarge,
+		}
+	}
+	if err == nil && sum != nil && !bytes.Equal(sum.hash.Sum(nil), sum.sum) {
+		err = &apperr.Error{
+			Code:    apperr.CodeValidation,
+			Message: "chunk does not match Upload-Checksum",
+			Status:  StatusChecksumMismatch,
+		}
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+
+	span.SetAttributes(attribute.Int64("upload.chunk_size", n))
+	tracing.End(span, err)
+
+	if err != nil {
+		removeSpool(f)
+		return nil, 0, err
+	}
+
+	return f, n, nil
+}
+
+func removeSpool(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// dropChunks removes chunks that are no longer needed. Failures only leave
+// garbage behind, they are logged.
+func dropChunks(ctx context.Context, store blobstore.Store, keys []string) {
+
+	for _, key := range keys {
+		err := store.Delete(context.WithoutCancel(ctx), key)
+		if err != nil {
+			slog.WarnContext(ctx, "deleting upload chunk", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// chunkReader reads the chunks of an upload in order, opening each one only
+// once the previous one is read.
+type chunkReader struct {
+	ctx   context.Context
+	store blobstore.Store
+	keys  []string
+	blob  io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+
+	for {
+		if r.blob == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+
+			blob, err := r.store.Get(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("reading upload chunk %s: %w", r.keys[0], err)
+			}
+			r.blob, r.keys = blob, r.keys[1:]
+		}
+
+		n, err := r.blob.Read(p)
+		if err == io.EOF {
+			err = r.blob.Close()
+			r.blob = nil
+			if n == 0 && err == nil {
+				continue
+			}
+		}
+
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+
+	if r.blob == nil {
+		return nil
+	}
+
+	return r.blob.Close()
+}
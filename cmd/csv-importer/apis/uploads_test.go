@@ -0,0 +1,460 @@
+package apis
+
+import (
+	"context"
+	"crypto/sha1"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeUploadRepo keeps uploads in memory, appending chunks only at the
+// offset they were read with like UploadRepo.
+type fakeUploadRepo struct {
+	mu        sync.Mutex
+	uploads   map[string]model.Upload
+	finishErr error
+}
+
+func newFakeUploadRepo() *fakeUploadRepo {
+	return &fakeUploadRepo{uploads: map[string]model.Upload{}}
+}
+
+func (r *fakeUploadRepo) CreateUpload(ctx context.Context, upload model.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload.TenantID = auth.TenantID(ctx)
+	r.uploads[upload.ID] = upload
+	return nil
+}
+
+func (r *fakeUploadRepo) GetUpload(ctx context.Context, id string) (*model.Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok || upload.TenantID != auth.TenantID(ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	upload.Chunks = slices.Clone(upload.Chunks)
+	return &upload, nil
+}
+
+func (r *fakeUploadRepo) AppendChunk(ctx context.Context, upload *model.Upload, key string, size int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.uploads[upload.ID]
+	if stored.Offset != upload.Offset || stored.Status != model.UploadReceiving {
+		return model.ErrUploadMoved
+	}
+	upload.Offset += size
+	upload.Chunks = append(upload.Chunks, key)
+	if upload.Offset == upload.Length {
+		upload.Status = model.UploadImporting
+	}
+	r.uploads[upload.ID] = *upload
+	return nil
+}
+
+func (r *fakeUploadRepo) FinishUpload(ctx context.Context, upload *model.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finishErr != nil {
+		return r.finishErr
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	upload.Chunks = model.UploadChunks{}
+	r.uploads[upload.ID] = *upload
+	return nil
+}
+
+func (r *fakeUploadRepo) DeleteUpload(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.uploads, id)
+	return nil
+}
+
+type uploadTest struct {
+	t       *testing.T
+	e       *echo.Echo
+	dir     string
+	events  *MockEventRepo
+	uploads *fakeUploadRepo
+	abort   context.CancelFunc
+}
+
+func newUploadTest(t *testing.T) *uploadTest {
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+
+	v1g := e.Group("/api/v1", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.Principal{Subject: "alice", TenantID: "tenant-a", Role: auth.RoleImporter}
+			c.SetRequest(c.Request().WithContext(auth.NewContext(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+
+	dir := t.TempDir()
+	store, err := blobstore.NewFS(dir)
+	require.NoError(t, err)
+
+	base, abort := context.WithCancel(context.Background())
+	t.Cleanup(abort)
+
+	events := new(MockEventRepo)
+	uploads := newFakeUploadRepo()
+	NewUploadAPI(uploads, NewEventAPI(events).WithSources(store)).
+		WithMaxSize(1 << 10).
+		WithBaseContext(base).
+		Setup(v1g)
+
+	return &uploadTest{t: t, e: e, dir: dir, events: events, uploads: uploads, abort: abort}
+}
+
+func (u *uploadTest) do(method string, target string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(HeaderTusResumable, TusVersion)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	u.e.ServeHTTP(rec, req)
+	return rec
+}
+
+func (u *uploadTest) create(length int, metadata string) string {
+	rec := u.do(http.MethodPost, "/api/v1/uploads", "", map[string]string{
+		HeaderUploadLength:   strconv.Itoa(length),
+		HeaderUploadMetadata: metadata,
+	})
+	require.Equal(u.t, http.StatusCreated, rec.Code, rec.Body.String())
+	return rec.Header().Get(echo.HeaderLocation)
+}
+
+func (u *uploadTest) patch(location string, offset int, chunk string, headers map[string]string) *httptest.ResponseRecorder {
+	all := map[string]string{
+		echo.HeaderContentType: MIMEOffsetOctetStream,
+		HeaderUploadOffset:     strconv.Itoa(offset),
+	}
+	for name, value := range headers {
+		all[name] = value
+	}
+	return u.do(http.MethodPatch, location, chunk, all)
+}
+
+func (u *uploadTest) status(location string) model.Upload {
+	rec := u.do(http.MethodGet, location, "", nil)
+	require.Equal(u.t, http.StatusOK, rec.Code)
+	var response struct {
+		Data model.Upload `json:"data"`
+	}
+	require.NoError(u.t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Data
+}
+
+func (u *uploadTest) files(sub string) []os.DirEntry {
+	entries, err := os.ReadDir(filepath.Join(u.dir, sub))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(u.t, err)
+	return entries
+}
+
+func metadata(pairs ...string) string {
+	var encoded []string
+	for n := 0; n < len(pairs); n += 2 {
+		encoded = append(encoded, pairs[n]+" "+base64.StdEncoding.EncodeToString([]byte(pairs[n+1])))
+	}
+	return strings.Join(encoded, ",")
+}
+
+func sha1Checksum(chunk string) string {
+	sum := sha1.Sum([]byte(chunk))
+	return "sha1 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestUploadAPI_Resumable(t *testing.T) {
+	u := newUploadTest(t)
+
+	var event model.Event
+	var todos []model.TodoEvent
+	u.events.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			event = args.Get(1).(model.Event)
+			todos = args.Get(2).([]model.TodoEvent)
+		}).
+		Return(nil)
+
+	rec := u.do(http.MethodOptions, "/api/v1/uploads", "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, TusVersion, rec.Header().Get(HeaderTusVersion))
+	assert.Equal(t, TusExtensions, rec.Header().Get(HeaderTusExtension))
+	assert.Equal(t, "1024", rec.Header().Get(HeaderTusMaxSize))
+
+	csv := "todo_name,note\nBook venue,Downtown\nOrder catering,Vegan\n"
+	first, second := csv[:20], csv[20:]
+
+	location := u.create(len(csv), metadata("name", "Offsite", "filename", "offsite.csv", "filetype", "text/csv", "mode", "partial"))
+	assert.True(t, strings.HasPrefix(location, "/api/v1/uploads/"))
+
+	rec = u.patch(location, 0, first, map[string]string{HeaderUploadChecksum: sha1Checksum(first)})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Equal(t, "20", rec.Header().Get(HeaderUploadOffset))
+	assert.Equal(t, TusVersion, rec.Header().Get(HeaderTusResumable))
+
+	rec = u.do(http.MethodHead, location, "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "20", rec.Header().Get(HeaderUploadOffset))
+	assert.Equal(t, strconv.Itoa(len(csv)), rec.Header().Get(HeaderUploadLength))
+	assert.Equal(t, "no-store", rec.Header().Get(echo.HeaderCacheControl))
+
+	rec = u.patch(location, 0, first, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "Chunks must continue at the offset")
+	assert.Contains(t, rec.Body.String(), `"offset":20`)
+
+	rec = u.patch(location, 20, second, map[string]string{HeaderUploadChecksum: sha1Checksum("something else")})
+	assert.Equal(t, StatusChecksumMismatch, rec.Code)
+
+	upload := u.status(location)
+	assert.Equal(t, int64(20), upload.Offset, "Corrupt chunks are dropped")
+	assert.Equal(t, model.UploadReceiving, upload.Status)
+	assert.Len(t, u.files("uploads/"+upload.ID), 1)
+
+	rec = u.patch(location, 20, second, map[string]string{HeaderUploadChecksum: sha1Checksum(second)})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Equal(t, strconv.Itoa(len(csv)), rec.Header().Get(HeaderUploadOffset))
+
+	assert.Equal(t, "Offsite", event.Name)
+	assert.Len(t, todos, 2)
+	require.NotNil(t, event.Source)
+	assert.Equal(t, "offsite.csv", event.Source.Filename)
+	assert.Equal(t, "text/csv", event.Source.ContentType)
+	assert.Equal(t, int64(len(csv)), event.Source.Size)
+	assert.Equal(t, "alice", event.Source.UploadedBy)
+
+	upload = u.status(location)
+	assert.Equal(t, model.UploadImported, upload.Status)
+	require.NotNil(t, upload.EventID)
+	assert.Equal(t, event.ID, *upload.EventID)
+	require.NotNil(t, upload.Result)
+	assert.Equal(t, "success", upload.Result.Message)
+	assert.Equal(t, float64(2), upload.Result.Details.(map[string]any)["rows_imported"])
+	assert.Empty(t, u.files("uploads/"+upload.ID), "Chunks are dropped once imported")
+	assert.Len(t, u.files("sources"), 1)
+
+	rec = u.patch(location, len(csv), "", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "Complete uploads take no more chunks")
+}
+
+func TestUploadAPI_FailedImport(t *testing.T) {
+	u := newUploadTest(t)
+
+	csv := "todo_name,note,priority\nBook venue,,urgent\n"
+	location := u.create(len(csv), metadata("name", "Offsite"))
+
+	rec := u.patch(location, 0, csv, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	upload := u.status(location)
+	assert.Equal(t, model.UploadFailed, upload.Status)
+	assert.Nil(t, upload.EventID)
+	require.NotNil(t, upload.Result)
+	assert.Equal(t, string(apperr.CodeValidation), upload.Result.Code)
+	assert.Empty(t, u.files("sources"), "Failed imports keep no file")
+	assert.Empty(t, u.files("uploads/"+upload.ID))
+	u.events.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadAPI_ImportFailsOnLastChunk(t *testing.T) {
+	u := newUploadTest(t)
+	u.events.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("database is gone"))
+
+	csv := "todo_name,note\nBook venue,Downtown\n"
+	location := u.create(len(csv), metadata("name", "Offsite"))
+
+	rec := u.patch(location, 0, csv, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	upload := u.status(location)
+	assert.Equal(t, model.UploadFailed, upload.Status)
+	require.NotNil(t, upload.Result)
+	assert.Equal(t, string(apperr.CodeInternal), upload.Result.Code)
+	assert.Empty(t, u.files("sources"), "Failed imports keep no file")
+
+	rec = u.do(http.MethodDelete, location, "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "Failed uploads can be deleted")
+}
+
+func TestUploadAPI_ImportAborted(t *testing.T) {
+	u := newUploadTest(t)
+
+	importing := make(chan struct{})
+	u.events.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			close(importing)
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(context.Canceled)
+
+	csv := "todo_name,note\nBook venue,Downtown\n"
+	location := u.create(len(csv), metadata("name", "Offsite"))
+
+	// The client giving up does not stop the import, the server does
+	ctx, disconnect := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPatch, location, strings.NewReader(csv)).WithContext(ctx)
+	req.Header.Set(HeaderTusResumable, TusVersion)
+	req.Header.Set(echo.HeaderContentType, MIMEOffsetOctetStream)
+	req.Header.Set(HeaderUploadOffset, "0")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.e.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	<-importing
+	disconnect()
+	select {
+	case <-done:
+		t.Fatal("The import stopped with the client")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	u.abort()
+	<-done
+
+	upload := u.status(location)
+	assert.Equal(t, model.UploadFailed, upload.Status, "Aborted imports are not left importing")
+	require.NotNil(t, upload.Result)
+	assert.Equal(t, string(apperr.CodeUnavailable), upload.Result.Code)
+
+	rec := u.do(http.MethodDelete, location, "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestUploadAPI_OutcomeNotRecorded(t *testing.T) {
+	u := newUploadTest(t)
+	u.events.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	u.uploads.finishErr = errors.New("database is gone")
+
+	csv := "todo_name,note\nBook venue,Downtown\n"
+	location := u.create(len(csv), metadata("name", "Offsite"))
+	id := strings.TrimPrefix(location, "/api/v1/uploads/")
+
+	rec := u.patch(location, 0, csv, nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	upload := u.status(location)
+	assert.Equal(t, model.UploadImporting, upload.Status)
+	assert.Len(t, u.files("uploads/"+id), 1)
+
+	rec = u.do(http.MethodDelete, location, "", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "Uploads may be importing still")
+
+	u.uploads.mu.Lock()
+	stuck := u.uploads.uploads[id]
+	stuck.UpdateDate = time.Now().Add(-uploadImportTimeout)
+	u.uploads.uploads[id] = stuck
+	u.uploads.mu.Unlock()
+
+	rec = u.do(http.MethodDelete, location, "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "Stuck uploads can be deleted")
+	assert.Empty(t, u.files("uploads/"+id))
+}
+
+func TestUploadAPI_Errors(t *testing.T) {
+	u := newUploadTest(t)
+
+	rec := u.do(http.MethodPost, "/api/v1/uploads", "", map[string]string{
+		HeaderTusResumable: "0.2.2",
+		HeaderUploadLength: "10",
+	})
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, TusVersion, rec.Header().Get(HeaderTusVersion))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"Missing length", map[string]string{}, http.StatusBadRequest},
+		{"Deferred length", map[string]string{HeaderUploadDeferLength: "1"}, http.StatusBadRequest},
+		{"Too large", map[string]string{HeaderUploadLength: "1025"}, http.StatusRequestEntityTooLarge},
+		{"Malformed metadata", map[string]string{HeaderUploadLength: "10", HeaderUploadMetadata: "name Offsite!"}, http.StatusBadRequest},
+		{"Invalid import options", map[string]string{HeaderUploadLength: "10", HeaderUploadMetadata: metadata("dry_run", "maybe")}, http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := u.do(http.MethodPost, "/api/v1/uploads", "", tc.headers)
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+		})
+	}
+
+	location := u.create(10, "")
+
+	rec = u.patch(location, 0, "todo_name\n", map[string]string{echo.HeaderContentType: "text/csv"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	rec = u.patch(location, 0, "todo_name\n", map[string]string{HeaderUploadChecksum: "md5 AAAA"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = u.patch(location, 0, "todo_name\nBook venue\n", nil)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, "Chunks may not go past the length")
+
+	rec = u.do(http.MethodHead, "/api/v1/uploads/missing", "", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = u.patch(location, 0, "todo", nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = u.do(http.MethodDelete, location, "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, u.files("uploads/"+strings.TrimPrefix(location, "/api/v1/uploads/")), "Terminated uploads drop their chunks")
+
+	rec = u.do(http.MethodHead, location, "", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestChunkReader(t *testing.T) {
+	store, err := blobstore.NewFS(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for key, chunk := range map[string]string{"a": "todo_name\n", "b": "", "c": "Book venue\n"} {
+		require.NoError(t, store.Put(ctx, key, strings.NewReader(chunk), int64(len(chunk))))
+	}
+
+	data, err := io.ReadAll(&chunkReader{ctx: ctx, store: store, keys: []string{"a", "b", "c"}})
+	require.NoError(t, err)
+	assert.Equal(t, "todo_name\nBook venue\n", string(data))
+
+	_, err = io.ReadAll(&chunkReader{ctx: ctx, store: store, keys: []string{"a", "gone"}})
+	assert.ErrorIs(t, err, blobstore.ErrNotFound)
+}
@@ -0,0 +1,182 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Code is a stable, machine-readable error identifier. Clients switch on it,
+// so existing values must never change meaning.
+type Code string
+
+const (
+	CodeBadRequest   Code = "bad_request"
+	CodeValidation   Code = "validation_failed"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeRateLimited  Code = "rate_limited"
+	CodeUnavailable  Code = "unavailable"
+	CodeInternal     Code = "internal"
+)
+
+var statusByCode = map[Code]int{
+	CodeBadRequest:   http.StatusBadRequest,
+	CodeValidation:   http.StatusBadRequest,
+	CodeUnauthorized: http.StatusUnauthorized,
+	CodeForbidden:    http.StatusForbidden,
+	CodeNotFound:     http.StatusNotFound,
+	CodeConflict:     http.StatusConflict,
+	CodeRateLimited:  http.StatusTooManyRequests,
+	CodeUnavailable:  http.StatusServiceUnavailable,
+	CodeInternal:     http.StatusInternalServerError,
+}
+
+func (c Code) Status() int {
+	status, ok := statusByCode[c]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+type Error struct {
+	Code    Code
+	Message string
+	Details any
+	Err     error
+
+	// Status overrides the HTTP status derived from Code.
+	Status int
+}
+
+func (e *Error) HTTPStatus() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	return e.Code.Status()
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+func New(code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func NotFound(message string) *Error {
+	return New(CodeNotFound, message)
+}
+
+func Validation(message string) *Error {
+	return New(CodeValidation, message)
+}
+
+func Conflict(message string) *Error {
+	return New(CodeConflict, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(CodeForbidden, message)
+}
+
+func RateLimited(message string) *Error {
+	return New(CodeRateLimited, message)
+}
+
+func Unavailable(message string) *Error {
+	return New(CodeUnavailable, message)
+}
+
+// Internal hides err from clients; it is only kept for logging.
+func Internal(err error) *Error {
+	return &Error{
+		Code:    CodeInternal,
+		Message: "internal server error",
+		Err:     err,
+	}
+}
+
+const pgUniqueViolation = "23505"
+
+// From converts any error into an *Error, recognising the storage and
+// framework errors that have a better mapping than internal.
+func From(err error) *Error {
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Error{Code: CodeNotFound, Message: "resource not found", Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.Is(err, gorm.ErrDuplicatedKey) || (errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation) {
+		return &Error{Code: CodeConflict, Message: "resource already exists", Err: err}
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return fromHTTPError(httpErr)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return &Error{Code: CodeBadRequest, Message: "request canceled", Err: err}
+	}
+
+	return Internal(err)
+}
+
+func fromHTTPError(httpErr *echo.HTTPError) *Error {
+
+	message := http.StatusText(httpErr.Code)
+	if m, ok := httpErr.Message.(string); ok && httpErr.Code < http.StatusInternalServerError {
+		message = m
+	}
+
+	switch {
+	case httpErr.Code == http.StatusNotFound:
+		return &Error{Code: CodeNotFound, Message: message, Err: httpErr}
+	case httpErr.Code == http.StatusUnauthorized:
+		return &Error{Code: CodeUnauthorized, Message: message, Err: httpErr}
+	case httpErr.Code == http.StatusForbidden:
+		return &Error{Code: CodeForbidden, Message: message, Err: httpErr}
+	case httpErr.Code == http.StatusConflict:
+		return &Error{Code: CodeConflict, Message: message, Err: httpErr}
+	case httpErr.Code == http.StatusTooManyRequests:
+		return &Error{Code: CodeRateLimited, Message: message, Err: httpErr}
+	case httpErr.Code < http.StatusInternalServerError:
+		return &Error{Code: CodeBadRequest, Message: message, Err: httpErr, Status: httpErr.Code}
+	}
+
+	return Internal(httpErr)
+}
@@ -0,0 +1,75 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCode_Status(t *testing.T) {
+	testCases := []struct {
+		code     Code
+		expected int
+	}{
+		{CodeBadRequest, http.StatusBadRequest},
+		{CodeValidation, http.StatusBadRequest},
+		{CodeUnauthorized, http.StatusUnauthorized},
+		{CodeForbidden, http.StatusForbidden},
+		{CodeNotFound, http.StatusNotFound},
+		{CodeConflict, http.StatusConflict},
+		{CodeRateLimited, http.StatusTooManyRequests},
+		{CodeUnavailable, http.StatusServiceUnavailable},
+		{CodeInternal, http.StatusInternalServerError},
+		{Code("unknown"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.code), func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.code.Status())
+		})
+	}
+}
+
+func TestInternal_HidesCause(t *testing.T) {
+	cause := errors.New("pq: password authentication failed")
+	err := Internal(cause)
+
+	assert.Equal(t, "internal server error", err.Message)
+	assert.ErrorIs(t, err, cause)
+}
+
+func TestFrom(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedCode   Code
+		expectedStatus int
+	}{
+		{"App error", fmt.Errorf("wrapped: %w", NotFound("event not found")), CodeNotFound, http.StatusNotFound},
+		{"Record not found", fmt.Errorf("get event: %w", gorm.ErrRecordNotFound), CodeNotFound, http.StatusNotFound},
+		{"Duplicated key", gorm.ErrDuplicatedKey, CodeConflict, http.StatusConflict},
+		{"Unique violation", &pgconn.PgError{Code: "23505"}, CodeConflict, http.StatusConflict},
+		{"Other postgres error", &pgconn.PgError{Code: "42P01"}, CodeInternal, http.StatusInternalServerError},
+		{"Echo not found", echo.ErrNotFound, CodeNotFound, http.StatusNotFound},
+		{"Echo method not allowed", echo.ErrMethodNotAllowed, CodeBadRequest, http.StatusMethodNotAllowed},
+		{"Echo entity too large", echo.ErrStatusRequestEntityTooLarge, CodeBadRequest, http.StatusRequestEntityTooLarge},
+		{"Echo internal", echo.NewHTTPError(http.StatusBadGateway, "upstream"), CodeInternal, http.StatusInternalServerError},
+		{"Canceled", context.Canceled, CodeBadRequest, http.StatusBadRequest},
+		{"Unknown", errors.New("boom"), CodeInternal, http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			appErr := From(tc.err)
+			assert.Equal(t, tc.expectedCode, appErr.Code)
+			assert.Equal(t, tc.expectedStatus, appErr.HTTPStatus())
+		})
+	}
+}
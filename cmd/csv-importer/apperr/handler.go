@@ -0,0 +1,88 @@
+package apperr
+
+import (
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/requestid"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document carrying the same stable
+// code and details as the regular error response.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// HTTPErrorHandler is installed as echo's central error handler. Handlers and
+// middlewares return errors and this turns them into responses: a
+// model.BaseResponse by default, or problem+json when the client asks for it.
+func HTTPErrorHandler(err error, c echo.Context) {
+
+	if c.Response().Committed {
+		return
+	}
+
+	appErr := From(err)
+	status := appErr.HTTPStatus()
+
+	if appErr.Code == CodeInternal {
+		slog.ErrorContext(c.Request().Context(), "internal error", "error", err.Error())
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else if wantsProblem(c.Request()) {
+		err = writeProblem(c, status, appErr)
+	} else {
+		err = c.JSON(
+			status,
+			model.BaseResponse{
+				Code:    string(appErr.Code),
+				Message: appErr.Message,
+				Details: appErr.Details,
+			},
+		)
+	}
+
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "writing error response", "error", err.Error())
+	}
+}
+
+func wantsProblem(r *http.Request) bool {
+	return strings.Contains(r.Header.Get(echo.HeaderAccept), MIMEProblemJSON)
+}
+
+func writeProblem(c echo.Context, status int, appErr *Error) error {
+
+	problem := Problem{
+		Type:      "urn:csv-importer:error:" + string(appErr.Code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    appErr.Message,
+		Instance:  c.Request().URL.Path,
+		Code:      appErr.Code,
+		Details:   appErr.Details,
+		RequestID: requestid.FromContext(c.Request().Context()),
+	}
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(status, MIMEProblemJSON, body)
+}
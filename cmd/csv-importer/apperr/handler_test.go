@@ -0,0 +1,88 @@
+package apperr
+
+import (
+	"csv-importer-backend/cmd/csv-importer/requestid"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(err error) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(requestid.Middleware())
+	e.GET("/fail", func(c echo.Context) error {
+		return err
+	})
+	return e
+}
+
+func TestHTTPErrorHandler_BaseResponse(t *testing.T) {
+	e := newTestServer(Validation("csvfile is required").WithDetails(map[string]string{"field": "csvfile"}))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+
+	var response struct {
+		Code    string            `json:"code"`
+		Message string            `json:"message"`
+		Details map[string]string `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "validation_failed", response.Code)
+	assert.Equal(t, "csvfile is required", response.Message)
+	assert.Equal(t, "csvfile", response.Details["field"])
+}
+
+func TestHTTPErrorHandler_InternalNotLeaked(t *testing.T) {
+	e := newTestServer(errors.New(`ERROR: relation "events" does not exist (SQLSTATE 42P01)`))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "relation")
+	assert.Contains(t, rec.Body.String(), `"code":"internal"`)
+}
+
+func TestHTTPErrorHandler_UnknownRoute(t *testing.T) {
+	e := newTestServer(nil)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"not_found"`)
+}
+
+func TestHTTPErrorHandler_ProblemJSON(t *testing.T) {
+	e := newTestServer(NotFound("event not found"))
+
+	req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+	req.Header.Set(echo.HeaderAccept, MIMEProblemJSON)
+	req.Header.Set(echo.HeaderXRequestID, "req-123")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "urn:csv-importer:error:not_found", problem.Type)
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "event not found", problem.Detail)
+	assert.Equal(t, "/fail", problem.Instance)
+	assert.Equal(t, CodeNotFound, problem.Code)
+	assert.Equal(t, "req-123", problem.RequestID)
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+type KeyStore map[string]Principal
+
+// ParseAPIKeys builds a KeyStore from entries of the form
+// "<key>:<tenant>:<subject>[:<role>]". Keys without a role are viewers.
+func ParseAPIKeys(entries []string) (KeyStore, error) {
+
+	keys := KeyStore{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 && len(parts) != 4 {
+			return nil, fmt.Errorf("invalid api key entry %q, expected <key>:<tenant>:<subject>[:<role>]", redact(parts[0]))
+		}
+
+		key, tenant, subject := parts[0], parts[1], parts[2]
+		if key == "" || tenant == "" || subject == "" {
+			return nil, fmt.Errorf("invalid api key entry %q, key, tenant and subject must not be empty", redact(key))
+		}
+
+		role := RoleViewer
+		if len(parts) == 4 {
+			role = Role(parts[3])
+		}
+
+		if !role.Valid() {
+			return nil, fmt.Errorf("invalid role %q for api key %q", role, redact(key))
+		}
+
+		if _, ok := keys[key]; ok {
+			return nil, fmt.Errorf("duplicate api key %q", redact(key))
+		}
+
+		keys[key] = Principal{
+			Subject:  subject,
+			TenantID: tenant,
+			Role:     role,
+		}
+	}
+
+	return keys, nil
+}
+
+func Middleware(keys KeyStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			key := extractKey(c.Request())
+			principal, ok := keys[key]
+			if key == "" || !ok {
+				return apperr.Unauthorized("missing or invalid api key")
+			}
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(NewContext(req.Context(), principal)))
+
+			return next(c)
+		}
+	}
+}
+
+func extractKey(r *http.Request) string {
+
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	authz := r.Header.Get(echo.HeaderAuthorization)
+	if token, ok := strings.CutPrefix(authz, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+func redact(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPIKeys_Valid(t *testing.T) {
+	keys, err := ParseAPIKeys([]string{"key-a:tenant-a:alice", " key-b:tenant-b:bob:admin ", ""})
+	require.NoError(t, err)
+
+	assert.Len(t, keys, 2)
+	assert.Equal(t, Principal{Subject: "alice", TenantID: "tenant-a", Role: RoleViewer}, keys["key-a"])
+	assert.Equal(t, Principal{Subject: "bob", TenantID: "tenant-b", Role: RoleAdmin}, keys["key-b"])
+}
+
+func TestParseAPIKeys_Invalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		entries []string
+	}{
+		{"Missing subject", []string{"key-a:tenant-a"}},
+		{"Empty tenant", []string{"key-a::alice"}},
+		{"Unknown role", []string{"key-a:tenant-a:alice:superuser"}},
+		{"Too many parts", []string{"key-a:tenant-a:alice:admin:extra"}},
+		{"Duplicate key", []string{"key-a:tenant-a:alice", "key-a:tenant-b:bob"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseAPIKeys(tc.entries)
+			assert.Error(t, err)
+			assert.NotContains(t, err.Error(), "key-a", "Error should not leak the full key")
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := KeyStore{
+		"secret-key": {Subject: "alice", TenantID: "tenant-a"},
+	}
+
+	testCases := []struct {
+		name           string
+		setHeader      func(r *http.Request)
+		expectedStatus int
+	}{
+		{
+			name:           "Missing key",
+			setHeader:      func(r *http.Request) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown key",
+			setHeader:      func(r *http.Request) { r.Header.Set(APIKeyHeader, "wrong") },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "API key header",
+			setHeader:      func(r *http.Request) { r.Header.Set(APIKeyHeader, "secret-key") },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Bearer token",
+			setHeader:      func(r *http.Request) { r.Header.Set(echo.HeaderAuthorization, "Bearer secret-key") },
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = apperr.HTTPErrorHandler
+			e.GET("/", func(c echo.Context) error {
+				p, ok := FromContext(c.Request().Context())
+				assert.True(t, ok)
+				assert.Equal(t, "tenant-a", p.TenantID)
+				return c.NoContent(http.StatusOK)
+			}, Middleware(keys))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.setHeader(req)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
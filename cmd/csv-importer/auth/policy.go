@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleImporter Role = "importer"
+	RoleEditor   Role = "editor"
+	RoleAdmin    Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleViewer, RoleImporter, RoleEditor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+type Action string
+
+const (
+	ActionReadEvents   Action = "event.read"
+	ActionImportCSV    Action = "event.import"
+	ActionChangeStatus Action = "event.status_change"
+	ActionDeleteEvent  Action = "event.delete"
+	ActionExportEvent  Action = "event.export"
+	ActionReadAudit    Action = "audit.read"
+	ActionReadSchemas  Action = "schema.read"
+	ActionReadMetrics  Action = "metrics.read"
+)
+
+const (
+	ReasonUnauthenticated = "unauthenticated"
+	ReasonRoleNotAllowed  = "role_not_allowed"
+)
+
+// Policy maps every action to the roles allowed to perform it. Actions that
+// are missing from the table are denied for everyone.
+type Policy map[Action][]Role
+
+var DefaultPolicy = Policy{
+	ActionReadEvents:   {RoleViewer, RoleImporter, RoleEditor, RoleAdmin},
+	ActionImportCSV:    {RoleImporter, RoleEditor, RoleAdmin},
+	ActionExportEvent:  {RoleImporter, RoleEditor, RoleAdmin},
+	ActionChangeStatus: {RoleEditor, RoleAdmin},
+	ActionDeleteEvent:  {RoleAdmin},
+	ActionReadAudit:    {RoleAdmin},
+	ActionReadSchemas:  {RoleViewer, RoleImporter, RoleEditor, RoleAdmin},
+	// Metrics count the events of every tenant
+	ActionReadMetrics: {RoleAdmin},
+}
+
+func (p Policy) Allows(role Role, action Action) bool {
+	for _, allowed := range p[action] {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+type Denial struct {
+	Reason string `json:"reason"`
+	Action Action `json:"action"`
+	Role   Role   `json:"role,omitempty"`
+}
+
+func Authorize(policy Policy, action Action) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			principal, ok := FromContext(c.Request().Context())
+			if !ok {
+				return apperr.Unauthorized("authentication required").
+					WithDetails(Denial{
+						Reason: ReasonUnauthenticated,
+						Action: action,
+					})
+			}
+
+			if !policy.Allows(principal.Role, action) {
+				return apperr.Forbidden("forbidden").
+					WithDetails(Denial{
+						Reason: ReasonRoleNotAllowed,
+						Action: action,
+						Role:   principal.Role,
+					})
+			}
+
+			return next(c)
+		}
+	}
+}
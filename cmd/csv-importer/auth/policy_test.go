@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	testCases := []struct {
+		role    Role
+		allowed []Action
+	}{
+		{RoleViewer, []Action{ActionReadEvents, ActionReadSchemas}},
+		{RoleImporter, []Action{ActionReadEvents, ActionReadSchemas, ActionImportCSV, ActionExportEvent}},
+		{RoleEditor, []Action{ActionReadEvents, ActionReadSchemas, ActionImportCSV, ActionExportEvent, ActionChangeStatus}},
+		{RoleAdmin, []Action{ActionReadEvents, ActionReadSchemas, ActionImportCSV, ActionExportEvent, ActionChangeStatus, ActionDeleteEvent, ActionReadAudit, ActionReadMetrics}},
+	}
+
+	actions := []Action{ActionReadEvents, ActionReadSchemas, ActionImportCSV, ActionExportEvent, ActionChangeStatus, ActionDeleteEvent, ActionReadAudit, ActionReadMetrics}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.role), func(t *testing.T) {
+			for _, action := range actions {
+				assert.Equal(t, contains(tc.allowed, action), DefaultPolicy.Allows(tc.role, action), "action %s", action)
+			}
+		})
+	}
+}
+
+func TestPolicy_UnknownActionDenied(t *testing.T) {
+	assert.False(t, DefaultPolicy.Allows(RoleAdmin, Action("event.unknown")))
+}
+
+func TestAuthorize(t *testing.T) {
+	testCases := []struct {
+		name           string
+		principal      *Principal
+		expectedStatus int
+		expectedReason string
+	}{
+		{
+			name:           "No principal",
+			expectedStatus: http.StatusUnauthorized,
+			expectedReason: ReasonUnauthenticated,
+		},
+		{
+			name:           "Role not allowed",
+			principal:      &Principal{Subject: "alice", TenantID: "tenant-a", Role: RoleViewer},
+			expectedStatus: http.StatusForbidden,
+			expectedReason: ReasonRoleNotAllowed,
+		},
+		{
+			name:           "Role allowed",
+			principal:      &Principal{Subject: "alice", TenantID: "tenant-a", Role: RoleEditor},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = apperr.HTTPErrorHandler
+			e.PATCH("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, Authorize(DefaultPolicy, ActionChangeStatus))
+
+			req := httptest.NewRequest(http.MethodPatch, "/", nil)
+			if tc.principal != nil {
+				req = req.WithContext(NewContext(req.Context(), *tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			if tc.expectedReason != "" {
+				var response struct {
+					Details Denial `json:"details"`
+				}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, tc.expectedReason, response.Details.Reason)
+				assert.Equal(t, ActionChangeStatus, response.Details.Action)
+			}
+		})
+	}
+}
+
+func contains(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package auth
+
+import "context"
+
+const DefaultTenant = "default"
+
+type Principal struct {
+	Subject  string `json:"subject"`
+	TenantID string `json:"tenant_id"`
+	Role     Role   `json:"role"`
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// TenantID returns the tenant of the principal carried by ctx. Contexts
+// without a principal (CLI jobs, tests) operate on the default tenant; HTTP
+// requests never reach the repository without one because Middleware rejects
+// them first.
+func TenantID(ctx context.Context) string {
+	p, ok := FromContext(ctx)
+	if !ok || p.TenantID == "" {
+		return DefaultTenant
+	}
+	return p.TenantID
+}
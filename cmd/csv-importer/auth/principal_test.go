@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantID_WithPrincipal(t *testing.T) {
+	ctx := NewContext(context.Background(), Principal{Subject: "alice", TenantID: "tenant-a"})
+	assert.Equal(t, "tenant-a", TenantID(ctx))
+}
+
+func TestTenantID_DefaultsWithoutPrincipal(t *testing.T) {
+	assert.Equal(t, DefaultTenant, TenantID(context.Background()))
+
+	ctx := NewContext(context.Background(), Principal{Subject: "alice"})
+	assert.Equal(t, DefaultTenant, TenantID(ctx))
+}
@@ -0,0 +1,59 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStore runs the behaviour every Store shares.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "sources/one.csv", strings.NewReader("a,b\n1,2\n"), 8))
+
+	blob, err := store.Get(ctx, "sources/one.csv")
+	require.NoError(t, err)
+	data, err := io.ReadAll(blob)
+	require.NoError(t, err)
+	require.NoError(t, blob.Close())
+	assert.Equal(t, "a,b\n1,2\n", string(data))
+
+	require.NoError(t, store.Put(ctx, "sources/one.csv", strings.NewReader("c\n"), 2), "Blobs are replaced")
+	blob, err = store.Get(ctx, "sources/one.csv")
+	require.NoError(t, err)
+	data, err = io.ReadAll(blob)
+	require.NoError(t, err)
+	require.NoError(t, blob.Close())
+	assert.Equal(t, "c\n", string(data))
+
+	_, err = store.Get(ctx, "sources/missing.csv")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Delete(ctx, "sources/one.csv"))
+	_, err = store.Get(ctx, "sources/one.csv")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, store.Delete(ctx, "sources/one.csv"), "Deleting twice is no error")
+}
+
+func TestFS(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blobs")
+	store, err := NewFS(dir)
+	require.NoError(t, err)
+
+	testStore(t, store)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "sources"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "No temporary files are left behind")
+
+	for _, key := range []string{"../outside", "/etc/passwd", ""} {
+		assert.Error(t, store.Put(context.Background(), key, strings.NewReader("x"), 1), key)
+	}
+}
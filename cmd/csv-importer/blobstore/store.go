@@ -0,0 +1,30 @@
+// Package blobstore keeps the raw files of uploads, on the local filesystem
+// or in an S3-compatible object store.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Backends.
+const (
+	BackendNone = "none"
+	BackendFS   = "fs"
+	BackendS3   = "s3"
+)
+
+var Backends = []string{BackendFS, BackendS3, BackendNone}
+
+// Store keeps blobs by key. Keys are slash-separated relative paths.
+type Store interface {
+	// Put stores the size bytes of r under key, replacing any blob there.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the blob under key, ErrNotFound when there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Missing blobs are no error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/importer"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/transform"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// cliSubject is recorded as the actor of writes made from the command line.
+const cliSubject = "cli"
+
+type eventStore interface {
+	importer.IEventRepo
+	importer.IRecordRepo
+	ListEvents(ctx context.Context) ([]model.Event, error)
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	tenant := fs.String("tenant", auth.DefaultTenant, "tenant the command acts on")
+
+	return fs, tenant
+}
+
+// cliContext runs a command as an admin of tenant, the command line is only
+// available to operators with database access anyway.
+func cliContext(ctx context.Context, tenant string) context.Context {
+	return auth.NewContext(ctx, auth.Principal{
+		Subject:  cliSubject,
+		TenantID: tenant,
+		Role:     auth.RoleAdmin,
+	})
+}
+
+func newImporter(repo eventStore, schemas *schema.Registry) *importer.Importer {
+
+	imp := importer.New(repo)
+	imp.SetSchemas(schemas, repo)
+
+	return imp
+}
+
+func runImport(ctx context.Context, repo eventStore, schemas *schema.Registry, args []string, out io.Writer) error {
+
+	fs, tenant := newFlagSet("import")
+	name := fs.String("name", "", "event name")
+	file := fs.String("file", "", "CSV to import, - for stdin")
+	importType := fs.String("type", model.ImportTypeTodos, "import type of the CSV")
+	keepCustomFields := fs.Bool("keep-custom-fields", false, "store columns without a todo field as custom fields")
+	transforms := fs.String("transforms", "", "JSON or YAML file with transform rules")
+	dryRun := fs.Bool("dry-run", false, "validate and transform without storing")
+	mode := fs.String("mode", importer.ModeStrict, "strict rejects files with invalid rows, partial imports the valid rows")
+	duplicateColumns := fs.String("duplicate-columns", "", "comma-separated columns whose values identify duplicate rows")
+	duplicateMatch := fs.String("duplicate-match", importer.MatchExact, "compare duplicate keys exact or normalized")
+	duplicatePolicy := fs.String("duplicate-policy", importer.DuplicateSkip, "skip, keep or fail on duplicate rows")
+	acrossEvents := fs.Bool("duplicates-across-events", false, "also find duplicates of rows of earlier events")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" || *file == "" {
+		return errors.New("import: --name and --file are required")
+	}
+
+	opts := importer.Options{
+		Type:             *importType,
+		KeepCustomFields: *keepCustomFields,
+		DryRun:           *dryRun,
+		Mode:             *mode,
+		Duplicates: importer.DuplicateOptions{
+			Match:        *duplicateMatch,
+			Policy:       *duplicatePolicy,
+			AcrossEvents: *acrossEvents,
+		},
+	}
+
+	for _, c := range strings.Split(*duplicateColumns, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			opts.Duplicates.Columns = append(opts.Duplicates.Columns, c)
+		}
+	}
+
+	if *transforms != "" {
+		data, err := os.ReadFile(*transforms)
+		if err != nil {
+			return err
+		}
+
+		opts.Transforms, err = transform.Parse(data)
+		if err != nil {
+			return fmt.Errorf("import: %s: %w", *transforms, err)
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	result, err := newImporter(repo, schemas).Import(cliContext(ctx, *tenant), *name, in, opts)
+	if err != nil {
+		return err
+	}
+
+	if preview := result.Preview; preview != nil {
+		fmt.Fprintf(out, "would import %d %s\n", preview.Rows, preview.ImportType)
+		if preview.RowsRejected > 0 {
+			fmt.Fprintf(out, "would reject %d rows\n", preview.RowsRejected)
+		}
+		if preview.RowsDuplicated > 0 {
+			fmt.Fprintf(out, "%d duplicate rows\n", preview.RowsDuplicated)
+		}
+		for n, applied := range preview.Transforms {
+			fmt.Fprintf(out, "transform %d (%s): %d cells changed\n", n+1, applied.Op, applied.CellsChanged)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(out, "imported %d %s into event %s\n", result.RowsImported, result.Event.ImportType, result.Event.ID)
+	if result.RowsRejected > 0 {
+		fmt.Fprintf(out, "rejected %d rows\n", result.RowsRejected)
+	}
+	if result.RowsDuplicated > 0 {
+		fmt.Fprintf(out, "%d duplicate rows, %d skipped\n", result.RowsDuplicated, result.RowsSkipped)
+	}
+
+	return nil
+}
+
+func runExport(ctx context.Context, repo eventStore, schemas *schema.Registry, args []string, out io.Writer) error {
+
+	fs, tenant := newFlagSet("export")
+	eventID := fs.String("event", "", "event ID")
+	file := fs.String("out", "-", "destination file, - for stdout")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *eventID == "" {
+		return errors.New("export: --event is required")
+	}
+
+	ctx = cliContext(ctx, *tenant)
+
+	// An unknown event leaves an existing file alone
+	_, err = repo.GetEvent(ctx, *eventID)
+	if err != nil {
+		return err
+	}
+
+	export := func(w io.Writer) error {
+		_, err := newImporter(repo, schemas).Export(ctx, *eventID, w)
+		return err
+	}
+
+	if *file == "-" {
+		return export(out)
+	}
+
+	return replaceFile(*file, export)
+}
+
+// replaceFile replaces path with what write produces, or leaves it untouched
+// when write fails, by writing to a temporary file next to it first.
+func replaceFile(path string, write func(w io.Writer) error) error {
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	err = f.Chmod(0o644)
+	if err == nil {
+		err = write(f)
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+func runEvents(ctx context.Context, repo eventStore, args []string, out io.Writer) error {
+
+	if len(args) == 0 || args[0] != "list" {
+		return errors.New("usage: csv-importer events list [--tenant TENANT]")
+	}
+
+	fs, tenant := newFlagSet("events list")
+
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	events, err := repo.ListEvents(cliContext(ctx, *tenant))
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tCREATED")
+	for _, event := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", event.ID, event.Name, event.Status, event.CreateDate.UTC().Format(time.RFC3339))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryStore keeps events per tenant so that CLI tests can check the tenant
+// and actor the commands run as.
+type memoryStore struct {
+	events  map[string]model.Event
+	todos   map[string][]model.TodoEvent
+	records map[string][]map[string]any
+	actor   string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		events:  map[string]model.Event{},
+		todos:   map[string][]model.TodoEvent{},
+		records: map[string][]map[string]any{},
+	}
+}
+
+func (s *memoryStore) ListEvents(ctx context.Context) ([]model.Event, error) {
+	var events []model.Event
+	for _, event := range s.events {
+		if event.TenantID == auth.TenantID(ctx) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStore) GetEvent(ctx context.Context, id string) (*model.Event, error) {
+	event, ok := s.events[id]
+	if !ok || event.TenantID != auth.TenantID(ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &event, nil
+}
+
+func (s *memoryStore) CreateEvent(ctx context.Context, event model.Event, todos ...model.TodoEvent) error {
+	principal, _ := auth.FromContext(ctx)
+	s.actor = principal.Subject
+	s.events[event.ID] = event
+	s.todos[event.ID] = todos
+	return nil
+}
+
+func (s *memoryStore) ListTodos(ctx context.Context, eventID string, filter model.TodoFilter) ([]model.TodoEvent, error) {
+	return s.todos[eventID], nil
+}
+
+func (s *memoryStore) CreateEventRecords(ctx context.Context, event model.Event, table string, records []map[string]any) error {
+	s.events[event.ID] = event
+	s.records[table+"/"+event.ID] = records
+	return nil
+}
+
+func (s *memoryStore) ListRecords(ctx context.Context, eventID string, table string) ([]map[string]any, error) {
+	return s.records[table+"/"+eventID], nil
+}
+
+func TestCLI_ImportExportRoundTrip(t *testing.T) {
+	store := newMemoryStore()
+	dir := t.TempDir()
+
+	in := filepath.Join(dir, "todos.csv")
+	content := "todo_name,note\nBuy groceries,\"Milk, bread\"\nCall dentist,Tuesday\n"
+	require.NoError(t, os.WriteFile(in, []byte(content), 0o600))
+
+	var out bytes.Buffer
+	err := runImport(context.Background(), store, nil, []string{"--name", "Weekly", "--file", in, "--tenant", "tenant-a"}, &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "imported 2 todos into event ")
+	assert.Equal(t, cliSubject, store.actor)
+
+	require.Len(t, store.events, 1)
+	var eventID string
+	for id, event := range store.events {
+		eventID = id
+		assert.Equal(t, "tenant-a", event.TenantID)
+		assert.Equal(t, "Weekly", event.Name)
+	}
+
+	exported := filepath.Join(dir, "export.csv")
+	err = runExport(context.Background(), store, nil, []string{"--event", eventID, "--out", exported, "--tenant", "tenant-a"}, &out)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(exported)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	// Events stay invisible to other tenants
+	err = runExport(context.Background(), store, nil, []string{"--event", eventID}, &out)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestCLI_ExportKeepsFileOnError(t *testing.T) {
+	store := newMemoryStore()
+	dir := t.TempDir()
+
+	exported := filepath.Join(dir, "export.csv")
+	require.NoError(t, os.WriteFile(exported, []byte("previous"), 0o600))
+
+	var out bytes.Buffer
+	err := runExport(context.Background(), store, nil, []string{"--event", "missing", "--out", exported}, &out)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	data, err := os.ReadFile(exported)
+	require.NoError(t, err)
+	assert.Equal(t, "previous", string(data), "Unknown events leave the file alone")
+
+	err = replaceFile(exported, func(w io.Writer) error {
+		_, err := io.WriteString(w, "half")
+		require.NoError(t, err)
+		return errors.New("listing failed")
+	})
+	assert.EqualError(t, err, "listing failed")
+
+	data, err = os.ReadFile(exported)
+	require.NoError(t, err)
+	assert.Equal(t, "previous", string(data), "Failed exports leave the file alone")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "No temporary files are left behind")
+}
+
+func TestCLI_ImportKeepsCustomFields(t *testing.T) {
+	store := newMemoryStore()
+	dir := t.TempDir()
+
+	in := filepath.Join(dir, "todos.csv")
+	content := "todo_name,note,cost,region\nBook venue,Downtown,120,north\nSend invites,,,\n"
+	require.NoError(t, os.WriteFile(in, []byte(content), 0o600))
+
+	var out bytes.Buffer
+	err := runImport(context.Background(), store, nil, []string{"--name", "Offsite", "--file", in, "--keep-custom-fields"}, &out)
+	require.NoError(t, err)
+
+	var eventID string
+	for id := range store.events {
+		eventID = id
+	}
+
+	exported := filepath.Join(dir, "export.csv")
+	err = runExport(context.Background(), store, nil, []string{"--event", eventID, "--out", exported}, &out)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(exported)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data), "Nothing from the file is lost")
+}
+
+func TestCLI_ImportType(t *testing.T) {
+	store := newMemoryStore()
+	dir := t.TempDir()
+
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+
+	in := filepath.Join(dir, "attendees.csv")
+	content := "name,email,company,ticket_type,checked_in,registered_on\nAda,ada@example.com,,vip,true,2025-03-01\n"
+	require.NoError(t, os.WriteFile(in, []byte(content), 0o600))
+
+	var out bytes.Buffer
+	err = runImport(context.Background(), store, schemas, []string{"--name", "Summit", "--file", in, "--type", "attendees"}, &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "imported 1 attendees into event ")
+
+	var eventID string
+	for id := range store.events {
+		eventID = id
+	}
+
+	exported := filepath.Join(dir, "export.csv")
+	err = runExport(context.Background(), store, schemas, []string{"--event", eventID, "--out", exported}, &out)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(exported)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	err = runImport(context.Background(), store, schemas, []string{"--name", "Summit", "--file", in, "--type", "inventory"}, &out)
+	assert.ErrorContains(t, err, "unknown import type")
+}
+
+func TestCLI_ImportDryRun(t *testing.T) {
+	store := newMemoryStore()
+	dir := t.TempDir()
+
+	in := filepath.Join(dir, "todos.csv")
+	require.NoError(t, os.WriteFile(in, []byte("todo_name,note\n buy milk ,\n"), 0o600))
+
+	rules := filepath.Join(dir, "transforms.yaml")
+	require.NoError(t, os.WriteFile(rules, []byte("- op: trim\n  column: todo_name\n- op: default\n  column: note\n  value: none\n"), 0o600))
+
+	var out bytes.Buffer
+	err := runImport(context.Background(), store, nil, []string{"--name", "Weekly", "--file", in, "--transforms", rules, "--dry-run"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, "would import 1 todos\ntransform 1 (trim): 1 cells changed\ntransform 2 (default): 1 cells changed\n", out.String())
+	assert.Empty(t, store.events)
+
+	require.NoError(t, os.WriteFile(rules, []byte("- op: shout\n"), 0o600))
+	err = runImport(context.Background(), store, nil, []string{"--name", "Weekly", "--file", in, "--transforms", rules}, &out)
+	assert.ErrorContains(t, err, "transform 1 (shout): op must be one of")
+}
+
+func TestCLI_ImportDuplicates(t *testing.T) {
+	store := newMemoryStore()
+	dir := t.TempDir()
+
+	in := filepath.Join(dir, "todos.csv")
+	require.NoError(t, os.WriteFile(in, []byte("todo_name,note\nBook venue,\nBOOK VENUE,\nSend invites,\n"), 0o600))
+
+	var out bytes.Buffer
+	err := runImport(context.Background(), store, nil, []string{"--name", "Offsite", "--file", in, "--duplicate-columns", "todo_name", "--duplicate-match", "normalized"}, &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "imported 2 todos into event ")
+	assert.Contains(t, out.String(), "1 duplicate rows, 1 skipped\n")
+
+	err = runImport(context.Background(), store, nil, []string{"--name", "Offsite", "--file", in, "--duplicate-columns", "todo_name", "--duplicate-policy", "merge"}, &out)
+	assert.ErrorContains(t, err, "duplicate policy must be one of skip, keep, fail")
+}
+
+func TestCLI_ImportPartial(t *testing.T) {
+	store := newMemoryStore()
+	dir := t.TempDir()
+
+	in := filepath.Join(dir, "todos.csv")
+	require.NoError(t, os.WriteFile(in, []byte("todo_name,note,priority\nBook venue,,high\nSend invites,,urgent\n"), 0o600))
+
+	var out bytes.Buffer
+	err := runImport(context.Background(), store, nil, []string{"--name", "Offsite", "--file", in, "--mode", "partial"}, &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "imported 1 todos into event ")
+	assert.Contains(t, out.String(), "rejected 1 rows\n")
+
+	for _, event := range store.events {
+		require.Len(t, event.Rejected, 1)
+		assert.Equal(t, "Send invites,,urgent", event.Rejected[0].Line)
+	}
+
+	err = runImport(context.Background(), store, nil, []string{"--name", "Offsite", "--file", in}, &out)
+	assert.ErrorContains(t, err, "csvfile has invalid rows")
+}
+
+func TestCLI_ImportRequiresFlags(t *testing.T) {
+	store := newMemoryStore()
+
+	var out bytes.Buffer
+	err := runImport(context.Background(), store, nil, []string{"--name", "Weekly"}, &out)
+
+	assert.Error(t, err)
+	assert.Empty(t, store.events)
+}
+
+func TestCLI_EventsList(t *testing.T) {
+	store := newMemoryStore()
+	created := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	store.events["event-1"] = model.Event{ID: "event-1", TenantID: auth.DefaultTenant, Name: "Launch", Status: model.Start, CreateDate: created}
+	store.events["event-2"] = model.Event{ID: "event-2", TenantID: "tenant-b", Name: "Other", Status: model.Created, CreateDate: created}
+
+	var out bytes.Buffer
+	err := runEvents(context.Background(), store, []string{"list"}, &out)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"ID", "NAME", "STATUS", "CREATED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"event-1", "Launch", "start", "2025-03-01T09:00:00Z"}, strings.Fields(lines[1]))
+
+	err = runEvents(context.Background(), store, []string{"delete"}, &out)
+	assert.Error(t, err)
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"frobnicate"}, &out)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown command "frobnicate"`)
+}
+
+func TestRun_SQLiteDriver(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CSV_IMPORTER_DB_DRIVER", "sqlite")
+	t.Setenv("CSV_IMPORTER_DB_PATH", filepath.Join(dir, "events.db"))
+
+	in := filepath.Join(dir, "todos.csv")
+	require.NoError(t, os.WriteFile(in, []byte("todo_name,note\nBook venue,Downtown\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, run(context.Background(), []string{"migrate", "up"}, &out))
+	require.NoError(t, run(context.Background(), []string{"import", "--name", "Offsite", "--file", in}, &out))
+
+	out.Reset()
+	require.NoError(t, run(context.Background(), []string{"events", "list"}, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "Offsite")
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/logging"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "CSV_IMPORTER"
+
+// EnvCfg is read from CSV_IMPORTER_* variables. Every setting can also be
+// given in the YAML file named by CSV_IMPORTER_CONFIG_FILE, keyed by the
+// lower-case variable name without prefix (db_host, api_keys, ...);
+// variables take precedence over the file.
+//
+// Fields tagged redact never appear in Summary.
+type EnvCfg struct {
+	ConfigFile string `envconfig:"CONFIG_FILE"`
+
+	ListenAddr      string        `envconfig:"LISTEN_ADDR" default:":8080"`
+	ReadTimeout     time.Duration `envconfig:"READ_TIMEOUT" default:"60s"`
+	WriteTimeout    time.Duration `envconfig:"WRITE_TIMEOUT" default:"60s"`
+	IdleTimeout     time.Duration `envconfig:"IDLE_TIMEOUT" default:"120s"`
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+	TLSCertFile     string        `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile      string        `envconfig:"TLS_KEY_FILE"`
+
+	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
+	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
+
+	TraceExporter    string  `envconfig:"TRACE_EXPORTER" default:"none"`
+	TraceFile        string  `envconfig:"TRACE_FILE"`
+	TraceSampleRatio float64 `envconfig:"TRACE_SAMPLE_RATIO" default:"1"`
+
+	DBDriver             string        `envconfig:"DB_DRIVER" default:"postgres"`
+	DBPath               string        `envconfig:"DB_PATH" default:"csv-importer.db"`
+	DatabaseURL          string        `envconfig:"DATABASE_URL" redact:"url"`
+	DatabaseURLFile      string        `envconfig:"DATABASE_URL_FILE"`
+	DBHost               string        `envconfig:"DB_HOST"`
+	DBPort               int           `envconfig:"DB_PORT" default:"5432"`
+	DBUser               string        `envconfig:"DB_USER"`
+	DBPassword           string        `envconfig:"DB_PASSWORD" redact:"true"`
+	DBPasswordFile       string        `envconfig:"DB_PASSWORD_FILE"`
+	DBName               string        `envconfig:"DB_NAME"`
+	DBSSLMode            string        `envconfig:"DB_SSLMODE" default:"disable"`
+	DBSSLRootCert        string        `envconfig:"DB_SSLROOTCERT"`
+	DBMaxOpenConns       int           `envconfig:"DB_MAX_OPEN_CONNS" default:"20"`
+	DBMaxIdleConns       int           `envconfig:"DB_MAX_IDLE_CONNS" default:"5"`
+	DBConnMaxLifetime    time.Duration `envconfig:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	DBStatementTimeout   time.Duration `envconfig:"DB_STATEMENT_TIMEOUT"`
+	DBSlowQueryThreshold time.Duration `envconfig:"DB_SLOW_QUERY_THRESHOLD" default:"200ms"`
+
+	APIKeys     []string `envconfig:"API_KEYS" redact:"true"`
+	APIKeysFile string   `envconfig:"API_KEYS_FILE"`
+
+	RateLimitUploadsPerMinute      int `envconfig:"RATE_LIMIT_UPLOADS_PER_MINUTE" default:"10"`
+	RateLimitUploadBurst           int `envconfig:"RATE_LIMIT_UPLOAD_BURST" default:"5"`
+	RateLimitReadsPerMinute        int `envconfig:"RATE_LIMIT_READS_PER_MINUTE" default:"600"`
+	RateLimitReadBurst             int `envconfig:"RATE_LIMIT_READ_BURST" default:"100"`
+	RateLimitAuthFailuresPerMinute int `envconfig:"RATE_LIMIT_AUTH_FAILURES_PER_MINUTE" default:"10"`
+	RateLimitAuthFailureBurst      int `envconfig:"RATE_LIMIT_AUTH_FAILURE_BURST" default:"20"`
+	MaxConcurrentImports           int `envconfig:"MAX_CONCURRENT_IMPORTS" default:"4"`
+
+	// TrustedProxies are the CIDR ranges of reverse proxies whose
+	// X-Forwarded-For is believed. Without any, clients are identified by the
+	// address they connect from.
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+
+	MigrateOnStart bool `envconfig:"MIGRATE_ON_START" default:"true"`
+
+	// SchemaDir holds .json and .yaml definitions of import types in
+	// addition to the built-in ones.
+	SchemaDir string `envconfig:"SCHEMA_DIR"`
+
+	// BlobStore keeps the uploaded file of every import.
+	BlobStore             string `envconfig:"BLOB_STORE" default:"fs"`
+	BlobDir               string `envconfig:"BLOB_DIR" default:"uploads"`
+	S3Endpoint            string `envconfig:"S3_ENDPOINT"`
+	S3Region              string `envconfig:"S3_REGION" default:"us-east-1"`
+	S3Bucket              string `envconfig:"S3_BUCKET"`
+	S3AccessKeyID         string `envconfig:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey     string `envconfig:"S3_SECRET_ACCESS_KEY" redact:"true"`
+	S3SecretAccessKeyFile string `envconfig:"S3_SECRET_ACCESS_KEY_FILE"`
+
+	// UploadMaxSize caps the files sent in chunks to /api/v1/uploads.
+	UploadMaxSize int64 `envconfig:"UPLOAD_MAX_SIZE" default:"268435456"`
+}
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// loadEnvCfg reads the environment, then the config file and secret files it
+// points to, and validates the result.
+func loadEnvCfg(cfg *EnvCfg) error {
+
+	err := envconfig.Process(envPrefix, cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ConfigFile != "" {
+		err = cfg.applyFile(cfg.ConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = cfg.readSecretFiles()
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// applyFile sets every setting of the YAML file at path whose variable is
+// not set in the environment.
+func (cfg *EnvCfg) applyFile(path string) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var settings map[string]yaml.Node
+	err = yaml.Unmarshal(data, &settings)
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		fields[strings.ToLower(t.Field(i).Tag.Get("envconfig"))] = i
+	}
+
+	for key, node := range settings {
+		i, ok := fields[key]
+		if !ok || key == "config_file" {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+
+		if envIsSet(strings.ToUpper(key)) {
+			continue
+		}
+
+		err = node.Decode(v.Field(i).Addr().Interface())
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+
+	return nil
+}
+
+// envIsSet mirrors envconfig, which also accepts the name without prefix.
+func envIsSet(name string) bool {
+
+	_, prefixed := os.LookupEnv(envPrefix + "_" + name)
+	_, plain := os.LookupEnv(name)
+
+	return prefixed || plain
+}
+
+// readSecretFiles resolves the _FILE variants used with Docker and
+// Kubernetes secrets.
+func (cfg *EnvCfg) readSecretFiles() error {
+
+	secrets := []struct {
+		name  string
+		file  string
+		value *string
+	}{
+		{"DB_PASSWORD", cfg.DBPasswordFile, &cfg.DBPassword},
+		{"DATABASE_URL", cfg.DatabaseURLFile, &cfg.DatabaseURL},
+		{"S3_SECRET_ACCESS_KEY", cfg.S3SecretAccessKeyFile, &cfg.S3SecretAccessKey},
+	}
+
+	for _, secret := range secrets {
+		if secret.file == "" {
+			continue
+		}
+
+		if *secret.value != "" {
+			return fmt.Errorf("set either %s_%s or %s_%s_FILE, not both", envPrefix, secret.name, envPrefix, secret.name)
+		}
+
+		data, err := os.ReadFile(secret.file)
+		if err != nil {
+			return fmt.Errorf("read %s_%s_FILE: %w", envPrefix, secret.name, err)
+		}
+
+		*secret.value = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if cfg.APIKeysFile != "" {
+		if len(cfg.APIKeys) > 0 {
+			return fmt.Errorf("set either %s_API_KEYS or %s_API_KEYS_FILE, not both", envPrefix, envPrefix)
+		}
+
+		data, err := os.ReadFile(cfg.APIKeysFile)
+		if err != nil {
+			return fmt.Errorf("read %s_API_KEYS_FILE: %w", envPrefix, err)
+		}
+
+		// One entry per line or comma-separated, # starts a comment line
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			for _, entry := range strings.Split(line, ",") {
+				entry = strings.TrimSpace(entry)
+				if entry != "" {
+					cfg.APIKeys = append(cfg.APIKeys, entry)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (cfg EnvCfg) Validate() error {
+
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.ListenAddr); err != nil {
+		invalid("invalid %s_LISTEN_ADDR %q: %v", envPrefix, cfg.ListenAddr, err)
+	}
+
+	if cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 || cfg.IdleTimeout < 0 {
+		invalid("server timeouts must not be negative")
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		invalid("%s_SHUTDOWN_TIMEOUT must be positive", envPrefix)
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		invalid("%s_TLS_CERT_FILE and %s_TLS_KEY_FILE must be set together", envPrefix, envPrefix)
+	}
+
+	for _, file := range []string{cfg.TLSCertFile, cfg.TLSKeyFile} {
+		if _, err := os.Stat(file); file != "" && err != nil {
+			invalid("tls: %v", err)
+		}
+	}
+
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		invalid("%s_LOG_LEVEL: %v", envPrefix, err)
+	}
+
+	if cfg.LogFormat != logging.FormatJSON && cfg.LogFormat != logging.FormatText {
+		invalid("%s_LOG_FORMAT must be %q or %q", envPrefix, logging.FormatJSON, logging.FormatText)
+	}
+
+	if !slices.Contains(tracing.Exporters, cfg.TraceExporter) {
+		invalid("%s_TRACE_EXPORTER must be one of %s", envPrefix, strings.Join(tracing.Exporters, ", "))
+	}
+
+	if cfg.TraceExporter == tracing.ExporterFile && cfg.TraceFile == "" {
+		invalid("required key %s_TRACE_FILE missing value", envPrefix)
+	}
+
+	if cfg.TraceSampleRatio < 0 || cfg.TraceSampleRatio > 1 {
+		invalid("%s_TRACE_SAMPLE_RATIO must be between 0 and 1", envPrefix)
+	}
+
+	if cfg.DBSlowQueryThreshold < 0 {
+		invalid("%s_DB_SLOW_QUERY_THRESHOLD must not be negative", envPrefix)
+	}
+
+	switch cfg.DBDriver {
+	case repository.DriverPostgres:
+		errs = append(errs, cfg.validatePostgres()...)
+
+	case repository.DriverSQLite:
+		if cfg.DBPath == "" {
+			invalid("required key %s_DB_PATH missing value", envPrefix)
+		}
+
+	default:
+		invalid("unsupported %s_DB_DRIVER %q", envPrefix, cfg.DBDriver)
+	}
+
+	switch cfg.BlobStore {
+	case blobstore.BackendFS:
+		if cfg.BlobDir == "" {
+			invalid("required key %s_BLOB_DIR missing value", envPrefix)
+		}
+
+	case blobstore.BackendS3:
+		errs = append(errs, cfg.validateS3()...)
+
+	case blobstore.BackendNone:
+
+	default:
+		invalid("%s_BLOB_STORE must be one of %s", envPrefix, strings.Join(blobstore.Backends, ", "))
+	}
+
+	if cfg.UploadMaxSize <= 0 {
+		invalid("%s_UPLOAD_MAX_SIZE must be positive", envPrefix)
+	}
+
+	limits := []int{
+		cfg.RateLimitUploadsPerMinute,
+		cfg.RateLimitUploadBurst,
+		cfg.RateLimitReadsPerMinute,
+		cfg.RateLimitReadBurst,
+		cfg.RateLimitAuthFailuresPerMinute,
+		cfg.RateLimitAuthFailureBurst,
+		cfg.MaxConcurrentImports,
+	}
+
+	if slices.Min(limits) < 0 {
+		invalid("rate limits must not be negative")
+	}
+
+	for _, cidr := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			invalid("invalid %s_TRUSTED_PROXIES range %q", envPrefix, cidr)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (cfg EnvCfg) validatePostgres() []error {
+
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.DatabaseURL != "" {
+		u, err := url.Parse(cfg.DatabaseURL)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			invalid("%s_DATABASE_URL must be a postgres:// URL", envPrefix)
+		}
+	} else {
+		settings := []struct {
+			key string
+			set bool
+		}{
+			{"DB_HOST", cfg.DBHost != ""},
+			{"DB_USER", cfg.DBUser != ""},
+			{"DB_PASSWORD", cfg.DBPassword != ""},
+			{"DB_NAME", cfg.DBName != ""},
+		}
+
+		var missing []string
+		for _, setting := range settings {
+			if !setting.set {
+				missing = append(missing, envPrefix+"_"+setting.key)
+			}
+		}
+
+		if len(missing) > 0 {
+			invalid("required key %s missing value", strings.Join(missing, ", "))
+		}
+
+		if cfg.DBPort < 1 || cfg.DBPort > 65535 {
+			invalid("invalid %s_DB_PORT %d", envPrefix, cfg.DBPort)
+		}
+	}
+
+	if !slices.Contains(sslModes, cfg.DBSSLMode) {
+		invalid("invalid %s_DB_SSLMODE %q, expected one of %s", envPrefix, cfg.DBSSLMode, strings.Join(sslModes, ", "))
+	}
+
+	if _, err := os.Stat(cfg.DBSSLRootCert); cfg.DBSSLRootCert != "" && err != nil {
+		invalid("db root certificate: %v", err)
+	}
+
+	if cfg.DBMaxOpenConns < 0 || cfg.DBMaxIdleConns < 0 || cfg.DBConnMaxLifetime < 0 {
+		invalid("connection pool settings must not be negative")
+	}
+
+	if cfg.DBMaxOpenConns > 0 && cfg.DBMaxIdleConns > cfg.DBMaxOpenConns {
+		invalid("%s_DB_MAX_IDLE_CONNS must not exceed %s_DB_MAX_OPEN_CONNS", envPrefix, envPrefix)
+	}
+
+	if cfg.DBStatementTimeout < 0 {
+		invalid("%s_DB_STATEMENT_TIMEOUT must not be negative", envPrefix)
+	}
+
+	return errs
+}
+
+func (cfg EnvCfg) validateS3() []error {
+
+	var errs []error
+
+	settings := []struct {
+		key string
+		set bool
+	}{
+		{"S3_ENDPOINT", cfg.S3Endpoint != ""},
+		{"S3_REGION", cfg.S3Region != ""},
+		{"S3_BUCKET", cfg.S3Bucket != ""},
+		{"S3_ACCESS_KEY_ID", cfg.S3AccessKeyID != ""},
+		{"S3_SECRET_ACCESS_KEY", cfg.S3SecretAccessKey != ""},
+	}
+
+	var missing []string
+	for _, setting := range settings {
+		if !setting.set {
+			missing = append(missing, envPrefix+"_"+setting.key)
+		}
+	}
+
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("required key %s missing value", strings.Join(missing, ", ")))
+	}
+
+	if u, err := url.Parse(cfg.S3Endpoint); cfg.S3Endpoint != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https")) {
+		errs = append(errs, fmt.Errorf("%s_S3_ENDPOINT must be an http:// or https:// URL", envPrefix))
+	}
+
+	return errs
+}
+
+// newBlobStore opens the store uploads are kept in, nil when they are not
+// kept.
+func (cfg EnvCfg) newBlobStore() (blobstore.Store, error) {
+
+	switch cfg.BlobStore {
+	case blobstore.BackendFS:
+		return blobstore.NewFS(cfg.BlobDir)
+
+	case blobstore.BackendS3:
+		return blobstore.NewS3(blobstore.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+		})
+	}
+
+	return nil, nil
+}
+
+// ipExtractor reads the client address from X-Forwarded-For only when the
+// request came through one of the trusted proxies, so clients cannot pick
+// their own address.
+func (cfg EnvCfg) ipExtractor() echo.IPExtractor {
+
+	if len(cfg.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range cfg.TrustedProxies {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// postgresDSN prefers DatabaseURL and otherwise builds a keyword/value
+// connection string. The statement timeout is passed to the server as a
+// run-time parameter.
+func (cfg EnvCfg) postgresDSN() (string, error) {
+
+	timeout := strconv.FormatInt(cfg.DBStatementTimeout.Milliseconds(), 10)
+
+	if cfg.DatabaseURL != "" {
+		u, err := url.Parse(cfg.DatabaseURL)
+		if err != nil {
+			return "", fmt.Errorf("parse database url: %w", err)
+		}
+
+		q := u.Query()
+		if cfg.DBStatementTimeout > 0 && !q.Has("statement_timeout") {
+			q.Set("statement_timeout", timeout)
+		}
+		u.RawQuery = q.Encode()
+
+		return u.String(), nil
+	}
+
+	settings := []string{
+		"host=" + dsnValue(cfg.DBHost),
+		"port=" + strconv.Itoa(cfg.DBPort),
+		"user=" + dsnValue(cfg.DBUser),
+		"password=" + dsnValue(cfg.DBPassword),
+		"dbname=" + dsnValue(cfg.DBName),
+		"sslmode=" + cfg.DBSSLMode,
+	}
+
+	if cfg.DBSSLRootCert != "" {
+		settings = append(settings, "sslrootcert="+dsnValue(cfg.DBSSLRootCert))
+	}
+
+	if cfg.DBStatementTimeout > 0 {
+		settings = append(settings, "statement_timeout="+timeout)
+	}
+
+	settings = append(settings, "TimeZone=UTC")
+
+	return strings.Join(settings, " "), nil
+}
+
+// dsnValue quotes v for a keyword/value connection string when needed.
+func dsnValue(v string) string {
+
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+
+	return "'" + v + "'"
+}
+
+// Summary lists the effective settings as NAME=value lines, with secrets
+// redacted, for logging at startup.
+func (cfg EnvCfg) Summary() string {
+
+	v := reflect.ValueOf(cfg)
+	t := v.Type()
+
+	var b strings.Builder
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		var shown string
+		switch {
+		case value.IsZero():
+			shown = ""
+		case field.Tag.Get("redact") == "url":
+			u, err := url.Parse(value.String())
+			if err != nil {
+				shown = "[redacted]"
+			} else {
+				shown = u.Redacted()
+			}
+		case field.Tag.Get("redact") != "":
+			shown = "[redacted]"
+		case value.Kind() == reflect.Slice:
+			shown = strings.Join(value.Interface().([]string), ",")
+		default:
+			shown = fmt.Sprint(value.Interface())
+		}
+
+		fmt.Fprintf(&b, "%s_%s=%s\n", envPrefix, field.Tag.Get("envconfig"), shown)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apis"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// contract serves requests with the real server wiring and checks every
+// response against the OpenAPI document.
+type contract struct {
+	t      *testing.T
+	e      *echo.Echo
+	doc    *openapi3.T
+	router routers.Router
+}
+
+func newContract(t *testing.T) *contract {
+	db, err := repository.Open(repository.DriverSQLite, filepath.Join(t.TempDir(), "events.db"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, runMigrate(context.Background(), db, nil, &out))
+
+	e, _, err := newServer(EnvCfg{
+		APIKeys: []string{
+			"admin-key:tenant-a:alice:admin",
+			"viewer-key:tenant-a:victor:viewer",
+		},
+		BlobStore:     blobstore.BackendFS,
+		BlobDir:       t.TempDir(),
+		UploadMaxSize: 1 << 20,
+	}, db)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { closeDB(db) })
+
+	doc, err := openapi3.NewLoader().LoadFromData(apis.OpenAPISpec)
+	require.NoError(t, err)
+
+	router, err := legacy.NewRouter(doc)
+	require.NoError(t, err)
+
+	return &contract{t: t, e: e, doc: doc, router: router}
+}
+
+// do sends req and fails the test unless the response matches the spec.
+func (c *contract) do(req *http.Request) *httptest.ResponseRecorder {
+	t := c.t
+
+	rec := httptest.NewRecorder()
+	c.e.ServeHTTP(rec, req)
+
+	route, pathParams, err := c.router.FindRoute(req)
+	require.NoError(t, err, "%s %s is not in the spec", req.Method, req.URL.Path)
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: rec.Code,
+		Header: rec.Header(),
+		Body:   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+		},
+	}
+
+	err = openapi3filter.ValidateResponse(context.Background(), input)
+	assert.NoError(t, err, "%s %s answered %d: %s", req.Method, req.URL.Path, rec.Code, rec.Body.String())
+
+	return rec
+}
+
+func (c *contract) request(method string, target string, key string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	if key != "" {
+		req.Header.Set(auth.APIKeyHeader, key)
+	}
+	return req
+}
+
+func (c *contract) upload(key string, csv string) *httptest.ResponseRecorder {
+	return c.uploadForm(key, csv, map[string]string{"keep_custom_fields": "true"})
+}
+
+func (c *contract) uploadForm(key string, csv string, fields map[string]string) *httptest.ResponseRecorder {
+	return c.do(c.form("/api/v1/event", key, csv, map[string]string{"name": "Contract"}, fields))
+}
+
+func (c *contract) inspect(key string, csv string) *http.Request {
+	return c.form("/api/v1/csv/inspect", key, csv)
+}
+
+func (c *contract) form(target string, key string, csv string, fields ...map[string]string) *http.Request {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, f := range fields {
+		for name, value := range f {
+			require.NoError(c.t, writer.WriteField(name, value))
+		}
+	}
+	part, err := writer.CreateFormFile("csvfile", "todos.csv")
+	require.NoError(c.t, err)
+	_, err = part.Write([]byte(csv))
+	require.NoError(c.t, err)
+	require.NoError(c.t, writer.Close())
+
+	req := c.request(http.MethodPost, target, key, &buf)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	return req
+}
+
+func TestContract_Responses(t *testing.T) {
+	c := newContract(t)
+
+	rec := c.upload("admin-key", "todo_name,note,status,priority,due_date,assignee_email,tags,region\n"+
+		"Book venue,Downtown,done,high,2025-03-03,alice@example.com,\"venue,budget\",north\n"+
+		"Send invites,,,,,,,\n")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var created struct {
+		Data model.Event `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	id := created.Data.ID
+
+	rec = c.do(c.request(http.MethodGet, "/api/v1/imports/"+id+"/source", "admin-key", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice", rec.Header().Get("X-Uploaded-By"))
+	assert.Contains(t, rec.Body.String(), "Book venue,Downtown,done,high,2025-03-03")
+
+	rec = c.uploadForm("admin-key", "name,email,checked_in\nAda,ada@example.com,true\n", map[string]string{"type": "attendees"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	attendeesID := created.Data.ID
+
+	rec = c.uploadForm("admin-key", "name,email\n ada  lovelace ,ada@example.com\n", map[string]string{
+		"type":       "attendees",
+		"dry_run":    "true",
+		"transforms": `[{"op": "collapse_whitespace", "column": "name"}, {"op": "title_case", "column": "name"}, {"op": "default", "column": "ticket_type", "value": "standard"}]`,
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"sample":[["Ada Lovelace","ada@example.com","standard"]]`)
+
+	rec = c.uploadForm("admin-key", "name,email\nAda,ADA@example.com\nGrace,grace@example.com\nGrace,grace@example.com\n", map[string]string{
+		"type":                     "attendees",
+		"duplicate_columns":        "email",
+		"duplicates_across_events": "true",
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"duplicates":[{"row":1,"event_id":"`+attendeesID+`"},{"row":3,"duplicate_of":2}]`)
+
+	rec = c.uploadForm("admin-key", "todo_name,note,priority\nBook venue,,high\nSend invites,,urgent\nOrder food,,soon\n", map[string]string{"mode": "partial"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"rows_imported":1,"rows_rejected":2`)
+	assert.Contains(t, rec.Body.String(), `"error_report":"/api/v1/imports/`)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	partialID := created.Data.ID
+
+	rec = c.do(c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/rejects/export", "admin-key", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "todo_name,note,priority\nSend invites,,urgent\nOrder food,,soon\n", rec.Body.String())
+
+	rec = c.do(c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/report?failed_only=true", "admin-key", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "todo_name,note,priority,_errors\n"+
+		"Send invites,,urgent,\"priority must be one of low, medium, high\"\n"+
+		"Order food,,soon,\"priority must be one of low, medium, high\"\n", rec.Body.String())
+
+	statusBody := func(status string) io.Reader {
+		return strings.NewReader(`{"status":"` + status + `"}`)
+	}
+	patch := func(key string, body io.Reader) *http.Request {
+		req := c.request(http.MethodPatch, "/api/v1/events/"+id+"/status", key, body)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		return req
+	}
+
+	testCases := []struct {
+		name     string
+		req      *http.Request
+		expected int
+	}{
+		{"list events", c.request(http.MethodGet, "/api/v1/events", "viewer-key", nil), http.StatusOK},
+		{"get event", c.request(http.MethodGet, "/api/v1/events/"+id, "viewer-key", nil), http.StatusOK},
+		{"list todos", c.request(http.MethodGet, "/api/v1/events/"+id+"/todos", "viewer-key", nil), http.StatusOK},
+		{"filter todos", c.request(http.MethodGet, "/api/v1/events/"+id+"/todos?status=done&tag=venue&due_before=2025-04-01", "viewer-key", nil), http.StatusOK},
+		{"filter todos by custom field", c.request(http.MethodGet, "/api/v1/events/"+id+"/todos?custom_field=region:north", "viewer-key", nil), http.StatusOK},
+		{"bad todo filter", c.request(http.MethodGet, "/api/v1/events/"+id+"/todos?priority=urgent", "viewer-key", nil), http.StatusBadRequest},
+		{"list records", c.request(http.MethodGet, "/api/v1/events/"+attendeesID+"/records", "viewer-key", nil), http.StatusOK},
+		{"records of todos", c.request(http.MethodGet, "/api/v1/events/"+id+"/records", "viewer-key", nil), http.StatusBadRequest},
+		{"export records", c.request(http.MethodGet, "/api/v1/events/"+attendeesID+"/export", "admin-key", nil), http.StatusOK},
+		{"list schemas", c.request(http.MethodGet, "/api/v1/schemas", "viewer-key", nil), http.StatusOK},
+		{"get schema", c.request(http.MethodGet, "/api/v1/schemas/attendees", "viewer-key", nil), http.StatusOK},
+		{"unknown schema", c.request(http.MethodGet, "/api/v1/schemas/inventory", "viewer-key", nil), http.StatusNotFound},
+		{"inspect csv", c.inspect("admin-key", "sku,price,since\nABC-1,9.5,2025-03-03\nABC-2,,\n"), http.StatusOK},
+		{"inspect malformed csv", c.inspect("admin-key", "sku,price\n\"unclosed"), http.StatusBadRequest},
+		{"viewer may not inspect", c.inspect("viewer-key", "sku\nABC-1\n"), http.StatusForbidden},
+		{"list rejects", c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/rejects", "viewer-key", nil), http.StatusOK},
+		{"list rejects of a strict import", c.request(http.MethodGet, "/api/v1/imports/"+id+"/rejects", "viewer-key", nil), http.StatusOK},
+		{"rejects of an unknown import", c.request(http.MethodGet, "/api/v1/imports/missing/rejects", "viewer-key", nil), http.StatusNotFound},
+		{"export rejects", c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/rejects/export", "admin-key", nil), http.StatusOK},
+		{"error report", c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/report", "admin-key", nil), http.StatusOK},
+		{"error report of a strict import", c.request(http.MethodGet, "/api/v1/imports/"+id+"/report", "admin-key", nil), http.StatusOK},
+		{"error report with a bad flag", c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/report?failed_only=maybe", "admin-key", nil), http.StatusBadRequest},
+		{"viewer may not download error reports", c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/report", "viewer-key", nil), http.StatusForbidden},
+		{"source file", c.request(http.MethodGet, "/api/v1/imports/"+id+"/source", "admin-key", nil), http.StatusOK},
+		{"source file of an unknown import", c.request(http.MethodGet, "/api/v1/imports/missing/source", "admin-key", nil), http.StatusNotFound},
+		{"viewer may not download source files", c.request(http.MethodGet, "/api/v1/imports/"+id+"/source", "viewer-key", nil), http.StatusForbidden},
+		{"viewer may not export rejects", c.request(http.MethodGet, "/api/v1/imports/"+partialID+"/rejects/export", "viewer-key", nil), http.StatusForbidden},
+		{"export", c.request(http.MethodGet, "/api/v1/events/"+id+"/export", "admin-key", nil), http.StatusOK},
+		{"change status", patch("admin-key", statusBody("start")), http.StatusOK},
+		{"invalid status", patch("admin-key", statusBody("paused")), http.StatusBadRequest},
+		{"malformed body", patch("admin-key", strings.NewReader("{")), http.StatusBadRequest},
+		{"viewer may not change status", patch("viewer-key", statusBody("end")), http.StatusForbidden},
+		{"missing key", c.request(http.MethodGet, "/api/v1/events", "", nil), http.StatusUnauthorized},
+		{"unknown event", c.request(http.MethodGet, "/api/v1/events/missing", "viewer-key", nil), http.StatusNotFound},
+		{"audit log", c.request(http.MethodGet, "/api/v1/audit?target_id="+id+"&limit=10", "admin-key", nil), http.StatusOK},
+		{"bad audit query", c.request(http.MethodGet, "/api/v1/audit?limit=ten", "admin-key", nil), http.StatusBadRequest},
+		{"liveness", c.request(http.MethodGet, "/livez", "", nil), http.StatusOK},
+		{"readiness", c.request(http.MethodGet, "/readyz?verbose=1", "", nil), http.StatusOK},
+		{"health report", c.request(http.MethodGet, "/healthz?verbose=1", "", nil), http.StatusOK},
+		{"metrics", c.request(http.MethodGet, "/metrics", "admin-key", nil), http.StatusOK},
+		{"metrics without a key", c.request(http.MethodGet, "/metrics", "", nil), http.StatusUnauthorized},
+		{"viewer may not scrape metrics", c.request(http.MethodGet, "/metrics", "viewer-key", nil), http.StatusForbidden},
+		{"delete event", c.request(http.MethodDelete, "/api/v1/events/"+id, "admin-key", nil), http.StatusOK},
+		{"deleted event", c.request(http.MethodDelete, "/api/v1/events/"+id, "admin-key", nil), http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.t = t
+			rec := c.do(tc.req)
+			assert.Equal(t, tc.expected, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestContract_UploadErrors(t *testing.T) {
+	c := newContract(t)
+
+	rec := c.upload("admin-key", "todo_name,note\n\"unclosed,quote")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.upload("admin-key", "todo_name,note,due_date\nTask,Note,someday\n")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.uploadForm("admin-key", "name,email\nAda,ada@example.com\n", map[string]string{"type": "inventory"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.uploadForm("admin-key", "name\nAda\n", map[string]string{"type": "attendees"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.uploadForm("admin-key", "todo_name,note\nTask,Note\n", map[string]string{"transforms": `[{"op": "shout"}]`})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.uploadForm("admin-key", "todo_name,note\nTask,Note\n", map[string]string{"transforms": `[{"op": "trim", "column": "region"}]`})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.uploadForm("admin-key", "todo_name,note\nTask,Note\nTask,Other\n", map[string]string{"duplicate_columns": "todo_name", "duplicate_policy": "fail"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.uploadForm("admin-key", "todo_name,note\nTask,Note\n", map[string]string{"duplicate_columns": "region"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.uploadForm("admin-key", "todo_name,note\nTask,Note\n", map[string]string{"mode": "lenient"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = c.upload("viewer-key", "todo_name,note\nTask,Note\n")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req := c.request(http.MethodPost, "/api/v1/event", "admin-key", strings.NewReader(""))
+	req.Header.Set(echo.HeaderContentType, "multipart/form-data; boundary=x")
+	rec = c.do(req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestContract_ResumableUpload(t *testing.T) {
+	c := newContract(t)
+
+	tus := func(method string, target string, key string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := c.request(method, target, key, strings.NewReader(body))
+		req.Header.Set(apis.HeaderTusResumable, apis.TusVersion)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return c.do(req)
+	}
+
+	rec := tus(http.MethodOptions, "/api/v1/uploads", "admin-key", "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	csv := "todo_name,note\nBook venue,Downtown\n"
+	rec = tus(http.MethodPost, "/api/v1/uploads", "admin-key", "", map[string]string{
+		apis.HeaderUploadLength:   strconv.Itoa(len(csv)),
+		apis.HeaderUploadMetadata: "name " + base64.StdEncoding.EncodeToString([]byte("Contract")),
+	})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	location := rec.Header().Get(echo.HeaderLocation)
+
+	chunk := func(offset int, body string) *httptest.ResponseRecorder {
+		return tus(http.MethodPatch, location, "admin-key", body, map[string]string{
+			echo.HeaderContentType:  apis.MIMEOffsetOctetStream,
+			apis.HeaderUploadOffset: strconv.Itoa(offset),
+		})
+	}
+
+	testCases := []struct {
+		name     string
+		rec      func() *httptest.ResponseRecorder
+		expected int
+	}{
+		{"first chunk", func() *httptest.ResponseRecorder { return chunk(0, csv[:10]) }, http.StatusNoContent},
+		{"offset", func() *httptest.ResponseRecorder { return tus(http.MethodHead, location, "admin-key", "", nil) }, http.StatusOK},
+		{"chunk at a wrong offset", func() *httptest.ResponseRecorder { return chunk(0, csv[:10]) }, http.StatusConflict},
+		{"chunk past the length", func() *httptest.ResponseRecorder { return chunk(10, csv[10:]+"x") }, http.StatusRequestEntityTooLarge},
+		{"last chunk", func() *httptest.ResponseRecorder { return chunk(10, csv[10:]) }, http.StatusNoContent},
+		{"status", func() *httptest.ResponseRecorder { return tus(http.MethodGet, location, "admin-key", "", nil) }, http.StatusOK},
+		{"unknown upload", func() *httptest.ResponseRecorder {
+			return tus(http.MethodGet, "/api/v1/uploads/missing", "admin-key", "", nil)
+		}, http.StatusNotFound},
+		{"missing length", func() *httptest.ResponseRecorder { return tus(http.MethodPost, "/api/v1/uploads", "admin-key", "", nil) }, http.StatusBadRequest},
+		{"too large", func() *httptest.ResponseRecorder {
+			return tus(http.MethodPost, "/api/v1/uploads", "admin-key", "", map[string]string{apis.HeaderUploadLength: "2097152"})
+		}, http.StatusRequestEntityTooLarge},
+		{"other protocol version", func() *httptest.ResponseRecorder {
+			return tus(http.MethodPost, "/api/v1/uploads", "admin-key", "", map[string]string{apis.HeaderTusResumable: "0.2.2"})
+		}, http.StatusPreconditionFailed},
+		{"viewer may not upload", func() *httptest.ResponseRecorder {
+			return tus(http.MethodPost, "/api/v1/uploads", "viewer-key", "", map[string]string{apis.HeaderUploadLength: "10"})
+		}, http.StatusForbidden},
+		{"terminate", func() *httptest.ResponseRecorder { return tus(http.MethodDelete, location, "admin-key", "", nil) }, http.StatusNoContent},
+		{"terminated upload", func() *httptest.ResponseRecorder { return tus(http.MethodHead, location, "admin-key", "", nil) }, http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.t = t
+			rec := tc.rec()
+			assert.Equal(t, tc.expected, rec.Code, rec.Body.String())
+		})
+	}
+
+	rec = c.do(c.request(http.MethodGet, "/api/v1/events", "admin-key", nil))
+	assert.Contains(t, rec.Body.String(), `"name":"Contract"`, "The complete upload is imported")
+}
+
+func TestContract_ProblemJSON(t *testing.T) {
+	c := newContract(t)
+
+	req := c.request(http.MethodGet, "/api/v1/events/missing", "admin-key", nil)
+	req.Header.Set(echo.HeaderAccept, apperr.MIMEProblemJSON)
+
+	rec := c.do(req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, apperr.MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+}
+
+func TestContract_EveryRouteIsDocumented(t *testing.T) {
+	c := newContract(t)
+
+	documented := make(map[string]bool)
+	for path, item := range c.doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	var routes []string
+	for _, route := range c.e.Routes() {
+		// The spec and its docs page describe the API rather than being part
+		// of it, and the group's not-found handlers answer unknown paths
+		if strings.HasPrefix(route.Path, "/api/v1/docs") || route.Path == "/api/v1/openapi.json" || route.Method == echo.RouteNotFound {
+			continue
+		}
+
+		path := route.Path
+		for _, segment := range strings.Split(route.Path, "/") {
+			if strings.HasPrefix(segment, ":") {
+				path = strings.Replace(path, segment, "{"+segment[1:]+"}", 1)
+			}
+		}
+
+		key := route.Method + " " + path
+		routes = append(routes, key)
+		assert.True(t, documented[key], "%s is registered but not documented", key)
+	}
+
+	for key := range documented {
+		assert.Contains(t, routes, key, "%s is documented but not registered", key)
+	}
+}
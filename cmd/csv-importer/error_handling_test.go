@@ -4,14 +4,19 @@ import (
 	"bytes"
 	"context"
 	"csv-importer-backend/cmd/csv-importer/apis"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/lifecycle"
 	"csv-importer-backend/cmd/csv-importer/model"
 	"csv-importer-backend/cmd/csv-importer/repository"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -130,7 +135,7 @@ func TestErrorHandling_DatabaseConstraintViolation(t *testing.T) {
 	// Simulate unique constraint violation
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "events"`).
-		WithArgs(testEvent.ID, testEvent.Name, testEvent.Status, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WithArgs(testEvent.ID, auth.DefaultTenant, testEvent.Name, testEvent.Status, model.ImportTypeTodos, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnError(errors.New(`pq: duplicate key value violates unique constraint "events_pkey"`))
 	mock.ExpectRollback()
 
@@ -295,32 +300,176 @@ func TestErrorHandling_DatabaseConnectionPool(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestErrorHandling_GracefulShutdown(t *testing.T) {
-	// Test that the application can handle shutdown signals gracefully
-	// This would typically involve testing signal handling in main()
-	
-	// Create a context that gets cancelled (simulating shutdown)
-	ctx, cancel := context.WithCancel(context.Background())
-	
-	// Simulate some work being interrupted
-	done := make(chan bool)
+// shutdownServer runs the real server on a random port against a SQLite
+// file, so that tests can interrupt it the way a SIGTERM would.
+type shutdownServer struct {
+	url     string
+	dbPath  string
+	db      *gorm.DB
+	drainer *lifecycle.Drainer
+	stop    context.CancelFunc
+	done    chan error
+}
+
+func startShutdownServer(t *testing.T, timeout time.Duration) *shutdownServer {
+	dbPath := filepath.Join(t.TempDir(), "events.db")
+
+	db, err := repository.Open(repository.DriverSQLite, dbPath, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, runMigrate(context.Background(), db, nil, &out))
+
+	e, drainer, err := newServer(EnvCfg{APIKeys: []string{"shutdown-key:tenant-a:alice:admin"}}, db)
+	require.NoError(t, err)
+	e.HideBanner = true
+	e.HidePort = true
+
+	e.Listener, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+
+	s := &shutdownServer{
+		url:     "http://" + e.Listener.Addr().String() + "/api/v1/event",
+		dbPath:  dbPath,
+		db:      db,
+		drainer: drainer,
+		stop:    stop,
+		done:    make(chan error, 1),
+	}
+
 	go func() {
-		select {
-		case <-ctx.Done():
-			done <- true
-		case <-time.After(time.Second):
-			done <- false
-		}
+		s.done <- serve(ctx, EnvCfg{ShutdownTimeout: timeout}, e, drainer, db)
 	}()
-	
-	// Cancel context after short delay
+
+	return s
+}
+
+// startUpload sends the headers and the first row of an upload and keeps the
+// body open until the returned writer is finished.
+func (s *shutdownServer) startUpload(t *testing.T) (*multipart.Writer, *io.PipeWriter, chan *http.Response) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	req, err := http.NewRequest(http.MethodPost, s.url, pr)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set(auth.APIKeyHeader, "shutdown-key")
+
+	responses := make(chan *http.Response, 1)
 	go func() {
-		time.Sleep(time.Millisecond * 10)
-		cancel()
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			res = nil
+		}
+		responses <- res
 	}()
-	
-	result := <-done
-	assert.True(t, result, "Context cancellation should interrupt work")
+
+	require.NoError(t, writer.WriteField("name", "Slow upload"))
+	part, err := writer.CreateFormFile("csvfile", "todos.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("todo_name,note\nBook venue,Downtown\n"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return s.drainer.InFlight() == 1 }, 5*time.Second, time.Millisecond)
+
+	return writer, pw, responses
+}
+
+func (s *shutdownServer) upload(t *testing.T) *http.Response {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	require.NoError(t, writer.WriteField("name", "Late upload"))
+	part, err := writer.CreateFormFile("csvfile", "todos.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("todo_name,note\nCall dentist,Tuesday\n"))
+	require.NoError(t, err)
+	writer.Close()
+
+	req, err := http.NewRequest(http.MethodPost, s.url, &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set(auth.APIKeyHeader, "shutdown-key")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+
+	return res
+}
+
+func countEvents(t *testing.T, dbPath string) int64 {
+	db, err := repository.Open(repository.DriverSQLite, dbPath, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	var count int64
+	require.NoError(t, db.Model(&model.Event{}).Count(&count).Error)
+	return count
+}
+
+func TestErrorHandling_GracefulShutdown(t *testing.T) {
+	s := startShutdownServer(t, 5*time.Second)
+
+	writer, pw, responses := s.startUpload(t)
+
+	// SIGTERM while the upload is still streaming in
+	s.stop()
+	require.Eventually(t, s.drainer.Draining, time.Second, time.Millisecond)
+
+	res := s.upload(t)
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode, "New uploads are refused while draining")
+
+	select {
+	case err := <-s.done:
+		t.Fatalf("Server stopped before the in-flight upload finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, writer.Close())
+	require.NoError(t, pw.Close())
+
+	inFlight := <-responses
+	require.NotNil(t, inFlight)
+	inFlight.Body.Close()
+	assert.Equal(t, http.StatusOK, inFlight.StatusCode, "The in-flight upload completes")
+
+	require.NoError(t, <-s.done)
+
+	sqlDB, err := s.db.DB()
+	require.NoError(t, err)
+	assert.Error(t, sqlDB.Ping(), "The connection pool is closed")
+
+	assert.Equal(t, int64(1), countEvents(t, s.dbPath))
+}
+
+func TestErrorHandling_ShutdownDeadline(t *testing.T) {
+	s := startShutdownServer(t, 100*time.Millisecond)
+
+	_, pw, responses := s.startUpload(t)
+
+	s.stop()
+
+	err := <-s.done
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The client only notices once its side of the body is done too
+	pw.CloseWithError(errors.New("client gave up"))
+	if res := <-responses; res != nil {
+		res.Body.Close()
+		assert.NotEqual(t, http.StatusOK, res.StatusCode)
+	}
+
+	assert.Equal(t, int64(0), countEvents(t, s.dbPath), "An aborted import leaves nothing behind")
 }
 
 func TestErrorHandling_ConcurrentDatabaseAccess(t *testing.T) {
@@ -376,13 +525,29 @@ func (m *MockEventRepo) ListEvents(ctx context.Context) ([]model.Event, error) {
 	return []model.Event{}, nil
 }
 
-func (m *MockEventRepo) CreateEvent(ctx context.Context, event model.Event) error {
+func (m *MockEventRepo) GetEvent(ctx context.Context, id string) (*model.Event, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockEventRepo) CreateEvent(ctx context.Context, event model.Event, todos ...model.TodoEvent) error {
 	if m.ShouldFailCreate {
 		return m.CreateError
 	}
 	return nil
 }
 
+func (m *MockEventRepo) ListTodos(ctx context.Context, eventID string, filter model.TodoFilter) ([]model.TodoEvent, error) {
+	return []model.TodoEvent{}, nil
+}
+
+func (m *MockEventRepo) UpdateEventStatus(ctx context.Context, id string, status model.EventStatus) error {
+	return gorm.ErrRecordNotFound
+}
+
+func (m *MockEventRepo) DeleteEvent(ctx context.Context, id string) error {
+	return gorm.ErrRecordNotFound
+}
+
 func TestErrorHandling_RepositoryErrorPropagation(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/transform"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// mappedColumns are the headers gocsv assigns to model.TodoCSV fields.
+var mappedColumns = func() map[string]bool {
+
+	columns := map[string]bool{}
+
+	t := reflect.TypeOf(model.TodoCSV{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("csv"); tag != "" && tag != "-" {
+			columns[strings.Split(tag, ",")[0]] = true
+		}
+	}
+
+	return columns
+}()
+
+// recordsReader hands records that were already read to gocsv.
+type recordsReader struct {
+	records [][]string
+}
+
+func (r *recordsReader) Read() ([]string, error) {
+	if len(r.records) == 0 {
+		return nil, io.EOF
+	}
+	record := r.records[0]
+	r.records = r.records[1:]
+	return record, nil
+}
+
+func (r *recordsReader) ReadAll() ([][]string, error) {
+	records := r.records
+	r.records = nil
+	return records, nil
+}
+
+// csvFile is a parsed import file.
+type csvFile struct {
+	rows []model.TodoCSV
+	// header and body hold the transformed records, body aligned with rows.
+	header []string
+	body   [][]string
+	// unmapped maps the index of every unmapped, named column to its header.
+	// A repeated header is only kept the first time.
+	unmapped map[int]string
+	applied  []transform.Applied
+}
+
+func newCSVFile(rows []model.TodoCSV, header []string, body [][]string) *csvFile {
+
+	file := &csvFile{
+		rows:     rows,
+		header:   header,
+		body:     body,
+		unmapped: map[int]string{},
+	}
+
+	seen := map[string]bool{}
+	for i, header := range header {
+		name := columnName(header)
+		if name == "" || name == ErrorsColumn || mappedColumns[name] || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		file.unmapped[i] = name
+	}
+
+	return file
+}
+
+// customFields returns the non-empty unmapped cells of data row n, counted
+// from 0.
+func (f *csvFile) customFields(n int) model.CustomFields {
+
+	if n >= len(f.body) {
+		return nil
+	}
+
+	var fields model.CustomFields
+	for i, name := range f.unmapped {
+		if i >= len(f.body[n]) || f.body[n][i] == "" {
+			continue
+		}
+
+		if fields == nil {
+			fields = model.CustomFields{}
+		}
+		fields[name] = f.body[n][i]
+	}
+
+	return fields
+}
+
+// columnName normalizes a header the way gocsv does when matching it to a
+// field: surrounding space and zero width characters are ignored.
+func columnName(header string) string {
+
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\u200B', '\u200C', '\u200D', '\uFEFF':
+			return -1
+		}
+		return r
+	}, strings.TrimFunc(header, unicode.IsSpace))
+}
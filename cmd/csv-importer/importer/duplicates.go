@@ -0,0 +1,362 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Duplicate policies.
+const (
+	// DuplicateSkip imports the first of equal rows only.
+	DuplicateSkip = "skip"
+	// DuplicateKeep imports every row and only reports duplicates.
+	DuplicateKeep = "keep"
+	// DuplicateFail rejects files with duplicates.
+	DuplicateFail = "fail"
+)
+
+// Duplicate key matching.
+const (
+	MatchExact = "exact"
+	// MatchNormalized ignores case and runs of whitespace.
+	MatchNormalized = "normalized"
+)
+
+var (
+	duplicatePolicies = []string{DuplicateSkip, DuplicateKeep, DuplicateFail}
+	duplicateMatches  = []string{MatchExact, MatchNormalized}
+)
+
+// DuplicateOptions configure duplicate detection, which is off without
+// Columns. A row is a duplicate when its values for all Columns equal those
+// of an earlier row of the file or, with AcrossEvents, of a row of another
+// event of the tenant with the same import type. Values are compared as
+// they are exported, e.g. dates as 2006-01-02, and rows whose key columns
+// are all empty are never duplicates.
+type DuplicateOptions struct {
+	Columns []string
+	// Match is exact by default.
+	Match string
+	// Policy is skip by default.
+	Policy       string
+	AcrossEvents bool
+}
+
+// Duplicate is one duplicate row, of an earlier row of the file or of a row
+// of another event. Rows count from 1, after the header.
+type Duplicate struct {
+	Row         int    `json:"row"`
+	DuplicateOf int    `json:"duplicate_of,omitempty"`
+	EventID     string `json:"event_id,omitempty"`
+}
+
+// IDuplicateRepo reads the rows of earlier events for AcrossEvents. Only
+// live rows of the tenant in ctx are passed to fn, in batches.
+type IDuplicateRepo interface {
+	ScanTodos(ctx context.Context, fn func(todos []model.TodoEvent) error) error
+	ScanRecords(ctx context.Context, table string, fn func(records []map[string]any) error) error
+}
+
+func (o *DuplicateOptions) enabled() bool {
+	return len(o.Columns) > 0
+}
+
+// check validates the options and fills in the defaults.
+func (o *DuplicateOptions) check() error {
+
+	if !o.enabled() {
+		return nil
+	}
+
+	if o.Match == "" {
+		o.Match = MatchExact
+	}
+	if !slices.Contains(duplicateMatches, o.Match) {
+		return apperr.Validation("duplicate match must be one of " + strings.Join(duplicateMatches, ", ")).
+			WithDetails(map[string]any{"match": o.Match})
+	}
+
+	if o.Policy == "" {
+		o.Policy = DuplicateSkip
+	}
+	if !slices.Contains(duplicatePolicies, o.Policy) {
+		return apperr.Validation("duplicate policy must be one of " + strings.Join(duplicatePolicies, ", ")).
+			WithDetails(map[string]any{"policy": o.Policy})
+	}
+
+	return nil
+}
+
+// checkColumns rejects key columns that are not imported, as only imported
+// values can be compared with other events.
+func (o *DuplicateOptions) checkColumns(imported func(column string) bool) error {
+
+	var unknown []string
+	for _, c := range o.Columns {
+		if !imported(c) {
+			unknown = append(unknown, c)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return apperr.Validation("duplicate columns must be imported columns").
+			WithDetails(map[string]any{"columns": unknown})
+	}
+
+	return nil
+}
+
+// key joins the values of the key columns; empty keys never match.
+func (o *DuplicateOptions) key(value func(column string) string) string {
+
+	values := make([]string, len(o.Columns))
+	empty := true
+	for n, c := range o.Columns {
+		v := value(c)
+		if o.Match == MatchNormalized {
+			v = strings.ToLower(strings.Join(strings.Fields(v), " "))
+		}
+		values[n] = v
+		empty = empty && v == ""
+	}
+
+	if empty {
+		return ""
+	}
+
+	return strings.Join(values, "\x1f")
+}
+
+// duplicateReport is the outcome of duplicate detection for one file.
+type duplicateReport struct {
+	// skip holds the indexes of the rows left out.
+	skip  map[int]bool
+	found []Duplicate
+	count int
+}
+
+// rows returns the number of duplicate rows; a nil report has none.
+func (r *duplicateReport) rows() int {
+
+	if r == nil {
+		return 0
+	}
+
+	return r.count
+}
+
+// result fills in the duplicates of result.
+func (r *duplicateReport) result(result *Result) *Result {
+
+	if r != nil {
+		result.RowsDuplicated = r.count
+		result.RowsSkipped = len(r.skip)
+		result.Duplicates = r.found
+	}
+
+	return result
+}
+
+// keep returns the rows that are not skipped.
+func keep[T any](report *duplicateReport, rows []T) []T {
+
+	if report == nil || len(report.skip) == 0 {
+		return rows
+	}
+
+	kept := make([]T, 0, len(rows)-len(report.skip))
+	for n, row := range rows {
+		if !report.skip[n] {
+			kept = append(kept, row)
+		}
+	}
+
+	return kept
+}
+
+// findDuplicates applies the policy of opts to the rows with keys; keys[n]
+// belongs to row numbers[n]. existing maps the keys of other events to one of
+// them. With the fail policy, the report comes with the error.
+func findDuplicates(opts DuplicateOptions, keys []string, numbers []int, existing map[string]string) (*duplicateReport, error) {
+
+	report := &duplicateReport{skip: map[int]bool{}}
+	seen := map[string]int{}
+
+	for n, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		duplicate := Duplicate{Row: numbers[n]}
+		if eventID, ok := existing[key]; ok {
+			duplicate.EventID = eventID
+		} else if first, ok := seen[key]; ok {
+			duplicate.DuplicateOf = first
+		} else {
+			seen[key] = numbers[n]
+			continue
+		}
+
+		report.count++
+		if len(report.found) < maxRowErrors {
+			report.found = append(report.found, duplicate)
+		}
+		if opts.Policy == DuplicateSkip {
+			report.skip[n] = true
+		}
+	}
+
+	if report.count > 0 && opts.Policy == DuplicateFail {
+		return report, apperr.Validation("csvfile has duplicate rows").
+			WithDetails(map[string]any{
+				"rows_duplicated": report.count,
+				"duplicates":      report.found,
+			})
+	}
+
+	return report, nil
+}
+
+// todoValue returns the exported value of a todo or custom column.
+func todoValue(todo model.TodoEvent, name string) string {
+
+	for _, c := range columns {
+		if c.name == name {
+			return c.value(todo)
+		}
+	}
+
+	return todo.CustomFields[name]
+}
+
+// todoDuplicates finds the duplicates among todos, the valid rows of file;
+// todos[n] is row numbers[n].
+func (i *Importer) todoDuplicates(ctx context.Context, opts Options, file *csvFile, todos []model.TodoEvent, numbers []int) (*duplicateReport, error) {
+
+	dup := opts.Duplicates
+	if !dup.enabled() {
+		return nil, nil
+	}
+
+	err := dup.checkColumns(func(c string) bool {
+		return mappedColumns[c] || (opts.KeepCustomFields && slices.Contains(file.header, c))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "import.duplicates", attribute.Bool("duplicates.across_events", dup.AcrossEvents))
+
+	todoKey := func(todo model.TodoEvent) string {
+		return dup.key(func(c string) string { return todoValue(todo, c) })
+	}
+
+	keys := make([]string, len(todos))
+	for n, todo := range todos {
+		keys[n] = todoKey(todo)
+	}
+
+	existing := map[string]string{}
+	if dup.AcrossEvents {
+		err = i.scanDuplicates(func(repo IDuplicateRepo) error {
+			return repo.ScanTodos(ctx, func(todos []model.TodoEvent) error {
+				for _, todo := range todos {
+					existing[todoKey(todo)] = todo.EventID
+				}
+				return nil
+			})
+		})
+	}
+
+	var report *duplicateReport
+	if err == nil {
+		report, err = findDuplicates(dup, keys, numbers, existing)
+	}
+
+	endDuplicates(span, report, err)
+
+	return report, err
+}
+
+// recordDuplicates finds the duplicates among records of the import type s;
+// records[n] is row numbers[n].
+func (i *Importer) recordDuplicates(ctx context.Context, opts Options, s *schema.Schema, records []map[string]any, numbers []int) (*duplicateReport, error) {
+
+	dup := opts.Duplicates
+	if !dup.enabled() {
+		return nil, nil
+	}
+
+	columns := map[string]*schema.Column{}
+	for n := range s.Columns {
+		columns[s.Columns[n].Name] = &s.Columns[n]
+	}
+
+	err := dup.checkColumns(func(c string) bool { return columns[c] != nil })
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "import.duplicates", attribute.Bool("duplicates.across_events", dup.AcrossEvents))
+
+	recordKey := func(record map[string]any) string {
+		return dup.key(func(c string) string { return columns[c].Format(record[c]) })
+	}
+
+	keys := make([]string, len(records))
+	for n, record := range records {
+		keys[n] = recordKey(record)
+	}
+
+	existing := map[string]string{}
+	if dup.AcrossEvents {
+		err = i.scanDuplicates(func(repo IDuplicateRepo) error {
+			return repo.ScanRecords(ctx, s.Table, func(records []map[string]any) error {
+				for _, record := range records {
+					existing[recordKey(record)] = fmt.Sprint(record["event_id"])
+				}
+				return nil
+			})
+		})
+	}
+
+	var report *duplicateReport
+	if err == nil {
+		report, err = findDuplicates(dup, keys, numbers, existing)
+	}
+
+	endDuplicates(span, report, err)
+
+	return report, err
+}
+
+// scanDuplicates calls scan with the event repository, when it can read
+// earlier events.
+func (i *Importer) scanDuplicates(scan func(repo IDuplicateRepo) error) error {
+
+	repo, ok := i.eventRepo.(IDuplicateRepo)
+	if !ok {
+		return errors.New("the event repository cannot find duplicates across events")
+	}
+
+	return scan(repo)
+}
+
+func endDuplicates(span trace.Span, report *duplicateReport, err error) {
+
+	if report != nil {
+		span.SetAttributes(attribute.Int("import.rows_duplicated", report.count))
+	}
+
+	tracing.End(span, err)
+}
@@ -0,0 +1,234 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDuplicateRepo is a MockEventRepo that can read earlier events.
+type MockDuplicateRepo struct {
+	MockEventRepo
+}
+
+func (m *MockDuplicateRepo) ScanTodos(ctx context.Context, fn func(todos []model.TodoEvent) error) error {
+	args := m.Called(ctx)
+	return fn(args.Get(0).([]model.TodoEvent))
+}
+
+func (m *MockDuplicateRepo) ScanRecords(ctx context.Context, table string, fn func(records []map[string]any) error) error {
+	args := m.Called(ctx, table)
+	return fn(args.Get(0).([]map[string]any))
+}
+
+func TestImporter_Import_Duplicates(t *testing.T) {
+	csv := "todo_name,note\n" +
+		"Book venue,Downtown\n" +
+		"book  VENUE,Uptown\n" +
+		"Send invites,\n" +
+		"Book venue,Again\n" +
+		",\n" +
+		",\n"
+
+	testCases := []struct {
+		name       string
+		duplicates DuplicateOptions
+		imported   []string
+		duplicated []Duplicate
+		skipped    int
+	}{
+		{
+			name:     "off by default",
+			imported: []string{"Book venue", "book  VENUE", "Send invites", "Book venue", "", ""},
+		},
+		{
+			name:       "exact",
+			duplicates: DuplicateOptions{Columns: []string{"todo_name"}},
+			imported:   []string{"Book venue", "book  VENUE", "Send invites", "", ""},
+			duplicated: []Duplicate{{Row: 4, DuplicateOf: 1}},
+			skipped:    1,
+		},
+		{
+			name:       "normalized",
+			duplicates: DuplicateOptions{Columns: []string{"todo_name"}, Match: MatchNormalized},
+			imported:   []string{"Book venue", "Send invites", "", ""},
+			duplicated: []Duplicate{{Row: 2, DuplicateOf: 1}, {Row: 4, DuplicateOf: 1}},
+			skipped:    2,
+		},
+		{
+			name:       "keep",
+			duplicates: DuplicateOptions{Columns: []string{"todo_name"}, Match: MatchNormalized, Policy: DuplicateKeep},
+			imported:   []string{"Book venue", "book  VENUE", "Send invites", "Book venue", "", ""},
+			duplicated: []Duplicate{{Row: 2, DuplicateOf: 1}, {Row: 4, DuplicateOf: 1}},
+		},
+		{
+			name:       "several columns",
+			duplicates: DuplicateOptions{Columns: []string{"todo_name", "note"}},
+			imported:   []string{"Book venue", "book  VENUE", "Send invites", "Book venue", "", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := new(MockEventRepo)
+
+			var stored []model.TodoEvent
+			repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { stored = args.Get(2).([]model.TodoEvent) }).
+				Return(nil)
+
+			result, err := New(repo).Import(context.Background(), "Offsite", strings.NewReader(csv), Options{Duplicates: tc.duplicates})
+			require.NoError(t, err)
+
+			var names []string
+			for _, todo := range stored {
+				names = append(names, todo.Name)
+			}
+			assert.Equal(t, tc.imported, names, "Rows without a key are never duplicates")
+			assert.Equal(t, len(tc.imported), result.RowsImported)
+			assert.Equal(t, len(tc.duplicated), result.RowsDuplicated)
+			assert.Equal(t, tc.duplicated, result.Duplicates)
+			assert.Equal(t, tc.skipped, result.RowsSkipped)
+		})
+	}
+}
+
+func TestImporter_Import_DuplicatesFail(t *testing.T) {
+	repo := new(MockEventRepo)
+	observer := &recordingObserver{}
+	imp := New(repo)
+	imp.SetObserver(observer)
+
+	csv := "todo_name,note,due_date\nBook venue,,3 Mar 2025\nSend invites,,\nBook venue,,2025-03-03\n"
+	opts := Options{Duplicates: DuplicateOptions{Columns: []string{"todo_name", "due_date"}, Policy: DuplicateFail}}
+
+	_, err := imp.Import(context.Background(), "Offsite", strings.NewReader(csv), opts)
+
+	var appErr *apperr.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperr.CodeValidation, appErr.Code)
+	assert.Equal(t, "csvfile has duplicate rows", appErr.Message)
+	assert.Equal(t, map[string]any{
+		"rows_duplicated": 1,
+		"duplicates":      []Duplicate{{Row: 3, DuplicateOf: 1}},
+	}, appErr.Details, "Values are compared as imported")
+
+	require.Len(t, observer.stats, 1)
+	assert.Equal(t, 1, observer.stats[0].RowsRejected)
+	repo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImporter_Import_DuplicatesAcrossEvents(t *testing.T) {
+	repo := new(MockDuplicateRepo)
+	repo.On("ScanTodos", mock.Anything).Return([]model.TodoEvent{
+		{EventID: "event-1", Name: "Book venue", CustomFields: model.CustomFields{"region": "north"}},
+		{EventID: "event-1", Name: "Order food"},
+	})
+
+	var stored []model.TodoEvent
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]model.TodoEvent) }).
+		Return(nil)
+
+	csv := "todo_name,note,region\nBook venue,,north\nBook venue,,south\nSend invites,,south\nSend invites,,south\n"
+	opts := Options{
+		KeepCustomFields: true,
+		Duplicates:       DuplicateOptions{Columns: []string{"todo_name", "region"}, AcrossEvents: true},
+	}
+
+	result, err := New(repo).Import(context.Background(), "Offsite", strings.NewReader(csv), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.RowsImported)
+	assert.Equal(t, []Duplicate{{Row: 1, EventID: "event-1"}, {Row: 4, DuplicateOf: 3}}, result.Duplicates, "Custom fields can be keys")
+
+	require.Len(t, stored, 2)
+	assert.Equal(t, "south", stored[0].CustomFields["region"])
+	assert.Equal(t, "Send invites", stored[1].Name)
+}
+
+func TestImporter_Import_RecordDuplicatesAcrossEvents(t *testing.T) {
+	repo := new(MockDuplicateRepo)
+	repo.On("ScanRecords", mock.Anything, "attendees").Return([]map[string]any{
+		{"event_id": "event-1", "email": "ada@example.com", "registered_on": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	})
+
+	schemas := newSchemaImporter(t, &repo.MockEventRepo).schemas
+	imp := New(repo)
+	imp.SetSchemas(schemas, repo)
+
+	csv := "name,email,registered_on\nAda,Ada@Example.com,2025-03-01\nGrace,grace@example.com,\n"
+	opts := Options{
+		Type:       "attendees",
+		DryRun:     true,
+		Duplicates: DuplicateOptions{Columns: []string{"email"}, AcrossEvents: true},
+	}
+
+	result, err := imp.Import(context.Background(), "Summit", strings.NewReader(csv), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Preview.Rows)
+	assert.Equal(t, 1, result.Preview.RowsDuplicated)
+	assert.Equal(t, []Duplicate{{Row: 1, EventID: "event-1"}}, result.Preview.Duplicates, "Emails are compared lower cased")
+	assert.Equal(t, [][]string{{"Grace", "grace@example.com", ""}}, result.Preview.Sample, "Skipped rows are not previewed")
+}
+
+func TestImporter_Import_DuplicateErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    Options
+		message string
+		details any
+	}{
+		{
+			name:    "policy",
+			opts:    Options{Duplicates: DuplicateOptions{Columns: []string{"todo_name"}, Policy: "merge"}},
+			message: "duplicate policy must be one of skip, keep, fail",
+			details: map[string]any{"policy": "merge"},
+		},
+		{
+			name:    "match",
+			opts:    Options{Duplicates: DuplicateOptions{Columns: []string{"todo_name"}, Match: "fuzzy"}},
+			message: "duplicate match must be one of exact, normalized",
+			details: map[string]any{"match": "fuzzy"},
+		},
+		{
+			name:    "custom column not kept",
+			opts:    Options{Duplicates: DuplicateOptions{Columns: []string{"todo_name", "region"}}},
+			message: "duplicate columns must be imported columns",
+			details: map[string]any{"columns": []string{"region"}},
+		},
+		{
+			name:    "column outside the schema",
+			opts:    Options{Type: "attendees", Duplicates: DuplicateOptions{Columns: []string{"todo_name"}}},
+			message: "duplicate columns must be imported columns",
+			details: map[string]any{"columns": []string{"todo_name"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := new(MockEventRepo)
+			csv := "todo_name,note,region,name,email\nBook venue,,north,Ada,ada@example.com\n"
+
+			_, err := newSchemaImporter(t, repo).Import(context.Background(), "Offsite", strings.NewReader(csv), tc.opts)
+
+			var appErr *apperr.Error
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, apperr.CodeValidation, appErr.Code)
+			assert.Equal(t, tc.message, appErr.Message)
+			assert.Equal(t, tc.details, appErr.Details)
+		})
+	}
+
+	_, err := New(new(MockEventRepo)).Import(context.Background(), "Offsite", strings.NewReader("todo_name,note\nBook venue,\n"),
+		Options{Duplicates: DuplicateOptions{Columns: []string{"todo_name"}, AcrossEvents: true}})
+	assert.ErrorContains(t, err, "cannot find duplicates across events", "Stores without scans cannot look across events")
+}
@@ -0,0 +1,367 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"csv-importer-backend/cmd/csv-importer/transform"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/gocarina/gocsv"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type IEventRepo interface {
+	GetEvent(ctx context.Context, id string) (*model.Event, error)
+	CreateEvent(ctx context.Context, event model.Event, todos ...model.TodoEvent) error
+	ListTodos(ctx context.Context, eventID string, filter model.TodoFilter) ([]model.TodoEvent, error)
+}
+
+type Result struct {
+	Event        model.Event `json:"event"`
+	RowsImported int         `json:"rows_imported"`
+	// RowsRejected counts the rows a partial import left out.
+	RowsRejected int `json:"rows_rejected"`
+	// RowsDuplicated counts the duplicate rows found, RowsSkipped those of
+	// them left out. Duplicates lists the first of them.
+	RowsDuplicated int         `json:"rows_duplicated"`
+	RowsSkipped    int         `json:"rows_skipped"`
+	Duplicates     []Duplicate `json:"duplicates,omitempty"`
+	// Preview is only set by dry runs, which store nothing.
+	Preview *Preview `json:"preview,omitempty"`
+}
+
+// Stats describes one finished import, successful or not.
+type Stats struct {
+	Duration     time.Duration
+	Bytes        int64
+	RowsImported int
+	RowsRejected int
+	Err          error
+}
+
+// Observer is told about every import, e.g. to export metrics.
+type Observer interface {
+	ImportFinished(stats Stats)
+}
+
+// Importer is the import and export pipeline shared by the HTTP API and the
+// command line.
+type Importer struct {
+	eventRepo IEventRepo
+	observer  Observer
+	schemas   *schema.Registry
+	records   IRecordRepo
+	now       func() time.Time
+}
+
+func New(eventRepo IEventRepo) *Importer {
+
+	return &Importer{
+		eventRepo: eventRepo,
+		now:       time.Now,
+	}
+}
+
+// SetObserver registers o to be told about every import.
+func (i *Importer) SetObserver(o Observer) {
+	i.observer = o
+}
+
+// Options tune a single import.
+type Options struct {
+	// Type names the import type, see SetSchemas. Empty means todos.
+	Type string
+	// KeepCustomFields stores the cells of columns without a todo field as
+	// the todo's custom fields instead of discarding them.
+	KeepCustomFields bool
+	// Transforms run after those of the import type, before validation.
+	Transforms []transform.Rule
+	// DryRun validates the file and returns a preview instead of storing
+	// it.
+	DryRun bool
+	// Duplicates finds rows repeating earlier ones.
+	Duplicates DuplicateOptions
+	// Mode is ModeStrict by default.
+	Mode string
+	// Source describes the uploaded file, to be stored with the event.
+	Source *model.ImportSource
+}
+
+// Import parses r as a CSV of the import type in opts and stores it as a new
+// event of the tenant in ctx. Malformed files are reported as validation
+// errors.
+func (i *Importer) Import(ctx context.Context, name string, r io.Reader, opts Options) (*Result, error) {
+
+	ctx, span := tracing.Start(ctx, "import")
+
+	start := i.now()
+	counter := &CountingReader{R: r}
+
+	result, rejected, err := i.importCSV(ctx, name, counter, opts)
+
+	stats := Stats{
+		Duration:     i.now().Sub(start),
+		Bytes:        counter.N,
+		RowsRejected: rejected,
+		Err:          err,
+	}
+	if result != nil && !opts.DryRun {
+		stats.RowsImported = result.RowsImported
+		span.SetAttributes(attribute.String("event.id", result.Event.ID))
+	}
+
+	span.SetAttributes(
+		attribute.Int64("import.bytes", stats.Bytes),
+		attribute.Int("import.rows_imported", stats.RowsImported),
+		attribute.Int("import.rows_rejected", stats.RowsRejected),
+		attribute.Bool("import.dry_run", opts.DryRun),
+	)
+	tracing.End(span, err)
+
+	// Dry runs are not imports
+	if i.observer != nil && !opts.DryRun {
+		i.observer.ImportFinished(stats)
+	}
+
+	return result, err
+}
+
+// importCSV also returns the number of rows rejected by validation.
+func (i *Importer) importCSV(ctx context.Context, name string, r io.Reader, opts Options) (*Result, int, error) {
+
+	err := checkMode(opts.Mode)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = opts.Duplicates.check()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if opts.Type != "" && opts.Type != model.ImportTypeTodos {
+		return i.importRecords(ctx, name, r, opts)
+	}
+
+	p, err := i.todoPipeline(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q := newQuarantine(opts)
+
+	file, err := parse(ctx, r, p, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if opts.KeepCustomFields && len(file.unmapped) > model.MaxCustomFields {
+		return nil, 0, apperr.Validation(fmt.Sprintf("csvfile has more than %d custom columns", model.MaxCustomFields)).
+			WithDetails(map[string]any{"custom_columns": len(file.unmapped)})
+	}
+
+	rows := file.rows
+
+	event, err := i.newEvent(ctx, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := event.CreateDate
+
+	_, span := tracing.Start(ctx, "import.validate", attribute.Int("csv.rows", len(rows)))
+
+	todos := make([]model.TodoEvent, 0, len(rows))
+	numbers := make([]int, 0, len(rows))
+	var invalid []RowError
+	rejected := 0
+
+	for n, row := range rows {
+		todo, rowErrs := convert(n+1, row)
+		if malformed := q.check(n); malformed != nil {
+			rowErrs = malformed
+		}
+		if opts.KeepCustomFields {
+			todo.CustomFields = file.customFields(n)
+		}
+		if len(rowErrs) > 0 {
+			rejected++
+			invalid = append(invalid, rowErrs...)
+			q.reject(n, rowErrs)
+			continue
+		}
+
+		todoID, err := uuid.NewV7()
+		if err != nil {
+			tracing.End(span, err)
+			return nil, 0, err
+		}
+
+		todo.ID = todoID.String()
+		todo.EventID = event.ID
+		todo.CreateDate = now
+		todo.UpdateDate = now
+		todos = append(todos, todo)
+		numbers = append(numbers, n+1)
+	}
+
+	if rejected > 0 && q == nil {
+		err := invalidRowsError(invalid, rejected)
+		tracing.End(span, err)
+		return nil, rejected, err
+	}
+
+	tracing.End(span, nil)
+
+	report, err := i.todoDuplicates(ctx, opts, file, todos, numbers)
+	if err != nil {
+		return nil, rejected + report.rows(), err
+	}
+	todos = keep(report, todos)
+
+	if opts.DryRun {
+		return &Result{Preview: newPreview(event.ImportType, file.header, selectRows(file.body, keep(report, numbers)), file.applied, report, q)}, rejected, nil
+	}
+
+	err = q.store(&event, now)
+	if err != nil {
+		return nil, 0, err
+	}
+	event.Source = opts.Source
+
+	storeCtx, span := tracing.Start(ctx, "import.store",
+		attribute.String("event.id", event.ID),
+		attribute.Int("import.todos", len(todos)),
+		attribute.Int("import.rows_rejected", rejected),
+	)
+
+	err = i.eventRepo.CreateEvent(storeCtx, event, todos...)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return report.result(&Result{
+		Event:        event,
+		RowsImported: len(todos),
+		RowsRejected: rejected,
+	}), rejected, nil
+}
+
+func (i *Importer) newEvent(ctx context.Context, name string) (model.Event, error) {
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	now := i.now()
+	return model.Event{
+		ID:         id.String(),
+		TenantID:   auth.TenantID(ctx),
+		Name:       name,
+		Status:     model.Created,
+		ImportType: model.ImportTypeTodos,
+		CreateDate: now,
+		UpdateDate: now,
+	}, nil
+}
+
+// parse reads a todo file and runs p over it before mapping the rows to
+// todos.
+func parse(ctx context.Context, r io.Reader, p *transform.Pipeline, q *quarantine) (*csvFile, error) {
+
+	header, body, err := parseRecords(ctx, r, q)
+	if err != nil {
+		return nil, err
+	}
+
+	header, body, applied, err := transformRecords(ctx, p, header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []model.TodoCSV
+	err = gocsv.UnmarshalCSV(&recordsReader{records: slices.Concat([][]string{header}, body)}, &rows)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	file := newCSVFile(rows, header, body)
+	file.applied = applied
+
+	return file, nil
+}
+
+// Export writes the rows of an event in the same CSV layout Import accepts.
+// For todos, the optional columns are only written when at least one todo
+// has a value for them, so files using the basic layout round-trip
+// unchanged. Custom fields are written as columns of their own after the
+// todo columns.
+func (i *Importer) Export(ctx context.Context, eventID string, w io.Writer) (*model.Event, error) {
+
+	event, err := i.eventRepo.GetEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.ImportType != "" && event.ImportType != model.ImportTypeTodos {
+		err = i.exportRecords(ctx, event, w)
+		if err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+
+	todos, err := i.eventRepo.ListTodos(ctx, event.ID, model.TodoFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeCSV(w, todos)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// CountingReader counts the bytes read from R in N.
+type CountingReader struct {
+	R io.Reader
+	N int64
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.R.Read(p)
+	c.N += int64(n)
+	return n, err
+}
+
+// parseError keeps the position reported by encoding/csv when there is one.
+func parseError(err error) error {
+
+	appErr := apperr.Validation("csvfile is not a valid csv document")
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return appErr.WithDetails(map[string]any{
+			"line":   parseErr.Line,
+			"column": parseErr.Column,
+			"reason": parseErr.Err.Error(),
+		})
+	}
+
+	return appErr.WithDetails(map[string]any{
+		"reason": err.Error(),
+	})
+}
@@ -0,0 +1,567 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/transform"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type MockEventRepo struct {
+	mock.Mock
+}
+
+func (m *MockEventRepo) GetEvent(ctx context.Context, id string) (*model.Event, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Event), args.Error(1)
+}
+
+func (m *MockEventRepo) CreateEvent(ctx context.Context, event model.Event, todos ...model.TodoEvent) error {
+	args := m.Called(ctx, event, todos)
+	return args.Error(0)
+}
+
+func (m *MockEventRepo) ListTodos(ctx context.Context, eventID string, filter model.TodoFilter) ([]model.TodoEvent, error) {
+	args := m.Called(ctx, eventID, filter)
+	return args.Get(0).([]model.TodoEvent), args.Error(1)
+}
+
+func (m *MockEventRepo) CreateEventRecords(ctx context.Context, event model.Event, table string, records []map[string]any) error {
+	args := m.Called(ctx, event, table, records)
+	return args.Error(0)
+}
+
+func (m *MockEventRepo) ListRecords(ctx context.Context, eventID string, table string) ([]map[string]any, error) {
+	args := m.Called(ctx, eventID, table)
+	return args.Get(0).([]map[string]any), args.Error(1)
+}
+
+func newSchemaImporter(t *testing.T, repo *MockEventRepo) *Importer {
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+
+	imp := New(repo)
+	imp.SetSchemas(schemas, repo)
+	return imp
+}
+
+func TestImporter_Import(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	var stored []model.TodoEvent
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]model.TodoEvent) }).
+		Return(nil)
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a", Role: auth.RoleImporter})
+	csv := "todo_name,note\nBuy groceries,Milk\nCall dentist,"
+
+	result, err := New(repo).Import(ctx, "Weekly", strings.NewReader(csv), Options{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.RowsImported)
+	assert.Equal(t, "Weekly", result.Event.Name)
+	assert.Equal(t, "tenant-a", result.Event.TenantID)
+	assert.Equal(t, model.Created, result.Event.Status)
+
+	require.Len(t, stored, 2)
+	assert.Equal(t, result.Event.ID, stored[0].EventID)
+	assert.Equal(t, "Buy groceries", stored[0].Name)
+	assert.Equal(t, "Milk", stored[0].Note)
+	assert.NotEqual(t, stored[0].ID, stored[1].ID)
+}
+
+func TestImporter_Import_TypedColumns(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	var stored []model.TodoEvent
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]model.TodoEvent) }).
+		Return(nil)
+
+	csv := "todo_name,note,status,priority,due_date,assignee_email,tags\n" +
+		"Book venue,,Done,high,3 Mar 2025,Alice@Example.com,\"venue, Budget\"\n" +
+		"Send invites,,,,,,\n"
+
+	_, err := New(repo).Import(context.Background(), "Offsite", strings.NewReader(csv), Options{})
+	require.NoError(t, err)
+
+	require.Len(t, stored, 2)
+	assert.Equal(t, model.TodoDone, stored[0].Status)
+	assert.Equal(t, model.PriorityHigh, stored[0].Priority)
+	assert.Equal(t, time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), *stored[0].DueDate)
+	assert.Equal(t, "alice@example.com", stored[0].AssigneeEmail)
+	assert.Equal(t, model.Tags{"venue", "budget"}, stored[0].Tags)
+
+	assert.Equal(t, model.TodoOpen, stored[1].Status)
+	assert.Empty(t, stored[1].Priority)
+	assert.Nil(t, stored[1].DueDate)
+	assert.Empty(t, stored[1].AssigneeEmail)
+	assert.Nil(t, stored[1].Tags)
+}
+
+func TestImporter_Import_InvalidRows(t *testing.T) {
+	repo := new(MockEventRepo)
+	observer := &recordingObserver{}
+	imp := New(repo)
+	imp.SetObserver(observer)
+
+	csv := "todo_name,note,status,priority,due_date,assignee_email\n" +
+		"Book venue,,open,high,2025-03-03,alice@example.com\n" +
+		"Send invites,,paused,,next week,\n" +
+		"Order food,,,urgent,,bob\n"
+
+	_, err := imp.Import(context.Background(), "Offsite", strings.NewReader(csv), Options{})
+
+	var appErr *apperr.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperr.CodeValidation, appErr.Code)
+	assert.Equal(t, "csvfile has invalid rows", appErr.Message)
+
+	details := appErr.Details.(map[string]any)
+	assert.Equal(t, 2, details["rows_rejected"])
+	assert.Equal(t, []RowError{
+		{Row: 2, Column: "status", Reason: "status must be one of open, done"},
+		{Row: 2, Column: "due_date", Reason: "due_date is not a date, use e.g. 2006-01-02"},
+		{Row: 3, Column: "priority", Reason: "priority must be one of low, medium, high"},
+		{Row: 3, Column: "assignee_email", Reason: "assignee_email is not an email address"},
+	}, details["errors"])
+
+	require.Len(t, observer.stats, 1)
+	assert.Equal(t, 2, observer.stats[0].RowsRejected)
+	repo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImporter_Import_CustomFields(t *testing.T) {
+	csv := "\uFEFFtodo_name, Region ,note,,cost,cost\n" +
+		"Book venue,north,Downtown,ignored,120,999\n" +
+		"Send invites,,,,,\n"
+
+	testCases := []struct {
+		name     string
+		opts     Options
+		expected []model.CustomFields
+	}{
+		{"discarded by default", Options{}, []model.CustomFields{nil, nil}},
+		{"kept", Options{KeepCustomFields: true}, []model.CustomFields{{"Region": "north", "cost": "120"}, nil}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := new(MockEventRepo)
+
+			var stored []model.TodoEvent
+			repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { stored = args.Get(2).([]model.TodoEvent) }).
+				Return(nil)
+
+			_, err := New(repo).Import(context.Background(), "Offsite", strings.NewReader(csv), tc.opts)
+			require.NoError(t, err)
+
+			require.Len(t, stored, 2)
+			assert.Equal(t, "Book venue", stored[0].Name, "Mapped columns are unaffected")
+			assert.Equal(t, "Downtown", stored[0].Note)
+			assert.Equal(t, tc.expected[0], stored[0].CustomFields, "Blank headers and repeated headers are skipped")
+			assert.Equal(t, tc.expected[1], stored[1].CustomFields, "Empty cells are not kept")
+		})
+	}
+}
+
+func TestImporter_Import_TooManyCustomFields(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	header := []string{"todo_name", "note"}
+	row := []string{"Task", "Note"}
+	for i := 0; i <= model.MaxCustomFields; i++ {
+		header = append(header, fmt.Sprintf("extra_%d", i))
+		row = append(row, "x")
+	}
+	csv := strings.Join(header, ",") + "\n" + strings.Join(row, ",") + "\n"
+
+	_, err := New(repo).Import(context.Background(), "Wide", strings.NewReader(csv), Options{KeepCustomFields: true})
+
+	var appErr *apperr.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperr.CodeValidation, appErr.Code)
+	repo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImporter_Import_DeclarativeType(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	var stored []map[string]any
+	repo.On("CreateEventRecords", mock.Anything, mock.Anything, "attendees", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(3).([]map[string]any) }).
+		Return(nil)
+
+	csv := "email,name,shoe_size,checked_in,registered_on\n" +
+		"Ada@Example.com,Ada,38,TRUE,3 Mar 2025\n" +
+		"grace@example.com,Grace,,,\n"
+
+	result, err := newSchemaImporter(t, repo).Import(context.Background(), "Summit", strings.NewReader(csv), Options{Type: "attendees"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.RowsImported)
+	assert.Equal(t, "attendees", result.Event.ImportType)
+
+	require.Len(t, stored, 2)
+	assert.NotEmpty(t, stored[0]["id"])
+	assert.Equal(t, result.Event.CreateDate, stored[0]["create_date"])
+	assert.Equal(t, "Ada", stored[0]["name"])
+	assert.Equal(t, "ada@example.com", stored[0]["email"])
+	assert.Equal(t, true, stored[0]["checked_in"])
+	assert.Equal(t, time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), stored[0]["registered_on"])
+	assert.Nil(t, stored[1]["checked_in"])
+	assert.Contains(t, stored[1], "company", "Missing optional columns are stored empty")
+	assert.NotContains(t, stored[0], "shoe_size", "Columns outside the schema are ignored")
+	repo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImporter_Import_DeclarativeTypeErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    Options
+		csv     string
+		message string
+		details any
+	}{
+		{
+			name:    "unknown type",
+			opts:    Options{Type: "inventory"},
+			csv:     "sku\nABC\n",
+			message: "unknown import type",
+			details: map[string]any{"type": "inventory"},
+		},
+		{
+			name:    "custom fields",
+			opts:    Options{Type: "attendees", KeepCustomFields: true},
+			csv:     "name,email\nAda,ada@example.com\n",
+			message: "custom fields are only kept for todos",
+			details: map[string]any{"type": "attendees"},
+		},
+		{
+			name:    "missing required columns",
+			opts:    Options{Type: "attendees"},
+			csv:     "company\nEngines\n",
+			message: "csvfile is missing required columns",
+			details: map[string]any{"columns": []string{"name", "email"}},
+		},
+		{
+			name:    "invalid transforms",
+			opts:    Options{Type: "attendees", Transforms: []transform.Rule{{Op: "upper", Column: "name"}}},
+			csv:     "name,email\nAda,ada@example.com\n",
+			message: "transforms are invalid",
+			details: map[string]any{"reason": "transform 1 (upper): op must be one of trim, collapse_whitespace, title_case, replace, default, split, merge, date_format"},
+		},
+		{
+			name:    "missing transform column",
+			opts:    Options{Type: "attendees", Transforms: []transform.Rule{{Op: transform.OpTitleCase, Column: "full_name"}}},
+			csv:     "name,email\nAda,ada@example.com\n",
+			message: "csvfile has no column for transform",
+			details: map[string]any{"transform": 1, "op": "title_case", "column": "full_name"},
+		},
+		{
+			name:    "invalid rows",
+			opts:    Options{Type: "attendees"},
+			csv:     "name,email,ticket_type\nAda,ada@example.com,vip\n,ada,gold\n",
+			message: "csvfile has invalid rows",
+			details: map[string]any{"rows_rejected": 1, "errors": []RowError{
+				{Row: 2, Column: "name", Reason: "name is required"},
+				{Row: 2, Column: "email", Reason: "email is not an email address"},
+				{Row: 2, Column: "ticket_type", Reason: "ticket_type must be one of standard, vip, speaker, staff"},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := new(MockEventRepo)
+
+			_, err := newSchemaImporter(t, repo).Import(context.Background(), "Summit", strings.NewReader(tc.csv), tc.opts)
+
+			var appErr *apperr.Error
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, apperr.CodeValidation, appErr.Code)
+			assert.Equal(t, tc.message, appErr.Message)
+			assert.Equal(t, tc.details, appErr.Details)
+			repo.AssertNotCalled(t, "CreateEventRecords", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestImporter_Import_Transforms(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	var stored []model.TodoEvent
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]model.TodoEvent) }).
+		Return(nil)
+
+	csv := "todo_name,note,due_date\n  buy   MILK ,,13/4/2025\ncall dentist,Soon,n/a\n"
+	opts := Options{Transforms: []transform.Rule{
+		{Op: transform.OpCollapseWhitespace, Column: "todo_name"},
+		{Op: transform.OpTitleCase, Column: "todo_name"},
+		{Op: transform.OpDefault, Column: "note", Value: "-"},
+		{Op: transform.OpReplace, Column: "due_date", Pattern: "^n/a$"},
+		{Op: transform.OpDateFormat, Column: "due_date", Layout: "2/1/2006"},
+	}}
+
+	result, err := New(repo).Import(context.Background(), "Weekly", strings.NewReader(csv), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.RowsImported)
+	assert.Nil(t, result.Preview)
+
+	require.Len(t, stored, 2)
+	assert.Equal(t, "Buy Milk", stored[0].Name)
+	assert.Equal(t, "-", stored[0].Note)
+	require.NotNil(t, stored[0].DueDate)
+	assert.Equal(t, time.Date(2025, time.April, 13, 0, 0, 0, 0, time.UTC), *stored[0].DueDate)
+	assert.Equal(t, "Call Dentist", stored[1].Name)
+	assert.Nil(t, stored[1].DueDate)
+}
+
+func TestImporter_Import_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "speakers.yaml"), []byte(`
+name: speakers
+table: speakers
+transforms:
+  - op: trim
+    column: "*"
+columns:
+  - name: name
+    type: string
+    required: true
+  - name: talk
+    type: string
+`), 0o600))
+	schemas, err := schema.Load(dir)
+	require.NoError(t, err)
+
+	repo := new(MockEventRepo)
+	observer := &recordingObserver{}
+	imp := New(repo)
+	imp.SetSchemas(schemas, repo)
+	imp.SetObserver(observer)
+
+	csv := "first, last ,talk\n Ada,Lovelace , Engines \nGrace,Hopper,Compilers\n"
+	opts := Options{
+		Type:   "speakers",
+		DryRun: true,
+		Transforms: []transform.Rule{
+			{Op: transform.OpMerge, Columns: []string{"first", "last"}, Into: []string{"name"}},
+		},
+	}
+
+	result, err := imp.Import(context.Background(), "Summit", strings.NewReader(csv), opts)
+
+	require.NoError(t, err)
+	assert.Zero(t, result.RowsImported)
+	assert.Equal(t, &Preview{
+		ImportType: "speakers",
+		Rows:       2,
+		Header:     []string{"first", "last", "talk", "name"},
+		Sample:     [][]string{{"Ada", "Lovelace", "Engines", "Ada Lovelace"}, {"Grace", "Hopper", "Compilers", "Grace Hopper"}},
+		Transforms: []transform.Applied{
+			{Rule: transform.Rule{Op: transform.OpTrim, Column: "*"}, CellsChanged: 3},
+			{Rule: opts.Transforms[0], CellsChanged: 2},
+		},
+	}, result.Preview, "The import type's transforms run first")
+
+	repo.AssertNotCalled(t, "CreateEventRecords", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Empty(t, observer.stats, "Dry runs are not imports")
+
+	_, err = imp.Import(context.Background(), "Summit", strings.NewReader("first,last,talk\nAda,,\n"), Options{Type: "speakers", DryRun: true})
+	var appErr *apperr.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "csvfile is missing required columns", appErr.Message, "Dry runs validate like imports")
+}
+
+func TestImporter_Import_MalformedCSV(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	_, err := New(repo).Import(context.Background(), "Broken", strings.NewReader("todo_name,note\n\"unclosed,quote"), Options{})
+
+	var appErr *apperr.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperr.CodeValidation, appErr.Code)
+	assert.Contains(t, appErr.Details, "line")
+	repo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImporter_Import_RepositoryError(t *testing.T) {
+	repo := new(MockEventRepo)
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("database connection failed"))
+
+	_, err := New(repo).Import(context.Background(), "Weekly", strings.NewReader("todo_name,note\nTask,Note"), Options{})
+
+	assert.EqualError(t, err, "database connection failed")
+}
+
+type recordingObserver struct {
+	stats []Stats
+}
+
+func (o *recordingObserver) ImportFinished(stats Stats) {
+	o.stats = append(o.stats, stats)
+}
+
+func TestImporter_Import_Observer(t *testing.T) {
+	repo := new(MockEventRepo)
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+	observer := &recordingObserver{}
+	imp := New(repo)
+	imp.SetObserver(observer)
+
+	csv := "todo_name,note\nBuy groceries,Milk\n"
+	_, err := imp.Import(context.Background(), "Weekly", strings.NewReader(csv), Options{})
+	require.NoError(t, err)
+
+	_, err = imp.Import(context.Background(), "Broken", strings.NewReader("todo_name,note\n\"unterminated"), Options{})
+	require.Error(t, err)
+
+	require.Len(t, observer.stats, 2)
+	assert.Equal(t, int64(len(csv)), observer.stats[0].Bytes)
+	assert.Equal(t, 1, observer.stats[0].RowsImported)
+	assert.NoError(t, observer.stats[0].Err)
+
+	assert.Zero(t, observer.stats[1].RowsImported)
+	assert.Error(t, observer.stats[1].Err)
+}
+
+func TestImporter_Import_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var storeSpan trace.SpanContext
+	repo := new(MockEventRepo)
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { storeSpan = trace.SpanContextFromContext(args.Get(0).(context.Context)) }).
+		Return(nil)
+
+	result, err := New(repo).Import(context.Background(), "Weekly", strings.NewReader("todo_name,note\nA,1\nB,2\n"), Options{})
+	require.NoError(t, err)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Contains(t, spans, "import")
+	root := spans["import"].SpanContext().SpanID()
+
+	for _, name := range []string{"csv.parse", "import.validate", "import.store"} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, root, spans[name].Parent().SpanID(), name)
+	}
+
+	assert.Equal(t, spans["import.store"].SpanContext().SpanID(), storeSpan.SpanID(), "Queries run under the store span")
+	assert.Contains(t, spans["import"].Attributes(), attribute.String("event.id", result.Event.ID))
+	assert.Contains(t, spans["import"].Attributes(), attribute.Int("import.rows_imported", 2))
+	assert.Contains(t, spans["csv.parse"].Attributes(), attribute.Int("csv.rows", 2))
+}
+
+func TestImporter_Export(t *testing.T) {
+	repo := new(MockEventRepo)
+	repo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	repo.On("ListTodos", mock.Anything, "event-1", model.TodoFilter{}).Return([]model.TodoEvent{
+		{Name: "Buy groceries", Note: "Milk, bread"},
+		{Name: "Call dentist"},
+	}, nil)
+
+	var buf bytes.Buffer
+	event, err := New(repo).Export(context.Background(), "event-1", &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, "event-1", event.ID)
+	assert.Equal(t, "todo_name,note\nBuy groceries,\"Milk, bread\"\nCall dentist,\n", buf.String())
+}
+
+func TestImporter_Export_TypedColumns(t *testing.T) {
+	due := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+	repo := new(MockEventRepo)
+	repo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	repo.On("ListTodos", mock.Anything, "event-1", model.TodoFilter{}).Return([]model.TodoEvent{
+		{Name: "Book venue", Status: model.TodoDone, DueDate: &due, Tags: model.Tags{"venue", "budget"}},
+		{Name: "Send invites", Status: model.TodoOpen},
+	}, nil)
+
+	var buf bytes.Buffer
+	_, err := New(repo).Export(context.Background(), "event-1", &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, "todo_name,note,status,due_date,tags\nBook venue,,done,2025-03-03,\"venue,budget\"\nSend invites,,,,\n", buf.String(),
+		"Only columns with values are written")
+}
+
+func TestImporter_Export_CustomFields(t *testing.T) {
+	repo := new(MockEventRepo)
+	repo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1"}, nil)
+	repo.On("ListTodos", mock.Anything, "event-1", model.TodoFilter{}).Return([]model.TodoEvent{
+		{Name: "Book venue", CustomFields: model.CustomFields{"region": "north", "cost": "120"}},
+		{Name: "Send invites", CustomFields: model.CustomFields{"owner": "bob"}},
+		{Name: "Order food"},
+	}, nil)
+
+	var buf bytes.Buffer
+	_, err := New(repo).Export(context.Background(), "event-1", &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, "todo_name,note,cost,owner,region\nBook venue,,120,,north\nSend invites,,,bob,\nOrder food,,,,\n", buf.String())
+}
+
+func TestImporter_Export_UnknownEvent(t *testing.T) {
+	repo := new(MockEventRepo)
+	repo.On("GetEvent", mock.Anything, "missing").Return(nil, gorm.ErrRecordNotFound)
+
+	var buf bytes.Buffer
+	_, err := New(repo).Export(context.Background(), "missing", &buf)
+
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Empty(t, buf.String())
+}
+
+func TestImporter_Export_DeclarativeType(t *testing.T) {
+	repo := new(MockEventRepo)
+	repo.On("GetEvent", mock.Anything, "event-1").Return(&model.Event{ID: "event-1", ImportType: "attendees"}, nil)
+	repo.On("ListRecords", mock.Anything, "event-1", "attendees").Return([]map[string]any{
+		{"id": "row-1", "name": "Ada", "email": "ada@example.com", "checked_in": int64(1), "registered_on": time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)},
+		{"id": "row-2", "name": "Grace", "email": "grace@example.com", "company": "Navy, Reserve", "checked_in": nil},
+	}, nil)
+
+	var buf bytes.Buffer
+	_, err := newSchemaImporter(t, repo).Export(context.Background(), "event-1", &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, "name,email,company,ticket_type,checked_in,registered_on\n"+
+		"Ada,ada@example.com,,,true,2025-03-03\n"+
+		"Grace,grace@example.com,\"Navy, Reserve\",,,\n", buf.String())
+	repo.AssertNotCalled(t, "ListTodos", mock.Anything, mock.Anything, mock.Anything)
+}
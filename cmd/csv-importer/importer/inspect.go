@@ -0,0 +1,389 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"encoding/csv"
+	"errors"
+	"hash/maphash"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gocarina/gocsv"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Inferred column types, from most to least specific.
+const (
+	InferredInteger  = "integer"
+	InferredDecimal  = "decimal"
+	InferredBoolean  = "boolean"
+	InferredDate     = "date"
+	InferredDateTime = "datetime"
+	InferredUUID     = "uuid"
+	InferredEmail    = "email"
+	InferredText     = "text"
+	// InferredEmpty columns have no values at all.
+	InferredEmpty = "empty"
+)
+
+const (
+	maxSamples      = 5
+	maxSampleLength = 100
+	// distinctSketchSize is the number of hashes kept per column. Counts up
+	// to this size are exact, larger ones are estimates within a few percent.
+	distinctSketchSize = 1024
+)
+
+// Layouts are tried in this order and the first one matching every value of
+// a column is reported. Unlike due dates, both day/month orders are listed,
+// as a file's layout is what is being found out here.
+var (
+	dateLayouts = []string{
+		"2006-01-02",
+		"2006/01/02",
+		"1/2/2006",
+		"2/1/2006",
+		"2.1.2006",
+		"2 Jan 2006",
+		"2 January 2006",
+		"Jan 2, 2006",
+		"January 2, 2006",
+	}
+	dateTimeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"1/2/2006 15:04",
+		"2/1/2006 15:04",
+		time.RFC1123Z,
+		time.RFC1123,
+	}
+)
+
+// Report describes every column of an inspected file.
+type Report struct {
+	Rows    int            `json:"rows"`
+	Columns []ColumnReport `json:"columns"`
+}
+
+type ColumnReport struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	// SchemaType is the import type column type that holds every value.
+	SchemaType string `json:"schema_type"`
+	// Layout is the time layout of date and datetime columns, in Go
+	// reference time notation. AlternativeLayouts also match every value,
+	// e.g. when the order of day and month cannot be told apart.
+	Layout             string   `json:"layout,omitempty"`
+	AlternativeLayouts []string `json:"alternative_layouts,omitempty"`
+	Nulls              int      `json:"nulls"`
+	NullRatio          float64  `json:"null_ratio"`
+	DistinctEstimate   int      `json:"distinct_estimate"`
+	MinLength          int      `json:"min_length"`
+	MaxLength          int      `json:"max_length"`
+	Samples            []string `json:"samples"`
+}
+
+// Inspect reads a CSV file of unknown layout row by row and reports the type
+// and shape of every column. Empty cells are nulls; lengths count
+// characters.
+func Inspect(ctx context.Context, r io.Reader) (*Report, error) {
+
+	_, span := tracing.Start(ctx, "csv.inspect")
+
+	report, err := inspect(r)
+	if report != nil {
+		span.SetAttributes(
+			attribute.Int("csv.rows", report.Rows),
+			attribute.Int("csv.columns", len(report.Columns)),
+		)
+	}
+	tracing.End(span, err)
+
+	return report, err
+}
+
+func inspect(r io.Reader) (*Report, error) {
+
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		err = gocsv.ErrEmptyCSVFile
+	}
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	seed := maphash.MakeSeed()
+	columns := make([]*columnStats, len(header))
+	for i, h := range header {
+		columns[i] = newColumnStats(columnName(h), seed)
+	}
+
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, parseError(err)
+		}
+
+		rows++
+		for i, cell := range record {
+			columns[i].add(cell)
+		}
+	}
+
+	report := &Report{
+		Rows:    rows,
+		Columns: make([]ColumnReport, len(columns)),
+	}
+	for i, c := range columns {
+		report.Columns[i] = c.report(i, rows)
+	}
+
+	return report, nil
+}
+
+const (
+	maybeInteger = 1 << iota
+	maybeDecimal
+	maybeBoolean
+	maybeUUID
+	maybeEmail
+)
+
+type columnStats struct {
+	name     string
+	values   int
+	nulls    int
+	minLen   int
+	maxLen   int
+	maybe    int
+	dates    []string
+	times    []string
+	samples  []string
+	distinct *distinctSketch
+}
+
+func newColumnStats(name string, seed maphash.Seed) *columnStats {
+
+	return &columnStats{
+		name:     name,
+		maybe:    maybeInteger | maybeDecimal | maybeBoolean | maybeUUID | maybeEmail,
+		dates:    dateLayouts,
+		times:    dateTimeLayouts,
+		distinct: newDistinctSketch(seed),
+	}
+}
+
+func (c *columnStats) add(cell string) {
+
+	v := strings.TrimSpace(cell)
+	if v == "" {
+		c.nulls++
+		return
+	}
+
+	n := utf8.RuneCountInString(v)
+	if c.values == 0 || n < c.minLen {
+		c.minLen = n
+	}
+	if n > c.maxLen {
+		c.maxLen = n
+	}
+	c.values++
+
+	if c.distinct.add(v) && len(c.samples) < maxSamples {
+		c.samples = append(c.samples, truncate(v, maxSampleLength))
+	}
+
+	if c.maybe&(maybeInteger|maybeDecimal) != 0 && hasLeadingZero(v) {
+		// Codes such as 007 or 01234 would lose their zeros as numbers
+		c.maybe &^= maybeInteger | maybeDecimal
+	}
+	if c.maybe&maybeInteger != 0 {
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			c.maybe &^= maybeInteger
+		}
+	}
+	if c.maybe&maybeDecimal != 0 {
+		if f, err := strconv.ParseFloat(v, 64); err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			c.maybe &^= maybeDecimal
+		}
+	}
+	if c.maybe&maybeBoolean != 0 {
+		if _, err := strconv.ParseBool(strings.ToLower(v)); err != nil {
+			c.maybe &^= maybeBoolean
+		}
+	}
+	if c.maybe&maybeUUID != 0 {
+		if len(v) != 36 || uuid.Validate(v) != nil {
+			c.maybe &^= maybeUUID
+		}
+	}
+	if c.maybe&maybeEmail != 0 {
+		if _, err := model.ParseEmail(v); err != nil {
+			c.maybe &^= maybeEmail
+		}
+	}
+
+	c.dates = matchingLayouts(c.dates, v)
+	c.times = matchingLayouts(c.times, v)
+}
+
+func (c *columnStats) report(index int, rows int) ColumnReport {
+
+	report := ColumnReport{
+		Index:            index,
+		Name:             c.name,
+		Nulls:            c.nulls,
+		DistinctEstimate: c.distinct.estimate(),
+		MinLength:        c.minLen,
+		MaxLength:        c.maxLen,
+		Samples:          c.samples,
+	}
+
+	if report.Samples == nil {
+		report.Samples = []string{}
+	}
+	if rows > 0 {
+		report.NullRatio = float64(c.nulls) / float64(rows)
+	}
+
+	layouts := func(layouts []string) {
+		report.Layout = layouts[0]
+		report.AlternativeLayouts = layouts[1:]
+	}
+
+	switch {
+	case c.values == 0:
+		report.Type, report.SchemaType = InferredEmpty, schema.TypeString
+	case c.maybe&maybeInteger != 0:
+		report.Type, report.SchemaType = InferredInteger, schema.TypeInteger
+	case c.maybe&maybeDecimal != 0:
+		report.Type, report.SchemaType = InferredDecimal, schema.TypeNumber
+	case c.maybe&maybeBoolean != 0:
+		report.Type, report.SchemaType = InferredBoolean, schema.TypeBoolean
+	case len(c.dates) > 0:
+		report.Type, report.SchemaType = InferredDate, schema.TypeDate
+		layouts(c.dates)
+	case len(c.times) > 0:
+		report.Type, report.SchemaType = InferredDateTime, schema.TypeDate
+		layouts(c.times)
+	case c.maybe&maybeUUID != 0:
+		report.Type, report.SchemaType = InferredUUID, schema.TypeString
+	case c.maybe&maybeEmail != 0:
+		report.Type, report.SchemaType = InferredEmail, schema.TypeEmail
+	default:
+		report.Type, report.SchemaType = InferredText, schema.TypeString
+	}
+
+	return report
+}
+
+// matchingLayouts returns the layouts v parses with. The result shares the
+// backing array of layouts only when nothing was dropped.
+func matchingLayouts(layouts []string, v string) []string {
+
+	for i, layout := range layouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			continue
+		}
+
+		matching := append([]string{}, layouts[:i]...)
+		for _, layout := range layouts[i+1:] {
+			if _, err := time.Parse(layout, v); err == nil {
+				matching = append(matching, layout)
+			}
+		}
+		return matching
+	}
+
+	return layouts
+}
+
+func hasLeadingZero(v string) bool {
+
+	v = strings.TrimLeft(v, "+-")
+	return len(v) > 1 && v[0] == '0' && v[1] >= '0' && v[1] <= '9'
+}
+
+func truncate(v string, n int) string {
+
+	if utf8.RuneCountInString(v) <= n {
+		return v
+	}
+
+	return string([]rune(v)[:n])
+}
+
+// distinctSketch estimates the number of distinct values with the k minimum
+// values of their hashes, in constant memory.
+type distinctSketch struct {
+	seed   maphash.Seed
+	hashes map[uint64]struct{}
+	max    uint64
+}
+
+func newDistinctSketch(seed maphash.Seed) *distinctSketch {
+
+	return &distinctSketch{
+		seed:   seed,
+		hashes: make(map[uint64]struct{}),
+	}
+}
+
+// add reports whether v is certainly new. Values whose hash is not among the
+// smallest ones are reported as not new.
+func (s *distinctSketch) add(v string) bool {
+
+	h := maphash.String(s.seed, v)
+	if _, ok := s.hashes[h]; ok {
+		return false
+	}
+
+	if len(s.hashes) < distinctSketchSize {
+		s.hashes[h] = struct{}{}
+		s.max = max(s.max, h)
+		return true
+	}
+
+	if h > s.max {
+		return false
+	}
+
+	delete(s.hashes, s.max)
+	s.hashes[h] = struct{}{}
+
+	s.max = 0
+	for kept := range s.hashes {
+		s.max = max(s.max, kept)
+	}
+
+	return true
+}
+
+func (s *distinctSketch) estimate() int {
+
+	if len(s.hashes) < distinctSketchSize {
+		return len(s.hashes)
+	}
+
+	// The k-th smallest of n uniform hashes is expected at k/n of the range
+	return int(float64(distinctSketchSize-1) / (float64(s.max) / math.MaxUint64))
+}
@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	data := "id,amount,qty,active,joined,seen,email,zip,ref,note,blank\n" +
+		"1,9.50,3,yes,03/04/2025,2025-03-04 10:00,ada@example.com,01234,0190a3c4-7b1e-7c2d-9f00-000000000001,hello,\n" +
+		"2,12,4,no,13/04/2025,2025-03-05 11:30,bob@example.com,02345,0190a3c4-7b1e-7c2d-9f00-000000000002,,\n" +
+		"3,1e3,5,yes,,2025-03-06 12:45, eve@example.com ,03456,0190a3c4-7b1e-7c2d-9f00-000000000003,hello,\n"
+
+	report, err := Inspect(context.Background(), strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Rows)
+	require.Len(t, report.Columns, 11)
+
+	columns := map[string]ColumnReport{}
+	for _, c := range report.Columns {
+		columns[c.Name] = c
+	}
+
+	types := map[string]string{}
+	for name, c := range columns {
+		types[name] = c.Type
+	}
+	assert.Equal(t, map[string]string{
+		"id":     InferredInteger,
+		"amount": InferredDecimal,
+		"qty":    InferredInteger,
+		"active": InferredText,
+		"joined": InferredDate,
+		"seen":   InferredDateTime,
+		"email":  InferredEmail,
+		"zip":    InferredText,
+		"ref":    InferredUUID,
+		"note":   InferredText,
+		"blank":  InferredEmpty,
+	}, types, "yes/no is not a boolean, zip codes keep their leading zeros")
+
+	joined := columns["joined"]
+	assert.Equal(t, "2/1/2006", joined.Layout, "13/04 rules out month first")
+	assert.Empty(t, joined.AlternativeLayouts)
+	assert.Equal(t, 1, joined.Nulls)
+	assert.InDelta(t, 1.0/3, joined.NullRatio, 0.001)
+	assert.Equal(t, "date", joined.SchemaType)
+
+	assert.Equal(t, "2006-01-02 15:04", columns["seen"].Layout)
+	assert.Equal(t, "number", columns["amount"].SchemaType)
+
+	note := columns["note"]
+	assert.Equal(t, 1, note.DistinctEstimate)
+	assert.Equal(t, []string{"hello"}, note.Samples)
+	assert.Equal(t, 5, note.MinLength)
+	assert.Equal(t, 5, note.MaxLength)
+
+	email := columns["email"]
+	assert.Equal(t, []string{"ada@example.com", "bob@example.com", "eve@example.com"}, email.Samples)
+	assert.Equal(t, 15, email.MaxLength, "Cells are trimmed")
+
+	blank := columns["blank"]
+	assert.Equal(t, 3, blank.Nulls)
+	assert.Equal(t, 1.0, blank.NullRatio)
+	assert.Equal(t, []string{}, blank.Samples)
+	assert.Equal(t, "string", blank.SchemaType)
+}
+
+func TestInspect_AmbiguousLayouts(t *testing.T) {
+	report, err := Inspect(context.Background(), strings.NewReader("when,flag\n03/04/2025,1\n05/06/2025,0\n"))
+	require.NoError(t, err)
+
+	when := report.Columns[0]
+	assert.Equal(t, InferredDate, when.Type)
+	assert.Equal(t, "1/2/2006", when.Layout)
+	assert.Equal(t, []string{"2/1/2006"}, when.AlternativeLayouts)
+
+	assert.Equal(t, InferredInteger, report.Columns[1].Type, "0 and 1 are integers before booleans")
+}
+
+func TestInspect_DistinctEstimate(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("code\n")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&b, "code-%d\n", i%10000)
+	}
+
+	report, err := Inspect(context.Background(), strings.NewReader(b.String()))
+	require.NoError(t, err)
+
+	code := report.Columns[0]
+	assert.Equal(t, 20000, report.Rows)
+	assert.InDelta(t, 10000, code.DistinctEstimate, 1000)
+	assert.Len(t, code.Samples, maxSamples)
+	assert.Equal(t, 6, code.MinLength)
+	assert.Equal(t, 9, code.MaxLength)
+}
+
+func TestInspect_MalformedCSV(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"unclosed quote", "a,b\n\"unclosed,quote"},
+		{"ragged row", "a,b\n1,2,3\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Inspect(context.Background(), strings.NewReader(tc.data))
+
+			var appErr *apperr.Error
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, apperr.CodeValidation, appErr.Code)
+		})
+	}
+}
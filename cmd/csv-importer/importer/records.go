@@ -0,0 +1,255 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/gocarina/gocsv"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// IRecordRepo stores the rows of declarative import types.
+type IRecordRepo interface {
+	CreateEventRecords(ctx context.Context, event model.Event, table string, records []map[string]any) error
+	ListRecords(ctx context.Context, eventID string, table string) ([]map[string]any, error)
+}
+
+// SetSchemas enables the declarative import types of registry, whose rows
+// are stored through records.
+func (i *Importer) SetSchemas(registry *schema.Registry, records IRecordRepo) {
+	i.schemas = registry
+	i.records = records
+}
+
+// lookupSchema returns the declarative import type called name.
+func (i *Importer) lookupSchema(name string) (*schema.Schema, error) {
+
+	s, ok := i.schemas.Get(name)
+	if ok {
+		return s, nil
+	}
+
+	return nil, apperr.Validation("unknown import type").
+		WithDetails(map[string]any{"type": name})
+}
+
+// importRecords is importCSV for declarative import types: every column of
+// the schema is validated and converted, other columns are ignored.
+func (i *Importer) importRecords(ctx context.Context, name string, r io.Reader, opts Options) (*Result, int, error) {
+
+	s, err := i.lookupSchema(opts.Type)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if opts.KeepCustomFields {
+		return nil, 0, apperr.Validation("custom fields are only kept for todos").
+			WithDetails(map[string]any{"type": s.Name})
+	}
+
+	p, err := pipeline(s, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q := newQuarantine(opts)
+
+	header, body, err := parseRecords(ctx, r, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header, body, applied, err := transformRecords(ctx, p, header, body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	index := map[string]int{}
+	for n, name := range header {
+		if _, ok := index[name]; !ok {
+			index[name] = n
+		}
+	}
+
+	var missing []string
+	for _, c := range s.Columns {
+		if _, ok := index[c.Name]; !ok && c.Required {
+			missing = append(missing, c.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, 0, apperr.Validation("csvfile is missing required columns").
+			WithDetails(map[string]any{"columns": missing})
+	}
+
+	event, err := i.newEvent(ctx, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	event.ImportType = s.Name
+
+	_, span := tracing.Start(ctx, "import.validate", attribute.Int("csv.rows", len(body)))
+
+	records := make([]map[string]any, 0, len(body))
+	numbers := make([]int, 0, len(body))
+	var invalid []RowError
+	rejected := 0
+
+	for n, row := range body {
+		record := make(map[string]any, len(s.Columns)+4)
+		rowErrs := q.check(n)
+
+		for _, c := range s.Columns {
+			cell := ""
+			if col, ok := index[c.Name]; ok {
+				cell = row[col]
+			}
+
+			v, err := c.Parse(cell)
+			if err != nil {
+				rowErrs = append(rowErrs, RowError{Row: n + 1, Column: c.Name, Reason: c.Name + " " + err.Error()})
+				continue
+			}
+			record[c.Name] = v
+		}
+
+		if len(rowErrs) > 0 {
+			rejected++
+			invalid = append(invalid, rowErrs...)
+			q.reject(n, rowErrs)
+			continue
+		}
+
+		id, err := uuid.NewV7()
+		if err != nil {
+			tracing.End(span, err)
+			return nil, 0, err
+		}
+
+		record["id"] = id.String()
+		record["create_date"] = event.CreateDate
+		records = append(records, record)
+		numbers = append(numbers, n+1)
+	}
+
+	if rejected > 0 && q == nil {
+		err := invalidRowsError(invalid, rejected)
+		tracing.End(span, err)
+		return nil, rejected, err
+	}
+
+	tracing.End(span, nil)
+
+	report, err := i.recordDuplicates(ctx, opts, s, records, numbers)
+	if err != nil {
+		return nil, rejected + report.rows(), err
+	}
+	records = keep(report, records)
+
+	if opts.DryRun {
+		return &Result{Preview: newPreview(s.Name, header, selectRows(body, keep(report, numbers)), applied, report, q)}, rejected, nil
+	}
+
+	err = q.store(&event, event.CreateDate)
+	if err != nil {
+		return nil, 0, err
+	}
+	event.Source = opts.Source
+
+	storeCtx, span := tracing.Start(ctx, "import.store",
+		attribute.String("event.id", event.ID),
+		attribute.String("import.type", s.Name),
+		attribute.Int("import.records", len(records)),
+		attribute.Int("import.rows_rejected", rejected),
+	)
+
+	err = i.records.CreateEventRecords(storeCtx, event, s.Table, records)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return report.result(&Result{
+		Event:        event,
+		RowsImported: len(records),
+		RowsRejected: rejected,
+	}), rejected, nil
+}
+
+// parseRecords reads a whole CSV file without mapping it to a struct. With
+// q, malformed rows are left to q instead of failing the file.
+func parseRecords(ctx context.Context, r io.Reader, q *quarantine) ([]string, [][]string, error) {
+
+	_, span := tracing.Start(ctx, "csv.parse")
+
+	var records [][]string
+	var err error
+	if q != nil {
+		records, err = q.read(r)
+	} else {
+		records, err = csv.NewReader(r).ReadAll()
+	}
+	if err == nil && len(records) == 0 {
+		err = gocsv.ErrEmptyCSVFile
+	}
+	if err != nil {
+		err = parseError(err)
+		tracing.End(span, err)
+		return nil, nil, err
+	}
+
+	span.SetAttributes(attribute.Int("csv.rows", len(records)-1))
+	tracing.End(span, nil)
+
+	return records[0], records[1:], nil
+}
+
+// exportRecords writes the rows of a declarative import type with one column
+// per schema column, in schema order.
+func (i *Importer) exportRecords(ctx context.Context, event *model.Event, w io.Writer) error {
+
+	s, ok := i.schemas.Get(event.ImportType)
+	if !ok {
+		return fmt.Errorf("event %s has unknown import type %s", event.ID, event.ImportType)
+	}
+
+	records, err := i.records.ListRecords(ctx, event.ID, s.Table)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(s.Columns))
+	for n, c := range s.Columns {
+		header[n] = c.Name
+	}
+
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	row := make([]string, len(s.Columns))
+	for _, record := range records {
+		for n := range s.Columns {
+			row[n] = s.Columns[n].Format(record[s.Columns[n].Name])
+		}
+
+		err = writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
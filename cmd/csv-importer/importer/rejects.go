@@ -0,0 +1,278 @@
+package importer
+
+import (
+	"bytes"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Import modes.
+const (
+	// ModeStrict rejects files with invalid rows as a whole.
+	ModeStrict = "strict"
+	// ModePartial imports the valid rows and quarantines the others.
+	ModePartial = "partial"
+)
+
+var modes = []string{ModeStrict, ModePartial}
+
+// ErrorsColumn is the column error reports describe the problems of each row
+// in. Uploads ignore it, so that corrected reports can be imported again.
+const ErrorsColumn = "_errors"
+
+func checkMode(mode string) error {
+
+	if mode == "" || slices.Contains(modes, mode) {
+		return nil
+	}
+
+	return apperr.Validation("mode must be one of " + strings.Join(modes, ", ")).
+		WithDetails(map[string]any{"mode": mode})
+}
+
+// quarantine collects the rows a partial import leaves out, with the raw
+// text they were read from.
+type quarantine struct {
+	header string
+	// lines holds the raw text of every data row.
+	lines []string
+	// fields holds the fields of every record as uploaded, header included.
+	fields [][]string
+	// malformed maps data rows, counted from 0, with the wrong number of
+	// fields to the reason.
+	malformed map[int]string
+	rows      []model.RejectedRow
+	// errs holds the first errors of the rows, for previews.
+	errs []RowError
+}
+
+// newQuarantine returns a quarantine in partial mode, nil otherwise.
+func newQuarantine(opts Options) *quarantine {
+
+	if opts.Mode != ModePartial {
+		return nil
+	}
+
+	return &quarantine{malformed: map[int]string{}}
+}
+
+// read reads a whole CSV file like csv.Reader.ReadAll, keeping the raw text
+// of every record. Rows with the wrong number of fields are padded or cut
+// to the header instead of failing the file.
+func (q *quarantine) read(r io.Reader) ([][]string, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+
+	var records [][]string
+	var start int64
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+
+		if err == nil || errors.Is(err, csv.ErrFieldCount) {
+			q.fields = append(q.fields, slices.Clone(record))
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			n := len(records[0])
+			q.malformed[len(records)-1] = fmt.Sprintf("row has %d fields, the header has %d", len(record), n)
+			record = append(record, make([]string, max(n-len(record), 0))...)[:n]
+		} else if err != nil {
+			return nil, err
+		}
+
+		// The text since the last record also holds skipped blank lines
+		end := reader.InputOffset()
+		line := strings.Trim(string(data[start:end]), "\r\n")
+		start = end
+
+		if records == nil {
+			q.header = strings.TrimPrefix(line, "\uFEFF")
+		} else {
+			q.lines = append(q.lines, line)
+		}
+		records = append(records, record)
+	}
+}
+
+// check returns the reason data row n, counted from 0, was malformed, as
+// the error of the row.
+func (q *quarantine) check(n int) []RowError {
+
+	if q == nil {
+		return nil
+	}
+
+	reason, ok := q.malformed[n]
+	if !ok {
+		return nil
+	}
+
+	return []RowError{{Row: n + 1, Reason: reason}}
+}
+
+// reject quarantines data row n, counted from 0, for errs.
+func (q *quarantine) reject(n int, errs []RowError) {
+
+	if q == nil {
+		return
+	}
+
+	reasons := make(model.RejectReasons, len(errs))
+	for k, e := range errs {
+		reasons[k] = model.RejectReason{Column: e.Column, Reason: e.Reason}
+	}
+
+	q.rows = append(q.rows, model.RejectedRow{
+		Row:     n + 1,
+		Header:  q.header,
+		Line:    q.lines[n],
+		Reasons: reasons,
+	})
+
+	if room := maxRowErrors - len(q.errs); room > 0 {
+		q.errs = append(q.errs, errs[:min(room, len(errs))]...)
+	}
+}
+
+// store adds the quarantined rows to event, to be stored with it.
+func (q *quarantine) store(event *model.Event, now time.Time) error {
+
+	if q == nil {
+		return nil
+	}
+
+	for k := range q.rows {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+
+		q.rows[k].ID = id.String()
+		q.rows[k].CreateDate = now
+	}
+
+	event.Rejected = q.rows
+
+	if len(q.rows) > 0 {
+		content, err := q.report()
+		if err != nil {
+			return err
+		}
+
+		event.Report = &model.ImportReport{Content: content, CreateDate: now}
+	}
+
+	return nil
+}
+
+// report writes the uploaded file again with the problems of every row in an
+// ErrorsColumn of its own, replacing the one of an uploaded report. Short
+// rows are padded to the header so that their problems line up.
+func (q *quarantine) report() (string, error) {
+
+	reasons := make(map[int]string, len(q.rows))
+	for _, row := range q.rows {
+		texts := make([]string, len(row.Reasons))
+		for k, reason := range row.Reasons {
+			texts[k] = reason.Reason
+		}
+		reasons[row.Row] = strings.Join(texts, "; ")
+	}
+
+	q.fields[0][0] = strings.TrimPrefix(q.fields[0][0], "\uFEFF")
+	errorsAt := slices.IndexFunc(q.fields[0], func(h string) bool { return columnName(h) == ErrorsColumn })
+	width := len(q.fields[0])
+	if errorsAt >= 0 {
+		width--
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	for n, fields := range q.fields {
+		if errorsAt >= 0 && errorsAt < len(fields) {
+			fields = slices.Delete(fields, errorsAt, errorsAt+1)
+		}
+		if len(fields) < width {
+			fields = append(fields, make([]string, width-len(fields))...)
+		}
+
+		annotation := ErrorsColumn
+		if n > 0 {
+			annotation = reasons[n]
+		}
+
+		err := writer.Write(append(fields, annotation))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// FailedRows keeps the header and the rows with problems of an error report.
+func FailedRows(report string) (string, error) {
+
+	reader := csv.NewReader(strings.NewReader(report))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	for n, record := range records {
+		if n > 0 && record[len(record)-1] == "" {
+			continue
+		}
+
+		err = writer.Write(record)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// rejected returns the number of rows left out.
+func (q *quarantine) rejected() int {
+
+	if q == nil {
+		return 0
+	}
+
+	return len(q.rows)
+}
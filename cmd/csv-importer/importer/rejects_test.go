@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImporter_Import_Partial(t *testing.T) {
+	repo := new(MockEventRepo)
+	observer := &recordingObserver{}
+	imp := New(repo)
+	imp.SetObserver(observer)
+
+	var event model.Event
+	var stored []model.TodoEvent
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			event = args.Get(1).(model.Event)
+			stored = args.Get(2).([]model.TodoEvent)
+		}).
+		Return(nil)
+
+	csv := "\uFEFFtodo_name,note,priority\r\n" +
+		"Book venue,Downtown,high\r\n" +
+		"Send invites,\"Guests,\nplus ones\",urgent\r\n" +
+		"\r\n" +
+		"Order food,Lunch\r\n" +
+		"Call caterer,,low\r\n"
+
+	result, err := imp.Import(context.Background(), "Offsite", strings.NewReader(csv), Options{Mode: ModePartial})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.RowsImported)
+	assert.Equal(t, 2, result.RowsRejected)
+
+	require.Len(t, stored, 2)
+	assert.Equal(t, "Book venue", stored[0].Name)
+	assert.Equal(t, "Call caterer", stored[1].Name)
+
+	require.Len(t, event.Rejected, 2)
+	for _, row := range event.Rejected {
+		assert.NotEmpty(t, row.ID)
+		assert.Equal(t, event.CreateDate, row.CreateDate)
+		assert.Equal(t, "todo_name,note,priority", row.Header, "The header is kept without its byte order mark")
+	}
+	assert.Equal(t, 2, event.Rejected[0].Row)
+	assert.Equal(t, "Send invites,\"Guests,\nplus ones\",urgent", event.Rejected[0].Line, "Lines are kept as uploaded")
+	assert.Equal(t, model.RejectReasons{{Column: "priority", Reason: "priority must be one of low, medium, high"}}, event.Rejected[0].Reasons)
+	assert.Equal(t, 3, event.Rejected[1].Row)
+	assert.Equal(t, "Order food,Lunch", event.Rejected[1].Line, "Blank lines are skipped")
+	assert.Equal(t, model.RejectReasons{{Reason: "row has 2 fields, the header has 3"}}, event.Rejected[1].Reasons)
+
+	require.NotNil(t, event.Report)
+	assert.Equal(t, event.CreateDate, event.Report.CreateDate)
+	assert.Equal(t, "todo_name,note,priority,_errors\n"+
+		"Book venue,Downtown,high,\n"+
+		"Send invites,\"Guests,\nplus ones\",urgent,\"priority must be one of low, medium, high\"\n"+
+		"Order food,Lunch,,\"row has 2 fields, the header has 3\"\n"+
+		"Call caterer,,low,\n", event.Report.Content, "Short rows are padded to the header")
+
+	require.Len(t, observer.stats, 1)
+	assert.Equal(t, 2, observer.stats[0].RowsImported)
+	assert.Equal(t, 2, observer.stats[0].RowsRejected)
+}
+
+func TestImporter_Import_PartialRecords(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	csv := "name,email\n" +
+		"Ada,ada@example.com\n" +
+		"Bob,bob\n" +
+		"Grace,grace@example.com\n" +
+		"ADA,ada@example.com\n"
+	opts := Options{
+		Type:       "attendees",
+		Mode:       ModePartial,
+		DryRun:     true,
+		Duplicates: DuplicateOptions{Columns: []string{"email"}},
+	}
+
+	result, err := newSchemaImporter(t, repo).Import(context.Background(), "Summit", strings.NewReader(csv), opts)
+
+	require.NoError(t, err)
+	preview := result.Preview
+	assert.Equal(t, 2, preview.Rows)
+	assert.Equal(t, [][]string{{"Ada", "ada@example.com"}, {"Grace", "grace@example.com"}}, preview.Sample)
+	assert.Equal(t, 1, preview.RowsRejected)
+	assert.Equal(t, []RowError{{Row: 2, Column: "email", Reason: "email is not an email address"}}, preview.Errors)
+	assert.Equal(t, []Duplicate{{Row: 4, DuplicateOf: 1}}, preview.Duplicates, "Rows keep their numbers around rejected rows")
+	repo.AssertNotCalled(t, "CreateEventRecords", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImporter_Import_PartialReport(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	var event model.Event
+	var stored []model.TodoEvent
+	repo.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			event = args.Get(1).(model.Event)
+			stored = args.Get(2).([]model.TodoEvent)
+		}).
+		Return(nil)
+
+	// A corrected report with one row still wrong
+	csv := "todo_name,_errors,note,priority\n" +
+		"Send invites,\"priority must be one of low, medium, high\",Guests,high\n" +
+		"Order food,\"row has 2 fields, the header has 3\",Lunch,soon,extra\n"
+
+	result, err := New(repo).Import(context.Background(), "Offsite", strings.NewReader(csv), Options{Mode: ModePartial, KeepCustomFields: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.RowsImported)
+	require.Len(t, stored, 1)
+	assert.Empty(t, stored[0].CustomFields, "The errors column is not a custom field")
+
+	require.NotNil(t, event.Report)
+	assert.Equal(t, "todo_name,note,priority,_errors\n"+
+		"Send invites,Guests,high,\n"+
+		"Order food,Lunch,soon,extra,\"row has 5 fields, the header has 4\"\n", event.Report.Content,
+		"The errors column is replaced")
+
+	failed, err := FailedRows(event.Report.Content)
+	require.NoError(t, err)
+	assert.Equal(t, "todo_name,note,priority,_errors\n"+
+		"Order food,Lunch,soon,extra,\"row has 5 fields, the header has 4\"\n", failed)
+}
+
+func TestImporter_Import_StrictRejectsMalformedRows(t *testing.T) {
+	repo := new(MockEventRepo)
+
+	_, err := New(repo).Import(context.Background(), "Offsite", strings.NewReader("todo_name,note\nBook venue\n"), Options{Mode: ModeStrict})
+
+	var appErr *apperr.Error
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "csvfile is not a valid csv document", appErr.Message)
+
+	_, err = New(repo).Import(context.Background(), "Offsite", strings.NewReader("todo_name,note\nBook venue,\n"), Options{Mode: "lenient"})
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "mode must be one of strict, partial", appErr.Message)
+	assert.Equal(t, map[string]any{"mode": "lenient"}, appErr.Details)
+
+	repo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything, mock.Anything)
+}
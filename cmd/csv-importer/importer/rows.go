@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxRowErrors caps the errors reported for one file; the count of rejected
+// rows is always exact.
+const maxRowErrors = 100
+
+// RowError is one invalid cell. Row counts data rows from 1, after the
+// header. Cell values are never echoed back.
+type RowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+	Reason string `json:"reason"`
+}
+
+// convert parses the typed columns of row into a todo without IDs or dates.
+func convert(n int, row model.TodoCSV) (model.TodoEvent, []RowError) {
+
+	var errs []RowError
+	fail := func(column string, err error) {
+		errs = append(errs, RowError{Row: n, Column: column, Reason: column + " " + err.Error()})
+	}
+
+	status, err := model.ParseTodoStatus(row.Status)
+	if err != nil {
+		fail("status", err)
+	}
+
+	priority, err := model.ParseTodoPriority(row.Priority)
+	if err != nil {
+		fail("priority", err)
+	}
+
+	dueDate, err := model.ParseDueDate(row.DueDate)
+	if err != nil {
+		fail("due_date", err)
+	}
+
+	assignee, err := model.ParseEmail(row.AssigneeEmail)
+	if err != nil {
+		fail("assignee_email", err)
+	}
+
+	tags, err := model.ParseTags(row.Tags)
+	if err != nil {
+		fail("tags", err)
+	}
+
+	return model.TodoEvent{
+		Name:          row.TodoName,
+		Note:          row.Note,
+		Status:        status,
+		Priority:      priority,
+		DueDate:       dueDate,
+		AssigneeEmail: assignee,
+		Tags:          tags,
+	}, errs
+}
+
+func invalidRowsError(errs []RowError, rejected int) error {
+
+	if len(errs) > maxRowErrors {
+		errs = errs[:maxRowErrors]
+	}
+
+	return apperr.Validation("csvfile has invalid rows").
+		WithDetails(map[string]any{
+			"rows_rejected": rejected,
+			"errors":        errs,
+		})
+}
+
+// column is one exported CSV column; optional columns are skipped when no
+// todo has a value for them.
+type column struct {
+	name     string
+	optional bool
+	value    func(todo model.TodoEvent) string
+}
+
+var columns = []column{
+	{"todo_name", false, func(t model.TodoEvent) string { return t.Name }},
+	{"note", false, func(t model.TodoEvent) string { return t.Note }},
+	{"status", true, func(t model.TodoEvent) string {
+		// Open is the default and needs no column of its own
+		if t.Status == model.TodoOpen {
+			return ""
+		}
+		return string(t.Status)
+	}},
+	{"priority", true, func(t model.TodoEvent) string { return string(t.Priority) }},
+	{"due_date", true, func(t model.TodoEvent) string { return model.FormatDueDate(t.DueDate) }},
+	{"assignee_email", true, func(t model.TodoEvent) string { return t.AssigneeEmail }},
+	{"tags", true, func(t model.TodoEvent) string { return strings.Join(t.Tags, ",") }},
+}
+
+func writeCSV(w io.Writer, todos []model.TodoEvent) error {
+
+	var used []column
+	for _, c := range columns {
+		if !c.optional || anyValue(c, todos) {
+			used = append(used, c)
+		}
+	}
+	used = append(used, customColumns(todos)...)
+
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(used))
+	for i, c := range used {
+		header[i] = c.name
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(used))
+	for _, todo := range todos {
+		for i, c := range used {
+			record[i] = c.value(todo)
+		}
+
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func anyValue(c column, todos []model.TodoEvent) bool {
+
+	for _, todo := range todos {
+		if c.value(todo) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// customColumns follow the todo columns, sorted by name.
+func customColumns(todos []model.TodoEvent) []column {
+
+	var names []string
+	seen := map[string]bool{}
+	for _, todo := range todos {
+		for name := range todo.CustomFields {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	custom := make([]column, 0, len(names))
+	for _, name := range names {
+		custom = append(custom, column{name, true, func(t model.TodoEvent) string {
+			return t.CustomFields[name]
+		}})
+	}
+
+	return custom
+}
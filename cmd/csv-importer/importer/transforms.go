@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"csv-importer-backend/cmd/csv-importer/transform"
+	"errors"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// previewRows is the number of transformed rows a dry run echoes.
+const previewRows = 10
+
+// Preview describes what an import would store, for dry runs.
+type Preview struct {
+	ImportType string `json:"import_type"`
+	// Rows counts the rows that would be stored, without rejected rows and
+	// the duplicates skipped.
+	Rows   int      `json:"rows"`
+	Header []string `json:"header"`
+	// Sample holds the first rows after transformation.
+	Sample         [][]string          `json:"sample"`
+	Transforms     []transform.Applied `json:"transforms"`
+	RowsDuplicated int                 `json:"rows_duplicated"`
+	Duplicates     []Duplicate         `json:"duplicates,omitempty"`
+	// RowsRejected counts the rows a partial import would leave out, Errors
+	// lists the first of their errors.
+	RowsRejected int        `json:"rows_rejected"`
+	Errors       []RowError `json:"errors,omitempty"`
+}
+
+// newPreview previews the rows of body that would be stored.
+func newPreview(importType string, header []string, body [][]string, applied []transform.Applied, report *duplicateReport, q *quarantine) *Preview {
+
+	if applied == nil {
+		applied = []transform.Applied{}
+	}
+
+	preview := &Preview{
+		ImportType: importType,
+		Rows:       len(body),
+		Header:     header,
+		Sample:     body[:min(len(body), previewRows)],
+		Transforms: applied,
+	}
+	if report != nil {
+		preview.RowsDuplicated = report.count
+		preview.Duplicates = report.found
+	}
+	if q != nil {
+		preview.RowsRejected = q.rejected()
+		preview.Errors = q.errs
+	}
+
+	return preview
+}
+
+// selectRows returns the rows of body with the given numbers, counted from 1.
+func selectRows(body [][]string, numbers []int) [][]string {
+
+	rows := make([][]string, len(numbers))
+	for k, n := range numbers {
+		rows[k] = body[n-1]
+	}
+
+	return rows
+}
+
+// pipeline compiles the transforms of the import type s, if any, followed by
+// those of the upload.
+func pipeline(s *schema.Schema, opts Options) (*transform.Pipeline, error) {
+
+	var rules []transform.Rule
+	if s != nil {
+		rules = s.Transforms
+	}
+
+	p, err := transform.Compile(slices.Concat(rules, opts.Transforms))
+	if err != nil {
+		return nil, apperr.Validation("transforms are invalid").
+			WithDetails(map[string]any{"reason": err.Error()})
+	}
+
+	return p, nil
+}
+
+// todoPipeline is pipeline for todos, whose definition is optional.
+func (i *Importer) todoPipeline(opts Options) (*transform.Pipeline, error) {
+
+	s, _ := i.schemas.Get(model.ImportTypeTodos)
+	return pipeline(s, opts)
+}
+
+// transformRecords normalizes the header and runs p over the file.
+func transformRecords(ctx context.Context, p *transform.Pipeline, header []string, body [][]string) ([]string, [][]string, []transform.Applied, error) {
+
+	names := make([]string, len(header))
+	for n, h := range header {
+		names[n] = columnName(h)
+	}
+
+	if p.Len() == 0 {
+		return names, body, nil, nil
+	}
+
+	_, span := tracing.Start(ctx, "import.transform", attribute.Int("import.transforms", p.Len()))
+
+	names, body, applied, err := p.Apply(names, body)
+
+	var columnErr *transform.ColumnError
+	if errors.As(err, &columnErr) {
+		err = apperr.Validation("csvfile has no column for transform").
+			WithDetails(map[string]any{
+				"transform": columnErr.Rule,
+				"op":        columnErr.Op,
+				"column":    columnErr.Column,
+			})
+	}
+
+	tracing.End(span, err)
+
+	return names, body, applied, err
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"csv-importer-backend/cmd/csv-importer/apis"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/migration"
 	"csv-importer-backend/cmd/csv-importer/model"
 	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/repository/conformance"
+	"csv-importer-backend/cmd/csv-importer/requestid"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,6 +36,12 @@ const (
 	testDBName     = "postgres" // Use existing database instead of separate test DB
 )
 
+var testAPIKeys = auth.KeyStore{
+	"tenant-a-key":    {Subject: "alice", TenantID: "tenant-a", Role: auth.RoleAdmin},
+	"tenant-b-key":    {Subject: "bob", TenantID: "tenant-b", Role: auth.RoleAdmin},
+	"tenant-a-viewer": {Subject: "carol", TenantID: "tenant-a", Role: auth.RoleViewer},
+}
+
 func setupTestDB(t *testing.T) *gorm.DB {
 	// Skip integration tests if not in integration test environment
 	if os.Getenv("INTEGRATION_TEST") == "" {
@@ -42,21 +56,25 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	require.NoError(t, err, "Failed to connect to test database")
 
-	// Ensure tables exist (auto-migrate if needed)
-	err = db.AutoMigrate(&model.Event{}, &model.TodoEvent{})
+	// Bring the schema up to date with the embedded migrations
+	migrator, err := migration.New(db)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
 	require.NoError(t, err, "Failed to migrate test database")
 	
 	// Clean up existing test data after tables are ensured to exist
-	db.Exec("TRUNCATE TABLE events CASCADE")
 	db.Exec("TRUNCATE TABLE todo_events CASCADE")
+	db.Exec("TRUNCATE TABLE events CASCADE")
+	db.Exec("TRUNCATE TABLE audit_log")
 
 	return db
 }
 
 func teardownTestDB(t *testing.T, db *gorm.DB) {
 	// Clean up test data (ignore errors since tables might not exist yet)
-	db.Exec("TRUNCATE TABLE events CASCADE")
 	db.Exec("TRUNCATE TABLE todo_events CASCADE")
+	db.Exec("TRUNCATE TABLE events CASCADE")
+	db.Exec("TRUNCATE TABLE audit_log")
 	
 	// Close database connection
 	sqlDB, err := db.DB()
@@ -65,6 +83,14 @@ func teardownTestDB(t *testing.T, db *gorm.DB) {
 	}
 }
 
+func TestIntegration_RepositoryConformance(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) *gorm.DB {
+		db := setupTestDB(t)
+		t.Cleanup(func() { teardownTestDB(t, db) })
+		return db
+	})
+}
+
 func TestIntegration_EventAPI_CreateAndList(t *testing.T) {
 	db := setupTestDB(t)
 	defer teardownTestDB(t, db)
@@ -278,16 +304,22 @@ func createTestServer(t *testing.T) (*echo.Echo, *gorm.DB) {
 	db := setupTestDB(t)
 	
 	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.Use(requestid.Middleware())
 	rootg := e.Group("")
-	v1g := rootg.Group("/api/v1")
+	v1g := rootg.Group("/api/v1", auth.Middleware(testAPIKeys))
 
 	// Setup health check
-	apis.NewHealthCheckAPI(db).Setup(rootg)
+	apis.NewHealthCheckAPI(db, nil).Setup(rootg)
 
 	// Setup event API
 	eventRepo := repository.NewEventRepo(db)
 	apis.NewEventAPI(eventRepo).Setup(v1g)
 
+	// Setup audit API
+	auditRepo := repository.NewAuditRepo(db)
+	apis.NewAuditAPI(auditRepo).Setup(v1g)
+
 	return e, db
 }
 
@@ -310,6 +342,177 @@ func TestIntegration_HealthCheckEndpoint(t *testing.T) {
 	t.Logf("Health check response: %v", response)
 }
 
+func uploadTestEvent(t *testing.T, server *echo.Echo, apiKey string, name string, csvContent string) model.Event {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	require.NoError(t, writer.WriteField("name", name))
+	csvField, err := writer.CreateFormFile("csvfile", "todos.csv")
+	require.NoError(t, err)
+	_, err = csvField.Write([]byte(csvContent))
+	require.NoError(t, err)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/event", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set(auth.APIKeyHeader, apiKey)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response struct {
+		Data model.Event `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+
+	return response.Data
+}
+
+func TestIntegration_TenantIsolation(t *testing.T) {
+	server, db := createTestServer(t)
+	defer teardownTestDB(t, db)
+
+	eventA := uploadTestEvent(t, server, "tenant-a-key", "Tenant A Event", "todo_name,note\nTask A,Note A")
+	eventB := uploadTestEvent(t, server, "tenant-b-key", "Tenant B Event", "todo_name,note\nTask B,Note B")
+
+	get := func(apiKey string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Each tenant sees its own event and todos
+	rec := get("tenant-a-key", "/api/v1/events/"+eventA.ID)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = get("tenant-a-key", "/api/v1/events/"+eventA.ID+"/todos")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Task A")
+
+	// Cross-tenant reads behave exactly like missing events
+	rec = get("tenant-a-key", "/api/v1/events/"+eventB.ID)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = get("tenant-a-key", "/api/v1/events/"+eventB.ID+"/todos")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = get("tenant-b-key", "/api/v1/events/"+eventA.ID)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Listings only contain the caller's events
+	rec = get("tenant-a-key", "/api/v1/events")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), eventA.ID)
+	assert.NotContains(t, rec.Body.String(), eventB.ID)
+
+	// Requests without a key never reach the repository
+	rec = get("", "/api/v1/events")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Cross-tenant mutations behave like missing events as well
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/events/"+eventB.ID, nil)
+	req.Header.Set(auth.APIKeyHeader, "tenant-a-key")
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = get("tenant-b-key", "/api/v1/events/"+eventB.ID)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestIntegration_RoleBasedAccess(t *testing.T) {
+	server, db := createTestServer(t)
+	defer teardownTestDB(t, db)
+
+	event := uploadTestEvent(t, server, "tenant-a-key", "RBAC Event", "todo_name,note\nTask,Note")
+
+	send := func(apiKey string, method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("tenant-a-viewer", http.MethodGet, "/api/v1/events/"+event.ID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = send("tenant-a-viewer", http.MethodPatch, "/api/v1/events/"+event.ID+"/status", `{"status":"start"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), auth.ReasonRoleNotAllowed)
+
+	rec = send("tenant-a-key", http.MethodPatch, "/api/v1/events/"+event.ID+"/status", `{"status":"start"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"start"`)
+
+	rec = send("tenant-a-key", http.MethodGet, "/api/v1/events/"+event.ID+"/export", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "todo_name,note\nTask,Note\n", rec.Body.String())
+
+	rec = send("tenant-a-key", http.MethodDelete, "/api/v1/events/"+event.ID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = send("tenant-a-key", http.MethodGet, "/api/v1/events/"+event.ID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestIntegration_AuditLog(t *testing.T) {
+	server, db := createTestServer(t)
+	defer teardownTestDB(t, db)
+
+	event := uploadTestEvent(t, server, "tenant-a-key", "Audited Event", "todo_name,note\nTask 1,Note\nTask 2,Note")
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/events/"+event.ID+"/status", strings.NewReader(`{"status":"start"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(auth.APIKeyHeader, "tenant-a-key")
+	req.Header.Set(echo.HeaderXRequestID, "status-change-request")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	listAudit := func(apiKey string, query string) (int, []model.AuditLog) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/audit"+query, nil)
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		var response struct {
+			Data []model.AuditLog `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec.Code, response.Data
+	}
+
+	code, logs := listAudit("tenant-a-key", "?target_id="+event.ID)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, logs, 3)
+
+	// Newest first
+	assert.Equal(t, model.AuditEventStatusChange, logs[0].Action)
+	assert.Equal(t, "alice", logs[0].Actor)
+	assert.Equal(t, "status-change-request", logs[0].RequestID)
+	var diff map[string]map[string]any
+	require.NoError(t, json.Unmarshal(logs[0].Diff, &diff))
+	assert.Equal(t, map[string]any{"before": "draft", "after": "start"}, diff["status"])
+	assert.Equal(t, model.AuditImportRun, logs[1].Action)
+	assert.JSONEq(t, `{"todos_imported":2}`, string(logs[1].After))
+	assert.Equal(t, model.AuditEventCreate, logs[2].Action)
+
+	code, logs = listAudit("tenant-a-key", "?action=import.run")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, logs, 1)
+
+	// Other tenants see nothing and viewers are not allowed to read the log
+	code, logs = listAudit("tenant-b-key", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, logs)
+
+	code, _ = listAudit("tenant-a-viewer", "")
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
 // Benchmark for database operations
 func BenchmarkIntegration_CreateEvent(b *testing.B) {
 	if os.Getenv("INTEGRATION_TEST") == "" {
@@ -0,0 +1,132 @@
+// Package lifecycle lets the server finish in-flight imports before it exits.
+package lifecycle
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"errors"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+var ErrDraining = errors.New("server is shutting down")
+
+// Drainer counts in-flight work. Once Drain is called it refuses new work
+// and waits for the existing work to finish.
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{}
+
+	ctx   context.Context
+	abort context.CancelFunc
+}
+
+func New() *Drainer {
+
+	ctx, abort := context.WithCancel(context.Background())
+
+	return &Drainer{
+		idle:  make(chan struct{}),
+		ctx:   ctx,
+		abort: abort,
+	}
+}
+
+func (d *Drainer) acquire() bool {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return false
+	}
+
+	d.active++
+	return true
+}
+
+func (d *Drainer) release() {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.active--
+	if d.draining && d.active == 0 {
+		close(d.idle)
+	}
+}
+
+// Draining reports whether Drain has been called.
+func (d *Drainer) Draining() bool {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.draining
+}
+
+// InFlight returns the number of requests still running.
+func (d *Drainer) InFlight() int {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.active
+}
+
+// Context lasts until Abort. Work that has to outlive the request that
+// started it, but not the server, runs on it.
+func (d *Drainer) Context() context.Context {
+	return d.ctx
+}
+
+// Abort cancels Context, once draining has given up waiting.
+func (d *Drainer) Abort() {
+	d.abort()
+}
+
+// Drain stops accepting work and blocks until everything in flight has
+// finished or ctx is done. It is safe to call more than once.
+func (d *Drainer) Drain(ctx context.Context) error {
+
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		if d.active == 0 {
+			close(d.idle)
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Middleware tracks every request not selected by skipper, and rejects them
+// with 503 once draining has started.
+func Middleware(d *Drainer, skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			if !d.acquire() {
+				c.Response().Header().Set(echo.HeaderConnection, "close")
+				return apperr.Unavailable(ErrDraining.Error())
+			}
+			defer d.release()
+
+			return next(c)
+		}
+	}
+}
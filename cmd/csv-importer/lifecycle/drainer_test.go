@@ -0,0 +1,110 @@
+package lifecycle
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainer_DrainWaitsForWork(t *testing.T) {
+	d := New()
+
+	require.True(t, d.acquire())
+	assert.Equal(t, 1, d.InFlight())
+
+	drained := make(chan error, 1)
+	go func() { drained <- d.Drain(context.Background()) }()
+
+	select {
+	case <-drained:
+		t.Fatal("Drain returned while work was in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	assert.True(t, d.Draining())
+	assert.False(t, d.acquire(), "No new work once draining")
+
+	d.release()
+	assert.NoError(t, <-drained)
+	assert.Equal(t, 0, d.InFlight())
+
+	assert.NoError(t, d.Drain(context.Background()), "Draining twice is harmless")
+}
+
+func TestDrainer_DrainDeadline(t *testing.T) {
+	d := New()
+
+	require.True(t, d.acquire())
+	defer d.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, d.Drain(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 1, d.InFlight())
+}
+
+func TestDrainer_Abort(t *testing.T) {
+	d := New()
+	assert.NoError(t, d.Context().Err())
+
+	d.Abort()
+	assert.ErrorIs(t, d.Context().Err(), context.Canceled)
+}
+
+func TestDrainer_DrainWhenIdle(t *testing.T) {
+	d := New()
+	assert.NoError(t, d.Drain(context.Background()))
+}
+
+func TestMiddleware_RejectsWhileDraining(t *testing.T) {
+	d := New()
+
+	inHandler := make(chan struct{})
+	release := make(chan struct{})
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.Use(Middleware(d, func(c echo.Context) bool { return c.Request().Method == http.MethodGet }))
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.POST("/", func(c echo.Context) error {
+		close(inHandler)
+		<-release
+		return c.NoContent(http.StatusCreated)
+	})
+
+	inFlight := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		e.ServeHTTP(inFlight, httptest.NewRequest(http.MethodPost, "/", nil))
+		close(served)
+	}()
+	<-inHandler
+
+	drained := make(chan error, 1)
+	go func() { drained <- d.Drain(context.Background()) }()
+	require.Eventually(t, d.Draining, time.Second, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"unavailable"`)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "Skipped requests are still served")
+
+	close(release)
+	<-served
+	assert.Equal(t, http.StatusCreated, inFlight.Code, "In-flight requests finish")
+	assert.NoError(t, <-drained)
+}
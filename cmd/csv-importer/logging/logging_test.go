@@ -0,0 +1,245 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/requestid"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// records decodes the JSON lines written by a logger.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var result []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		result = append(result, record)
+	}
+	return result
+}
+
+func TestParseLevel(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			level, err := ParseLevel(tc.input)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, level)
+		})
+	}
+}
+
+func TestNew_Formats(t *testing.T) {
+	var buf bytes.Buffer
+
+	New(&buf, FormatJSON, slog.LevelInfo).Info("hello", "n", 1)
+	assert.True(t, json.Valid(bytes.TrimSpace(buf.Bytes())), buf.String())
+
+	buf.Reset()
+	New(&buf, FormatText, slog.LevelInfo).Info("hello", "n", 1)
+	assert.Contains(t, buf.String(), "msg=hello n=1")
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, FormatJSON, slog.LevelWarn)
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	logged := records(t, &buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "kept", logged[0]["msg"])
+}
+
+func TestNew_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, FormatJSON, slog.LevelInfo).With("component", "test")
+
+	logger.InfoContext(requestid.NewContext(context.Background(), "req-42"), "with id")
+	logger.InfoContext(context.Background(), "without id")
+
+	logged := records(t, &buf)
+	require.Len(t, logged, 2)
+	assert.Equal(t, "req-42", logged[0]["request_id"])
+	assert.Equal(t, "test", logged[0]["component"])
+	assert.NotContains(t, logged[1], "request_id")
+}
+
+func TestNew_Trace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, FormatJSON, slog.LevelInfo)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "traced")
+
+	logged := records(t, &buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logged[0]["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", logged[0]["span_id"])
+}
+
+type todo struct {
+	ID   int
+	Note string
+}
+
+func openTestDB(t *testing.T, logger gormlogger.Interface) *gorm.DB {
+	db, err := repository.Open(repository.DriverSQLite, ":memory:", &gorm.Config{Logger: logger})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	require.NoError(t, db.Exec("CREATE TABLE todos (id integer primary key, note text)").Error)
+	return db
+}
+
+func TestGormLogger_RedactsValues(t *testing.T) {
+	var buf bytes.Buffer
+	db := openTestDB(t, NewGormLogger(New(&buf, FormatJSON, slog.LevelDebug), 0))
+
+	ctx := requestid.NewContext(context.Background(), "req-7")
+	require.NoError(t, db.WithContext(ctx).Create(&todo{ID: 1, Note: "Call Alice on 555-0100"}).Error)
+
+	assert.NotContains(t, buf.String(), "555-0100", "CSV cell contents never reach the log")
+
+	logged := records(t, &buf)
+	require.NotEmpty(t, logged)
+	insert := logged[len(logged)-1]
+	assert.Equal(t, "query", insert["msg"])
+	assert.Equal(t, "DEBUG", insert["level"])
+	assert.Equal(t, "req-7", insert["request_id"])
+	assert.Contains(t, insert["sql"], "INSERT INTO")
+	assert.Contains(t, insert["sql"], "?")
+	assert.Equal(t, float64(1), insert["rows"])
+}
+
+func TestGormLogger_QueriesOnlyAtDebug(t *testing.T) {
+	var buf bytes.Buffer
+	db := openTestDB(t, NewGormLogger(New(&buf, FormatJSON, slog.LevelInfo), time.Hour))
+
+	require.NoError(t, db.Create(&todo{ID: 1, Note: "quiet"}).Error)
+	assert.Empty(t, buf.String())
+}
+
+func TestGormLogger_SlowQuery(t *testing.T) {
+	var buf bytes.Buffer
+	db := openTestDB(t, NewGormLogger(New(&buf, FormatJSON, slog.LevelInfo), time.Nanosecond))
+
+	require.NoError(t, db.Create(&todo{ID: 1, Note: "secret"}).Error)
+
+	logged := records(t, &buf)
+	require.NotEmpty(t, logged)
+	assert.Equal(t, "slow query", logged[len(logged)-1]["msg"])
+	assert.Equal(t, "WARN", logged[len(logged)-1]["level"])
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestGormLogger_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	db := openTestDB(t, NewGormLogger(New(&buf, FormatJSON, slog.LevelInfo), 0))
+
+	var found todo
+	assert.ErrorIs(t, db.First(&found, 1).Error, gorm.ErrRecordNotFound)
+	assert.Empty(t, buf.String(), "Missing records are not errors")
+
+	assert.Error(t, db.Table("missing").Create(map[string]any{"note": "secret"}).Error)
+
+	logged := records(t, &buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "query failed", logged[0]["msg"])
+	assert.Contains(t, logged[0]["error"], "no such table")
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestGormLogger_Silent(t *testing.T) {
+	var buf bytes.Buffer
+	db := openTestDB(t, NewGormLogger(New(&buf, FormatJSON, slog.LevelDebug), time.Nanosecond))
+
+	buf.Reset()
+
+	silent := db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormlogger.Silent)})
+	assert.Error(t, silent.Exec("SELECT * FROM missing").Error)
+	assert.Empty(t, buf.String())
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, FormatJSON, slog.LevelInfo)
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.Use(requestid.Middleware())
+	e.Use(Middleware(logger, func(c echo.Context) bool { return c.Path() == "/livez" }))
+
+	e.GET("/livez", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/events/:id", func(c echo.Context) error {
+		req := c.Request()
+		c.SetRequest(req.WithContext(auth.NewContext(req.Context(), auth.Principal{TenantID: "tenant-a"})))
+		return c.String(http.StatusOK, "ok")
+	})
+	e.GET("/boom", func(c echo.Context) error { return apperr.Internal(assert.AnError) })
+
+	for _, target := range []string{"/livez", "/events/1", "/boom"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(echo.HeaderXRequestID, "req"+target)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	logged := records(t, &buf)
+	require.Len(t, logged, 2, "Skipped requests are not logged")
+
+	assert.Equal(t, "request", logged[0]["msg"])
+	assert.Equal(t, "INFO", logged[0]["level"])
+	assert.Equal(t, "req/events/1", logged[0]["request_id"])
+	assert.Equal(t, "/events/1", logged[0]["path"])
+	assert.Equal(t, "/events/:id", logged[0]["route"])
+	assert.Equal(t, float64(200), logged[0]["status"])
+	assert.Equal(t, float64(2), logged[0]["bytes_out"])
+	assert.Equal(t, "tenant-a", logged[0]["tenant"])
+
+	assert.Equal(t, "ERROR", logged[1]["level"])
+	assert.Equal(t, float64(500), logged[1]["status"])
+	assert.NotContains(t, logged[1], "tenant")
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Middleware writes one access log record per request. It has to run after
+// the request ID middleware so that the record carries the ID. Server errors
+// are logged at error level, everything else at info. Requests selected by
+// skipper are not logged.
+func Middleware(logger *slog.Logger, skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			start := time.Now()
+			err := next(c)
+
+			// The error handler has not written the response yet
+			status := c.Response().Status
+			if err != nil {
+				status = apperr.From(err).HTTPStatus()
+			}
+
+			req := c.Request()
+
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.String("route", c.Path()),
+				slog.Int("status", status),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes_in", req.ContentLength),
+				slog.Int64("bytes_out", c.Response().Size),
+			}
+
+			if principal, ok := auth.FromContext(req.Context()); ok {
+				attrs = append(attrs, slog.String("tenant", principal.TenantID))
+			}
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			logger.LogAttrs(req.Context(), level, "request", attrs...)
+
+			return err
+		}
+	}
+}
@@ -1,24 +1,42 @@
 package main
 
 import (
-	"csv-importer-backend/cmd/csv-importer/apis"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/logging"
 	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/kelseyhightower/envconfig"
-	"github.com/labstack/echo/v4"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-type EnvCfg struct {
-	DBHost     string `envconfig:"DB_HOST" required:"true"`
-	DBPort     int    `envconfig:"DB_PORT" required:"true"`
-	DBUser     string `envconfig:"DB_USER" required:"true"`
-	DBPassword string `envconfig:"DB_PASSWORD" required:"true"`
-	DBName     string `envconfig:"DB_NAME" required:"true"`
-}
+const usage = `usage: csv-importer <command> [flags]
+
+commands:
+  serve                                  start the HTTP server (default)
+  import --name NAME --file FILE         import a CSV as a new event, of
+                                         --type TYPE (default todos), with
+                                         --transforms FILE, or --dry-run;
+                                         --duplicate-columns COLS finds
+                                         duplicate rows, --mode partial
+                                         imports the valid rows only
+  export --event ID [--out FILE]         write the rows of an event as CSV
+  events list                            list events
+  migrate [up | down [N] | status | version]
+
+import, export and events accept --tenant (default "default").
+`
+
+const traceFlushTimeout = 5 * time.Second
 
 func main() {
 
@@ -27,44 +45,142 @@ func main() {
 		panic(err)
 	}
 
+	// The first SIGINT or SIGTERM starts a graceful shutdown, a second one
+	// kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err = run(ctx, os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// run dispatches a command line. Every command shares EnvCfg and the
+// database connection.
+func run(ctx context.Context, args []string, out io.Writer) error {
+
+	cmd := "serve"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "serve", "import", "export", "events", "migrate":
+	case "help", "-h", "--help":
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+	}
+
 	var cfg EnvCfg
-	err = envconfig.Process("CSV_IMPORTER", &cfg)
+	err := loadEnvCfg(&cfg)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	db, err := gorm.Open(
-		postgres.Open(
-			fmt.Sprintf(
-				"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
-				cfg.DBHost,
-				cfg.DBPort,
-				cfg.DBUser,
-				cfg.DBPassword,
-				cfg.DBName,
-			),
-		),
-	)
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logger := logging.New(os.Stderr, cfg.LogFormat, level)
+	slog.SetDefault(logger)
 
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.TraceExporter,
+		File:        cfg.TraceFile,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	// Flush the last spans even though ctx is already cancelled
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), traceFlushTimeout)
+		defer cancel()
+
+		err := shutdownTracing(flushCtx)
+		if err != nil {
+			slog.Warn("flushing traces", "error", err.Error())
+		}
+	}()
+
+	var schemas *schema.Registry
+	if cmd == "import" || cmd == "export" {
+		schemas, err = schema.Load(cfg.SchemaDir)
+		if err != nil {
+			return err
+		}
 	}
 
-	e := echo.New()
+	db, err := openDB(cfg, logger)
+	if err != nil {
+		return err
+	}
 
-	rootg := e.Group("")
-	v1g := rootg.Group("/api/v1")
+	switch cmd {
+	case "import":
+		err = schemas.Ensure(ctx, db)
+		if err != nil {
+			return err
+		}
+		return runImport(ctx, repository.NewEventRepo(db), schemas, args, out)
+	case "export":
+		return runExport(ctx, repository.NewEventRepo(db), schemas, args, out)
+	case "events":
+		return runEvents(ctx, repository.NewEventRepo(db), args, out)
+	case "migrate":
+		return runMigrate(ctx, db, args, out)
+	}
 
-	apis.
-		NewHealthCheckAPI(db).
-		Setup(rootg)
+	return runServe(ctx, cfg, db, out)
+}
 
-	eventRepo := repository.NewEventRepo(db)
+func openDB(cfg EnvCfg, logger *slog.Logger) (*gorm.DB, error) {
 
-	apis.
-		NewEventAPI(eventRepo).
-		Setup(v1g)
+	config := &gorm.Config{
+		Logger: logging.NewGormLogger(logger, cfg.DBSlowQueryThreshold),
+	}
+
+	if cfg.DBDriver == repository.DriverSQLite {
+		db, err := repository.Open(repository.DriverSQLite, cfg.DBPath, config)
+		if err != nil {
+			return nil, err
+		}
+
+		return db, db.Use(tracing.GormPlugin{})
+	}
+
+	dsn, err := cfg.postgresDSN()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := repository.Open(repository.DriverPostgres, dsn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Use(tracing.GormPlugin{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
 
-	e.Start(":8080")
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
 
+	return db, nil
 }
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/repository"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
-	"github.com/kelseyhightower/envconfig"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +28,7 @@ func TestEnvCfg_EnvironmentVariables(t *testing.T) {
 	}()
 
 	var cfg EnvCfg
-	err := envconfig.Process("CSV_IMPORTER", &cfg)
+	err := loadEnvCfg(&cfg)
 	assert.NoError(t, err)
 	assert.Equal(t, "localhost", cfg.DBHost)
 	assert.Equal(t, 5432, cfg.DBPort)
@@ -49,7 +52,7 @@ func TestEnvCfg_MissingRequiredVariables(t *testing.T) {
 	}
 
 	var cfg EnvCfg
-	err := envconfig.Process("CSV_IMPORTER", &cfg)
+	err := loadEnvCfg(&cfg)
 	assert.Error(t, err, "Should fail when required environment variables are missing")
 }
 
@@ -64,7 +67,7 @@ func TestEnvCfg_PartiallyMissingVariables(t *testing.T) {
 	}()
 
 	var cfg EnvCfg
-	err := envconfig.Process("CSV_IMPORTER", &cfg)
+	err := loadEnvCfg(&cfg)
 	assert.Error(t, err, "Should fail when some required environment variables are missing")
 }
 
@@ -83,7 +86,7 @@ func TestEnvCfg_InvalidPortValue(t *testing.T) {
 	}()
 
 	var cfg EnvCfg
-	err := envconfig.Process("CSV_IMPORTER", &cfg)
+	err := loadEnvCfg(&cfg)
 	assert.Error(t, err, "Should fail when port is not a valid integer")
 }
 
@@ -103,7 +106,7 @@ func TestEnvCfg_EmptyValues(t *testing.T) {
 	}()
 
 	var cfg EnvCfg
-	err := envconfig.Process("CSV_IMPORTER", &cfg)
+	err := loadEnvCfg(&cfg)
 	// envconfig may not fail on empty strings, only on missing env vars
 	// This test verifies the behavior - error or success both are valid
 	if err != nil {
@@ -131,7 +134,7 @@ func TestEnvCfg_DefaultPrefix(t *testing.T) {
 	}()
 
 	var cfg EnvCfg
-	err := envconfig.Process("CSV_IMPORTER", &cfg)
+	err := loadEnvCfg(&cfg)
 	assert.NoError(t, err)
 	assert.Equal(t, "correct_host", cfg.DBHost, "Should use CSV_IMPORTER prefix")
 }
@@ -158,14 +161,12 @@ func TestEnvCfg_NumericPortValues(t *testing.T) {
 		{
 			name:        "Zero port",
 			portValue:   "0",
-			expectError: false,
-			expectedPort: 0,
+			expectError: true,
 		},
 		{
 			name:        "Negative port",
 			portValue:   "-1",
-			expectError: false,
-			expectedPort: -1,
+			expectError: true,
 		},
 		{
 			name:        "Very large port",
@@ -176,8 +177,7 @@ func TestEnvCfg_NumericPortValues(t *testing.T) {
 		{
 			name:        "Port too large",
 			portValue:   "65536",
-			expectError: false,
-			expectedPort: 65536, // envconfig will parse this, app logic should validate
+			expectError: true,
 		},
 		{
 			name:        "Float value",
@@ -202,7 +202,7 @@ func TestEnvCfg_NumericPortValues(t *testing.T) {
 			}()
 
 			var cfg EnvCfg
-			err := envconfig.Process("CSV_IMPORTER", &cfg)
+			err := loadEnvCfg(&cfg)
 			
 			if tc.expectError {
 				assert.Error(t, err)
@@ -267,4 +267,84 @@ func formatConnectionString(cfg EnvCfg) string {
 		cfg.DBPassword,
 		cfg.DBName,
 	)
-}
\ No newline at end of file
+}
+func TestEnvCfg_RateLimitDefaults(t *testing.T) {
+	os.Setenv("CSV_IMPORTER_DB_HOST", "localhost")
+	os.Setenv("CSV_IMPORTER_DB_PORT", "5432")
+	os.Setenv("CSV_IMPORTER_DB_USER", "testuser")
+	os.Setenv("CSV_IMPORTER_DB_PASSWORD", "testpass")
+	os.Setenv("CSV_IMPORTER_DB_NAME", "testdb")
+	os.Setenv("CSV_IMPORTER_MAX_CONCURRENT_IMPORTS", "2")
+	defer func() {
+		os.Unsetenv("CSV_IMPORTER_DB_HOST")
+		os.Unsetenv("CSV_IMPORTER_DB_PORT")
+		os.Unsetenv("CSV_IMPORTER_DB_USER")
+		os.Unsetenv("CSV_IMPORTER_DB_PASSWORD")
+		os.Unsetenv("CSV_IMPORTER_DB_NAME")
+		os.Unsetenv("CSV_IMPORTER_MAX_CONCURRENT_IMPORTS")
+	}()
+
+	var cfg EnvCfg
+	err := loadEnvCfg(&cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, cfg.RateLimitUploadsPerMinute)
+	assert.Equal(t, 5, cfg.RateLimitUploadBurst)
+	assert.Equal(t, 600, cfg.RateLimitReadsPerMinute)
+	assert.Equal(t, 100, cfg.RateLimitReadBurst)
+	assert.Equal(t, 10, cfg.RateLimitAuthFailuresPerMinute)
+	assert.Equal(t, 20, cfg.RateLimitAuthFailureBurst)
+	assert.Equal(t, 2, cfg.MaxConcurrentImports)
+	assert.True(t, cfg.MigrateOnStart)
+	assert.Equal(t, 30*time.Second, cfg.ShutdownTimeout)
+}
+
+func TestRunMigrate_Usage(t *testing.T) {
+	testCases := [][]string{
+		{"sideways"},
+		{"up", "extra"},
+		{"down", "1", "extra"},
+	}
+
+	db, err := repository.Open(repository.DriverSQLite, ":memory:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range testCases {
+		var out bytes.Buffer
+		err := runMigrate(context.Background(), db, args, &out)
+		assert.ErrorIs(t, err, errUsage, "args %v", args)
+	}
+
+	var out bytes.Buffer
+	err = runMigrate(context.Background(), db, []string{"down", "zero"}, &out)
+	assert.Error(t, err)
+}
+
+func TestEnvCfg_SQLiteDriver(t *testing.T) {
+	t.Setenv("CSV_IMPORTER_DB_DRIVER", "sqlite")
+	t.Setenv("CSV_IMPORTER_DB_PATH", "/tmp/events.db")
+
+	var cfg EnvCfg
+	err := loadEnvCfg(&cfg)
+	assert.NoError(t, err, "The sqlite driver needs no postgres settings")
+	assert.Equal(t, "sqlite", cfg.DBDriver)
+	assert.Equal(t, "/tmp/events.db", cfg.DBPath)
+}
+
+func TestEnvCfg_UnsupportedDriver(t *testing.T) {
+	t.Setenv("CSV_IMPORTER_DB_DRIVER", "mysql")
+
+	var cfg EnvCfg
+	err := loadEnvCfg(&cfg)
+	assert.Error(t, err)
+}
+
+func TestEnvCfg_MissingVariablesAreNamed(t *testing.T) {
+	t.Setenv("CSV_IMPORTER_DB_HOST", "localhost")
+	t.Setenv("CSV_IMPORTER_DB_PORT", "5432")
+
+	var cfg EnvCfg
+	err := loadEnvCfg(&cfg)
+	assert.EqualError(t, err, "required key CSV_IMPORTER_DB_USER, CSV_IMPORTER_DB_PASSWORD, CSV_IMPORTER_DB_NAME missing value")
+}
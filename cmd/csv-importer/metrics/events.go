@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const eventCountTimeout = 5 * time.Second
+
+var eventStatuses = []model.EventStatus{model.Created, model.Start, model.End}
+
+type EventCounter interface {
+	CountEventsByStatus(ctx context.Context) (map[model.EventStatus]int64, error)
+}
+
+// eventCollector queries the current number of events per status on every
+// scrape, so that the gauge is right across restarts and replicas.
+type eventCollector struct {
+	counter EventCounter
+	desc    *prometheus.Desc
+}
+
+func NewEventCollector(counter EventCounter) prometheus.Collector {
+
+	return &eventCollector{
+		counter: counter,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "events"),
+			"Events that are not deleted, by status.",
+			[]string{"status"},
+			nil,
+		),
+	}
+}
+
+func (c *eventCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *eventCollector) Collect(ch chan<- prometheus.Metric) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventCountTimeout)
+	defer cancel()
+
+	counts, err := c.counter.CountEventsByStatus(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	// Only known statuses become label values
+	for _, status := range eventStatuses {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), string(status))
+	}
+}
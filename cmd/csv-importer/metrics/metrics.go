@@ -0,0 +1,181 @@
+// Package metrics exposes the service's Prometheus metrics. Every label is
+// taken from a fixed set (route templates, methods, status codes, enums) so
+// that cardinality stays bounded no matter what clients send.
+package metrics
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/importer"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "csv_importer"
+
+// unmatchedRoute labels requests that hit no route, instead of their path.
+const unmatchedRoute = "unmatched"
+
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	imports        *prometheus.CounterVec
+	importDuration prometheus.Histogram
+	importRows     *prometheus.HistogramVec
+	uploadBytes    prometheus.Histogram
+}
+
+// New returns metrics on a registry of their own, which also carries the Go
+// runtime and process collectors.
+func New() *Metrics {
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "code"}),
+
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+
+		imports: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "imports_total",
+			Help:      "Finished CSV imports by result.",
+		}, []string{"result"}),
+
+		importDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "import_duration_seconds",
+			Help:      "Time taken to parse and store a CSV import.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}),
+
+		importRows: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "import_rows",
+			Help:      "Rows per import, by whether they were imported or rejected.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"outcome"}),
+
+		uploadBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upload_size_bytes",
+			Help:      "Size of uploaded CSV files.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.imports,
+		m.importDuration,
+		m.importRows,
+		m.uploadBytes,
+	)
+
+	// Results appear as zero before the first import
+	for _, result := range []string{"success", "failure"} {
+		m.imports.WithLabelValues(result)
+	}
+
+	return m
+}
+
+// Register adds further collectors, such as database pool statistics.
+func (m *Metrics) Register(cs ...prometheus.Collector) error {
+
+	for _, c := range cs {
+		err := m.registry.Register(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times requests by their route template. Requests
+// selected by skipper are not recorded.
+func (m *Metrics) Middleware(skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			start := time.Now()
+			err := next(c)
+
+			// The error handler has not written the response yet
+			status := c.Response().Status
+			if err != nil {
+				status = apperr.From(err).HTTPStatus()
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = unmatchedRoute
+			}
+
+			method := c.Request().Method
+			if !knownMethods[method] {
+				method = "OTHER"
+			}
+
+			m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+			m.requestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// ImportFinished implements importer.Observer.
+func (m *Metrics) ImportFinished(stats importer.Stats) {
+
+	result := "success"
+	if stats.Err != nil {
+		result = "failure"
+	}
+
+	m.imports.WithLabelValues(result).Inc()
+	m.importDuration.Observe(stats.Duration.Seconds())
+	m.importRows.WithLabelValues("imported").Observe(float64(stats.RowsImported))
+	m.importRows.WithLabelValues("rejected").Observe(float64(stats.RowsRejected))
+	m.uploadBytes.Observe(float64(stats.Bytes))
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/importer"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	m := New()
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.Use(m.Middleware(nil))
+	e.GET("/events/:id", func(c echo.Context) error {
+		if c.Param("id") == "missing" {
+			return apperr.NotFound("event not found")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, target := range []string{"/events/1", "/events/2", "/events/missing", "/random/path", "/other/random/path"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("/events/:id", "GET", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("/events/:id", "GET", "404")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues(unmatchedRoute, "GET", "404")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.requests), "Raw paths never become labels")
+	assert.Equal(t, 2, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestMiddleware_UnknownMethod(t *testing.T) {
+	m := New()
+
+	e := echo.New()
+	e.Use(m.Middleware(nil))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/", nil))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues(unmatchedRoute, "OTHER", "404")))
+}
+
+func TestMiddleware_Skipper(t *testing.T) {
+	m := New()
+
+	e := echo.New()
+	e.Use(m.Middleware(func(c echo.Context) bool { return true }))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, 0, testutil.CollectAndCount(m.requests))
+}
+
+func TestMetrics_ImportFinished(t *testing.T) {
+	m := New()
+
+	m.ImportFinished(importer.Stats{Duration: 200 * time.Millisecond, Bytes: 2048, RowsImported: 40})
+	m.ImportFinished(importer.Stats{Duration: 10 * time.Millisecond, Bytes: 100, Err: errors.New("bad csv")})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.imports.WithLabelValues("success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.imports.WithLabelValues("failure")))
+
+	expected := `
+# HELP csv_importer_upload_size_bytes Size of uploaded CSV files.
+# TYPE csv_importer_upload_size_bytes histogram
+csv_importer_upload_size_bytes_bucket{le="1024"} 1
+csv_importer_upload_size_bytes_bucket{le="4096"} 2
+csv_importer_upload_size_bytes_bucket{le="16384"} 2
+csv_importer_upload_size_bytes_bucket{le="65536"} 2
+csv_importer_upload_size_bytes_bucket{le="262144"} 2
+csv_importer_upload_size_bytes_bucket{le="1.048576e+06"} 2
+csv_importer_upload_size_bytes_bucket{le="4.194304e+06"} 2
+csv_importer_upload_size_bytes_bucket{le="1.6777216e+07"} 2
+csv_importer_upload_size_bytes_bucket{le="6.7108864e+07"} 2
+csv_importer_upload_size_bytes_bucket{le="2.68435456e+08"} 2
+csv_importer_upload_size_bytes_bucket{le="+Inf"} 2
+csv_importer_upload_size_bytes_sum 2148
+csv_importer_upload_size_bytes_count 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(m.uploadBytes, strings.NewReader(expected)))
+}
+
+type fakeCounter struct {
+	counts map[model.EventStatus]int64
+	err    error
+}
+
+func (f fakeCounter) CountEventsByStatus(ctx context.Context) (map[model.EventStatus]int64, error) {
+	return f.counts, f.err
+}
+
+func TestEventCollector(t *testing.T) {
+	collector := NewEventCollector(fakeCounter{counts: map[model.EventStatus]int64{
+		model.Created:          3,
+		model.End:              1,
+		model.EventStatus("x"): 7,
+	}})
+
+	expected := `
+# HELP csv_importer_events Events that are not deleted, by status.
+# TYPE csv_importer_events gauge
+csv_importer_events{status="draft"} 3
+csv_importer_events{status="end"} 1
+csv_importer_events{status="start"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
+
+func TestEventCollector_Error(t *testing.T) {
+	m := New()
+	require.NoError(t, m.Register(NewEventCollector(fakeCounter{err: errors.New("database is down")})))
+
+	_, err := m.registry.Gather()
+	assert.ErrorContains(t, err, "database is down")
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.ImportFinished(importer.Stats{RowsImported: 1})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `csv_importer_imports_total{result="success"} 1`)
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+}
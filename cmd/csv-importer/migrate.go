@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/migration"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+var errUsage = errors.New("usage: csv-importer migrate [up | down [steps] | status | version]")
+
+// runMigrate implements the migrate subcommand. Without arguments it applies
+// every pending migration.
+func runMigrate(ctx context.Context, db *gorm.DB, args []string, out io.Writer) error {
+
+	migrator, err := migration.New(db)
+	if err != nil {
+		return err
+	}
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		if len(args) > 1 {
+			return errUsage
+		}
+
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return errUsage
+		}
+
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid steps %q, must be a positive number", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Fprintf(out, "reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.UTC().Format("2006-01-02T15:04:05Z")
+			}
+			fmt.Fprintf(out, "%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%d (latest %d)\n", version, migrator.Latest())
+
+	default:
+		return errUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations are kept per dialect under sql/<dialect>, with the same versions
+// and names in every directory.
+//
+//go:embed sql/postgres/*.sql sql/sqlite/*.sql
+var embedded embed.FS
+
+// lockKey identifies the postgres advisory lock held while migrating, so that
+// replicas starting at the same time apply every migration exactly once.
+const lockKey int64 = 0x637376696d70 // "csvimp"
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type schemaMigration struct {
+	Version   int       `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations embedded in the binary that
+// match the dialect of db.
+func New(db *gorm.DB) (*Migrator, error) {
+
+	dialect := db.Dialector.Name()
+
+	dir := path.Join("sql", dialect)
+	if _, err := fs.Stat(embedded, dir); err != nil {
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+
+	return NewFromFS(db, embedded, dir)
+}
+
+func NewFromFS(db *gorm.DB, fsys fs.FS, dir string) (*Migrator, error) {
+
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Load reads <version>_<name>.up.sql and .down.sql pairs from dir, sorted by
+// version. Every version needs both files.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: file name must match <version>_<name>.(up|down).sql", entry.Name())
+		}
+
+		version, err := strconv.Atoi(m[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %04d: conflicting names %q and %q", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s: both up and down files are required", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest is the version the schema has once every migration is applied.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration in order and returns the applied ones.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+
+	var applied []Migration
+
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+
+				err := tx.Exec(migration.Up).Error
+				if err != nil {
+					return err
+				}
+
+				return tx.Create(&schemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s up: %w", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+
+	var reverted []Migration
+
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+
+				err := tx.Exec(migration.Down).Error
+				if err != nil {
+					return err
+				}
+
+				return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+			})
+
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s down: %w", migration.Version, migration.Name, err)
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Version returns the highest applied migration, or 0 on an empty database.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+
+	if !m.db.WithContext(ctx).Migrator().HasTable(&schemaMigration{}) {
+		return 0, nil
+	}
+
+	var version int
+	err := m.db.WithContext(ctx).
+		Model(&schemaMigration{}).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).
+		Error
+
+	return version, err
+}
+
+// Status lists every known migration with the time it was applied, if ever.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+
+	conn := m.db.WithContext(ctx)
+
+	done := map[int]schemaMigration{}
+	if conn.Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		done, err = appliedVersions(conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+
+		if row, ok := done[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// locked runs fn on a single connection holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB) error) error {
+
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+
+		// Connection hands out a bare instance whose statement would be shared
+		// by every chained call, start a fresh session on the pinned conn.
+		conn = conn.Session(&gorm.Session{})
+
+		if conn.Dialector.Name() == "postgres" {
+			err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error
+			if err != nil {
+				return fmt.Errorf("acquire migration lock: %w", err)
+			}
+
+			// Session locks survive the connection going back to the pool,
+			// so release it even when ctx is already canceled.
+			defer conn.WithContext(context.WithoutCancel(ctx)).Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		}
+
+		err := ensureTable(conn)
+		if err != nil {
+			return err
+		}
+
+		return fn(conn)
+	})
+}
+
+// ensureTable creates schema_migrations. SQLite only scans applied_at back
+// into a time.Time when the column is declared as datetime.
+func ensureTable(db *gorm.DB) error {
+
+	timeType := "timestamptz"
+	if db.Dialector.Name() == "sqlite" {
+		timeType = "datetime"
+	}
+
+	err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint NOT NULL,
+	name varchar(200) NOT NULL,
+	applied_at %s NOT NULL,
+	CONSTRAINT schema_migrations_pk PRIMARY KEY (version)
+)`, timeType)).Error
+
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return nil
+}
+
+func appliedVersions(db *gorm.DB) (map[int]schemaMigration, error) {
+
+	var rows []schemaMigration
+	err := db.Order("version").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]schemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+
+	return done, nil
+}
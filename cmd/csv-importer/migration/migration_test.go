@@ -0,0 +1,194 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { db.Close() })
+
+	return gormDB, mock
+}
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"sql/0001_first.up.sql":    {Data: []byte("CREATE TABLE first (id int)")},
+		"sql/0001_first.down.sql":  {Data: []byte("DROP TABLE first")},
+		"sql/0002_second.up.sql":   {Data: []byte("CREATE TABLE second (id int)")},
+		"sql/0002_second.down.sql": {Data: []byte("DROP TABLE second")},
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	postgresMigrations, err := Load(embedded, "sql/postgres")
+	require.NoError(t, err)
+	require.NotEmpty(t, postgresMigrations)
+
+	for i, m := range postgresMigrations {
+		assert.Equal(t, i+1, m.Version, "Versions must be contiguous")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+
+	sqliteMigrations, err := Load(embedded, "sql/sqlite")
+	require.NoError(t, err)
+	require.Len(t, sqliteMigrations, len(postgresMigrations), "Every dialect needs the same migrations")
+
+	for i, m := range sqliteMigrations {
+		assert.Equal(t, postgresMigrations[i].Version, m.Version)
+		assert.Equal(t, postgresMigrations[i].Name, m.Name)
+	}
+}
+
+func TestLoad_Sorted(t *testing.T) {
+	fsys := testFS()
+	fsys["sql/0010_tenth.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+	fsys["sql/0010_tenth.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+
+	migrations, err := Load(fsys, "sql")
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, []int{1, 2, 10}, []int{migrations[0].Version, migrations[1].Version, migrations[2].Version})
+	assert.Equal(t, "second", migrations[1].Name)
+	assert.Equal(t, "DROP TABLE second", migrations[1].Down)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "Missing down file",
+			files: fstest.MapFS{"sql/0001_first.up.sql": {Data: []byte("SELECT 1")}},
+		},
+		{
+			name:  "Bad file name",
+			files: fstest.MapFS{"sql/first.sql": {Data: []byte("SELECT 1")}},
+		},
+		{
+			name: "Conflicting names",
+			files: fstest.MapFS{
+				"sql/0001_first.up.sql":   {Data: []byte("SELECT 1")},
+				"sql/0001_other.down.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.files, "sql")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrator_Up_AppliesPendingUnderLock(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+
+	migrator, err := NewFromFS(gormDB, testFS(), "sql")
+	require.NoError(t, err)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations" ORDER BY version`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}).AddRow(1, "first", time.Now()))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE second`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "schema_migrations"`).
+		WithArgs(2, "second", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 2, applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_FailureRollsBackAndUnlocks(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+
+	migrator, err := NewFromFS(gormDB, testFS(), "sql")
+	require.NoError(t, err)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE first`).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "0001")
+	assert.Empty(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down_RevertsNewestFirst(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+
+	migrator, err := NewFromFS(gormDB, testFS(), "sql")
+	require.NoError(t, err)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}).
+			AddRow(1, "first", time.Now()).
+			AddRow(2, "second", time.Now()))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE second`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "schema_migrations" WHERE version = \$1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	reverted, err := migrator.Down(context.Background(), 1)
+
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, 2, reverted[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Latest(t *testing.T) {
+	migrator, err := NewFromFS(nil, testFS(), "sql")
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrator.Latest())
+}
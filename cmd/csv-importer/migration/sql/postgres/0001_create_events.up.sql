@@ -1,4 +1,4 @@
-CREATE TABLE public.events (
+CREATE TABLE IF NOT EXISTS public.events (
 	id varchar(100) NOT NULL,
 	name varchar(100) NOT NULL,
 	status varchar(10) NOT NULL,
@@ -7,4 +7,3 @@ CREATE TABLE public.events (
 	delete_date timestamptz NULL,
 	CONSTRAINT events_pk PRIMARY KEY (id)
 );
-
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS public.todo_events;
+
+DROP INDEX IF EXISTS public.events_tenant_id_idx;
+
+ALTER TABLE public.events DROP COLUMN IF EXISTS tenant_id;
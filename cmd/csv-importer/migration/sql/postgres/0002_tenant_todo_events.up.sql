@@ -0,0 +1,18 @@
+ALTER TABLE public.events ADD COLUMN IF NOT EXISTS tenant_id varchar(100) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS events_tenant_id_idx ON public.events (tenant_id);
+
+CREATE TABLE IF NOT EXISTS public.todo_events (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL DEFAULT 'default',
+	event_id varchar(100) NOT NULL,
+	name text NOT NULL,
+	note text NOT NULL,
+	create_date timestamptz NOT NULL,
+	update_date timestamptz NOT NULL,
+	delete_date timestamptz NULL,
+	CONSTRAINT todo_events_pk PRIMARY KEY (id),
+	CONSTRAINT todo_events_event_fk FOREIGN KEY (event_id) REFERENCES public.events (id)
+);
+
+CREATE INDEX IF NOT EXISTS todo_events_tenant_event_idx ON public.todo_events (tenant_id, event_id);
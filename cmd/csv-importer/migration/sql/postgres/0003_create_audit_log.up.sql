@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS public.audit_log (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	actor varchar(200) NOT NULL,
+	action varchar(50) NOT NULL,
+	target_type varchar(50) NOT NULL,
+	target_id varchar(100) NOT NULL,
+	request_id varchar(128) NULL,
+	before jsonb NULL,
+	after jsonb NULL,
+	diff jsonb NULL,
+	create_date timestamptz NOT NULL,
+	CONSTRAINT audit_log_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_tenant_date_idx ON public.audit_log (tenant_id, create_date DESC);
+CREATE INDEX IF NOT EXISTS audit_log_tenant_target_idx ON public.audit_log (tenant_id, target_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS public.todo_events_tenant_event_status_idx;
+
+ALTER TABLE public.todo_events DROP COLUMN IF EXISTS tags;
+ALTER TABLE public.todo_events DROP COLUMN IF EXISTS assignee_email;
+ALTER TABLE public.todo_events DROP COLUMN IF EXISTS due_date;
+ALTER TABLE public.todo_events DROP COLUMN IF EXISTS priority;
+ALTER TABLE public.todo_events DROP COLUMN IF EXISTS status;
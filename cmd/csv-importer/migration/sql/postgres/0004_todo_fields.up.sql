@@ -0,0 +1,7 @@
+ALTER TABLE public.todo_events ADD COLUMN IF NOT EXISTS status varchar(10) NOT NULL DEFAULT 'open';
+ALTER TABLE public.todo_events ADD COLUMN IF NOT EXISTS priority varchar(10) NULL;
+ALTER TABLE public.todo_events ADD COLUMN IF NOT EXISTS due_date timestamptz NULL;
+ALTER TABLE public.todo_events ADD COLUMN IF NOT EXISTS assignee_email varchar(254) NULL;
+ALTER TABLE public.todo_events ADD COLUMN IF NOT EXISTS tags text NULL;
+
+CREATE INDEX IF NOT EXISTS todo_events_tenant_event_status_idx ON public.todo_events (tenant_id, event_id, status);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.todo_events_custom_fields_idx;
+
+ALTER TABLE public.todo_events DROP COLUMN IF EXISTS custom_fields;
@@ -0,0 +1,3 @@
+ALTER TABLE public.todo_events ADD COLUMN IF NOT EXISTS custom_fields jsonb NULL;
+
+CREATE INDEX IF NOT EXISTS todo_events_custom_fields_idx ON public.todo_events USING gin (custom_fields jsonb_path_ops);
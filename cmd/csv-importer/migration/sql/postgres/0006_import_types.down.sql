@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.attendees;
+
+ALTER TABLE public.events DROP COLUMN IF EXISTS import_type;
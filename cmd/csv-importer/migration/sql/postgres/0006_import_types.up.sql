@@ -0,0 +1,18 @@
+ALTER TABLE public.events ADD COLUMN IF NOT EXISTS import_type varchar(50) NOT NULL DEFAULT 'todos';
+
+CREATE TABLE IF NOT EXISTS public.attendees (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	event_id varchar(100) NOT NULL,
+	name text NOT NULL,
+	email varchar(254) NOT NULL,
+	company text NULL,
+	ticket_type varchar(20) NULL,
+	checked_in boolean NULL,
+	registered_on timestamptz NULL,
+	create_date timestamptz NOT NULL,
+	CONSTRAINT attendees_pk PRIMARY KEY (id),
+	CONSTRAINT attendees_event_fk FOREIGN KEY (event_id) REFERENCES public.events (id)
+);
+
+CREATE INDEX IF NOT EXISTS attendees_tenant_event_idx ON public.attendees (tenant_id, event_id);
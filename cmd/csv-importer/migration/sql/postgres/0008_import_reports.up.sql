@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS public.import_reports (
+	event_id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	content text NOT NULL,
+	create_date timestamptz NOT NULL,
+	CONSTRAINT import_reports_pk PRIMARY KEY (event_id),
+	CONSTRAINT import_reports_event_fk FOREIGN KEY (event_id) REFERENCES public.events (id)
+);
+
+CREATE INDEX IF NOT EXISTS import_reports_tenant_idx ON public.import_reports (tenant_id, event_id);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS public.uploads (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	length bigint NOT NULL,
+	upload_offset bigint NOT NULL,
+	metadata jsonb NOT NULL,
+	chunks jsonb NOT NULL,
+	status varchar(20) NOT NULL,
+	event_id varchar(100) NULL,
+	result jsonb NULL,
+	created_by varchar(200) NOT NULL,
+	create_date timestamptz NOT NULL,
+	update_date timestamptz NOT NULL,
+	CONSTRAINT uploads_pk PRIMARY KEY (id),
+	CONSTRAINT uploads_event_fk FOREIGN KEY (event_id) REFERENCES public.events (id)
+);
+
+CREATE INDEX IF NOT EXISTS uploads_tenant_idx ON public.uploads (tenant_id, id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS events (
+	id varchar(100) NOT NULL,
+	name varchar(100) NOT NULL,
+	status varchar(10) NOT NULL,
+	create_date datetime NOT NULL,
+	update_date datetime NOT NULL,
+	delete_date datetime NULL,
+	CONSTRAINT events_pk PRIMARY KEY (id)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS todo_events;
+
+DROP INDEX IF EXISTS events_tenant_id_idx;
+
+ALTER TABLE events DROP COLUMN tenant_id;
@@ -0,0 +1,18 @@
+ALTER TABLE events ADD COLUMN tenant_id varchar(100) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS events_tenant_id_idx ON events (tenant_id);
+
+CREATE TABLE IF NOT EXISTS todo_events (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL DEFAULT 'default',
+	event_id varchar(100) NOT NULL,
+	name text NOT NULL,
+	note text NOT NULL,
+	create_date datetime NOT NULL,
+	update_date datetime NOT NULL,
+	delete_date datetime NULL,
+	CONSTRAINT todo_events_pk PRIMARY KEY (id),
+	CONSTRAINT todo_events_event_fk FOREIGN KEY (event_id) REFERENCES events (id)
+);
+
+CREATE INDEX IF NOT EXISTS todo_events_tenant_event_idx ON todo_events (tenant_id, event_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS todo_events_tenant_event_status_idx;
+
+ALTER TABLE todo_events DROP COLUMN tags;
+ALTER TABLE todo_events DROP COLUMN assignee_email;
+ALTER TABLE todo_events DROP COLUMN due_date;
+ALTER TABLE todo_events DROP COLUMN priority;
+ALTER TABLE todo_events DROP COLUMN status;
@@ -0,0 +1,7 @@
+ALTER TABLE todo_events ADD COLUMN status varchar(10) NOT NULL DEFAULT 'open';
+ALTER TABLE todo_events ADD COLUMN priority varchar(10) NULL;
+ALTER TABLE todo_events ADD COLUMN due_date datetime NULL;
+ALTER TABLE todo_events ADD COLUMN assignee_email varchar(254) NULL;
+ALTER TABLE todo_events ADD COLUMN tags text NULL;
+
+CREATE INDEX IF NOT EXISTS todo_events_tenant_event_status_idx ON todo_events (tenant_id, event_id, status);
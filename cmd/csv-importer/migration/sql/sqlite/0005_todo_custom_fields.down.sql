@@ -0,0 +1 @@
+ALTER TABLE todo_events DROP COLUMN custom_fields;
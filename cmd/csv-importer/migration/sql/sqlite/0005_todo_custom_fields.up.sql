@@ -0,0 +1 @@
+ALTER TABLE todo_events ADD COLUMN custom_fields text NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS attendees;
+
+ALTER TABLE events DROP COLUMN import_type;
@@ -0,0 +1,18 @@
+ALTER TABLE events ADD COLUMN import_type varchar(50) NOT NULL DEFAULT 'todos';
+
+CREATE TABLE IF NOT EXISTS attendees (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	event_id varchar(100) NOT NULL,
+	name text NOT NULL,
+	email varchar(254) NOT NULL,
+	company text NULL,
+	ticket_type varchar(20) NULL,
+	checked_in boolean NULL,
+	registered_on datetime NULL,
+	create_date datetime NOT NULL,
+	CONSTRAINT attendees_pk PRIMARY KEY (id),
+	CONSTRAINT attendees_event_fk FOREIGN KEY (event_id) REFERENCES events (id)
+);
+
+CREATE INDEX IF NOT EXISTS attendees_tenant_event_idx ON attendees (tenant_id, event_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS rejected_rows (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	event_id varchar(100) NOT NULL,
+	row_number integer NOT NULL,
+	header text NOT NULL,
+	line text NOT NULL,
+	reasons text NOT NULL,
+	create_date datetime NOT NULL,
+	CONSTRAINT rejected_rows_pk PRIMARY KEY (id),
+	CONSTRAINT rejected_rows_event_fk FOREIGN KEY (event_id) REFERENCES events (id)
+);
+
+CREATE INDEX IF NOT EXISTS rejected_rows_tenant_event_idx ON rejected_rows (tenant_id, event_id, row_number);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS import_sources;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS import_sources (
+	event_id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	blob_key varchar(255) NOT NULL,
+	sha256 char(64) NOT NULL,
+	size integer NOT NULL,
+	filename varchar(255) NOT NULL,
+	content_type varchar(255) NOT NULL,
+	uploaded_by varchar(200) NOT NULL,
+	create_date datetime NOT NULL,
+	CONSTRAINT import_sources_pk PRIMARY KEY (event_id),
+	CONSTRAINT import_sources_event_fk FOREIGN KEY (event_id) REFERENCES events (id)
+);
+
+CREATE INDEX IF NOT EXISTS import_sources_tenant_idx ON import_sources (tenant_id, event_id);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS uploads (
+	id varchar(100) NOT NULL,
+	tenant_id varchar(100) NOT NULL,
+	length integer NOT NULL,
+	upload_offset integer NOT NULL,
+	metadata text NOT NULL,
+	chunks text NOT NULL,
+	status varchar(20) NOT NULL,
+	event_id varchar(100) NULL,
+	result text NULL,
+	created_by varchar(200) NOT NULL,
+	create_date datetime NOT NULL,
+	update_date datetime NOT NULL,
+	CONSTRAINT uploads_pk PRIMARY KEY (id),
+	CONSTRAINT uploads_event_fk FOREIGN KEY (event_id) REFERENCES events (id)
+);
+
+CREATE INDEX IF NOT EXISTS uploads_tenant_idx ON uploads (tenant_id, id);
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditEventCreate       AuditAction = "event.create"
+	AuditEventStatusChange AuditAction = "event.status_change"
+	AuditEventDelete       AuditAction = "event.delete"
+	AuditImportRun         AuditAction = "import.run"
+)
+
+type AuditLog struct {
+	ID         string      `gorm:"column:id" json:"id"`
+	TenantID   string      `gorm:"column:tenant_id" json:"tenant_id"`
+	Actor      string      `gorm:"column:actor" json:"actor"`
+	Action     AuditAction `gorm:"column:action" json:"action"`
+	TargetType string      `gorm:"column:target_type" json:"target_type"`
+	TargetID   string      `gorm:"column:target_id" json:"target_id"`
+	RequestID  string      `gorm:"column:request_id" json:"request_id,omitempty"`
+	Before     JSON        `gorm:"column:before" json:"before,omitempty"`
+	After      JSON        `gorm:"column:after" json:"after,omitempty"`
+	Diff       JSON        `gorm:"column:diff" json:"diff,omitempty"`
+	CreateDate time.Time   `gorm:"column:create_date" json:"create_date"`
+}
+
+func (m *AuditLog) TableName() string {
+	return "audit_log"
+}
+
+type AuditFilter struct {
+	Actor     string
+	Action    AuditAction
+	TargetID  string
+	RequestID string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
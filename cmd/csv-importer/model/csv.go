@@ -1,6 +1,14 @@
 package model
 
+// TodoCSV is one row of an import file. Only todo_name and note are required;
+// the other columns are parsed by ParseTodoStatus, ParseTodoPriority,
+// ParseDueDate, ParseEmail and ParseTags.
 type TodoCSV struct {
-	TodoName string `csv:"todo_name"`
-	Note     string `csv:"note"`
+	TodoName      string `csv:"todo_name"`
+	Note          string `csv:"note"`
+	Status        string `csv:"status"`
+	Priority      string `csv:"priority"`
+	DueDate       string `csv:"due_date"`
+	AssigneeEmail string `csv:"assignee_email"`
+	Tags          string `csv:"tags"`
 }
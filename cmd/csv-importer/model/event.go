@@ -10,13 +10,36 @@ var (
 	End     EventStatus = "end"
 )
 
+func (s EventStatus) Valid() bool {
+	switch s {
+	case Created, Start, End:
+		return true
+	}
+	return false
+}
+
+// ImportTypeTodos is the import type of events created from todo CSV files,
+// and the default when no other type is asked for.
+const ImportTypeTodos = "todos"
+
 type Event struct {
 	ID         string      `gorm:"column:id" json:"id"`
+	TenantID   string      `gorm:"column:tenant_id" json:"tenant_id"`
 	Name       string      `gorm:"column:name" json:"name"`
 	Status     EventStatus `gorm:"column:status" json:"status"`
+	ImportType string      `gorm:"column:import_type" json:"import_type"`
 	CreateDate time.Time   `gorm:"column:create_date" json:"create_date"`
 	UpdateDate time.Time   `gorm:"column:update_date" json:"update_date"`
 	DeleteDate *time.Time  `gorm:"column:delete_date" json:"delete_date,omitempty"`
+	// Rejected holds the rows a partial import left out, to be stored with
+	// the event. Events read back never have them.
+	Rejected []RejectedRow `gorm:"-" json:"-"`
+	// Report is the error report of a partial import that left rows out,
+	// stored with the event like Rejected.
+	Report *ImportReport `gorm:"-" json:"-"`
+	// Source describes the uploaded file of the import, stored with the
+	// event like Rejected.
+	Source *ImportSource `gorm:"-" json:"-"`
 }
 
 func (m *Event) TableName() string {
@@ -24,9 +47,22 @@ func (m *Event) TableName() string {
 }
 
 type TodoEvent struct {
-	ID         string     `gorm:"column:id" json:"id"`
-	EventID    string     `gorm:"column:event_id" json:"event_id"`
-	CreateDate time.Time  `gorm:"column:create_date" json:"create_date"`
-	UpdateDate time.Time  `gorm:"column:update_date" json:"update_date"`
-	DeleteDate *time.Time `gorm:"column:delete_date" json:"delete_date,omitempty"`
+	ID            string       `gorm:"column:id" json:"id"`
+	TenantID      string       `gorm:"column:tenant_id" json:"tenant_id"`
+	EventID       string       `gorm:"column:event_id" json:"event_id"`
+	Name          string       `gorm:"column:name" json:"name"`
+	Note          string       `gorm:"column:note" json:"note"`
+	Status        TodoStatus   `gorm:"column:status" json:"status"`
+	Priority      TodoPriority `gorm:"column:priority" json:"priority,omitempty"`
+	DueDate       *time.Time   `gorm:"column:due_date" json:"due_date,omitempty"`
+	AssigneeEmail string       `gorm:"column:assignee_email" json:"assignee_email,omitempty"`
+	Tags          Tags         `gorm:"column:tags" json:"tags,omitempty"`
+	CustomFields  CustomFields `gorm:"column:custom_fields" json:"custom_fields,omitempty"`
+	CreateDate    time.Time    `gorm:"column:create_date" json:"create_date"`
+	UpdateDate    time.Time    `gorm:"column:update_date" json:"update_date"`
+	DeleteDate    *time.Time   `gorm:"column:delete_date" json:"delete_date,omitempty"`
+}
+
+func (m *TodoEvent) TableName() string {
+	return "todo_events"
 }
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RejectedRow is a row a partial import left out. Header and Line are the
+// raw text of the header and of the row, without line breaks, so that the
+// rejects of an import can be written back as a CSV, corrected and
+// uploaded again. Row counts data rows from 1, after the header.
+type RejectedRow struct {
+	ID         string        `gorm:"column:id" json:"-"`
+	TenantID   string        `gorm:"column:tenant_id" json:"-"`
+	EventID    string        `gorm:"column:event_id" json:"-"`
+	Row        int           `gorm:"column:row_number" json:"row"`
+	Header     string        `gorm:"column:header" json:"-"`
+	Line       string        `gorm:"column:line" json:"line"`
+	Reasons    RejectReasons `gorm:"column:reasons" json:"reasons"`
+	CreateDate time.Time     `gorm:"column:create_date" json:"-"`
+}
+
+func (m *RejectedRow) TableName() string {
+	return "rejected_rows"
+}
+
+// RejectReason is one problem of a rejected row. Column is empty for
+// problems of the whole row.
+type RejectReason struct {
+	Column string `json:"column,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// RejectReasons are stored as a JSON array.
+type RejectReasons []RejectReason
+
+func (RejectReasons) GormDataType() string {
+	return "json"
+}
+
+func (r RejectReasons) Value() (driver.Value, error) {
+
+	data, err := json.Marshal([]RejectReason(r))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+func (r *RejectReasons) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into model.RejectReasons", src)
+	}
+
+	return json.Unmarshal(data, r)
+}
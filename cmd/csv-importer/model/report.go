@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ImportReport is the uploaded file of a partial import annotated with the
+// problems of every row, for people to correct it in a spreadsheet.
+type ImportReport struct {
+	EventID    string    `gorm:"column:event_id;primaryKey"`
+	TenantID   string    `gorm:"column:tenant_id"`
+	Content    string    `gorm:"column:content"`
+	CreateDate time.Time `gorm:"column:create_date"`
+}
+
+func (m *ImportReport) TableName() string {
+	return "import_reports"
+}
@@ -2,9 +2,15 @@ package model
 
 type BaseResponse struct {
 	Data    any    `json:"data,omitempty"`
+	Code    string `json:"code,omitempty"`
 	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
 }
 
 type EventCreateRequest struct {
 	Name string `json:"name"`
 }
+
+type EventStatusUpdateRequest struct {
+	Status EventStatus `json:"status"`
+}
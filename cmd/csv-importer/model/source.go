@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Longest Filename and ContentType stored, in characters.
+const (
+	MaxSourceFilename    = 255
+	MaxSourceContentType = 255
+)
+
+// ImportSource describes the uploaded file an import read, kept under
+// BlobKey in the blob store.
+type ImportSource struct {
+	EventID     string    `gorm:"column:event_id;primaryKey"`
+	TenantID    string    `gorm:"column:tenant_id"`
+	BlobKey     string    `gorm:"column:blob_key"`
+	SHA256      string    `gorm:"column:sha256"`
+	Size        int64     `gorm:"column:size"`
+	Filename    string    `gorm:"column:filename"`
+	ContentType string    `gorm:"column:content_type"`
+	UploadedBy  string    `gorm:"column:uploaded_by"`
+	CreateDate  time.Time `gorm:"column:create_date"`
+}
+
+func (m *ImportSource) TableName() string {
+	return "import_sources"
+}
+
+// Clip shortens the fields the client chose to the longest ones stored.
+func (m *ImportSource) Clip() {
+	m.Filename = clip(m.Filename, MaxSourceFilename)
+	m.ContentType = clip(m.ContentType, MaxSourceContentType)
+}
+
+func clip(v string, n int) string {
+
+	if utf8.RuneCountInString(v) <= n {
+		return v
+	}
+
+	return string([]rune(v)[:n])
+}
@@ -0,0 +1,267 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+type TodoStatus string
+
+var (
+	TodoOpen TodoStatus = "open"
+	TodoDone TodoStatus = "done"
+)
+
+func (s TodoStatus) Valid() bool {
+	switch s {
+	case TodoOpen, TodoDone:
+		return true
+	}
+	return false
+}
+
+type TodoPriority string
+
+var (
+	PriorityLow    TodoPriority = "low"
+	PriorityMedium TodoPriority = "medium"
+	PriorityHigh   TodoPriority = "high"
+)
+
+func (p TodoPriority) Valid() bool {
+	switch p {
+	case PriorityLow, PriorityMedium, PriorityHigh:
+		return true
+	}
+	return false
+}
+
+const (
+	MaxTags      = 20
+	MaxTagLength = 50
+)
+
+// Tags are stored as one comma-separated text column.
+type Tags []string
+
+func (Tags) GormDataType() string {
+	return "text"
+}
+
+func (t Tags) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	return strings.Join(t, ","), nil
+}
+
+func (t *Tags) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("cannot scan %T into model.Tags", src)
+	}
+
+	*t = nil
+	if s != "" {
+		*t = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// MaxCustomFields caps the unmapped columns an import may keep.
+const MaxCustomFields = 100
+
+// CustomFields are the cells of CSV columns without a todo field, keyed by
+// header. They are stored as a JSON object of strings.
+type CustomFields map[string]string
+
+func (CustomFields) GormDataType() string {
+	return "json"
+}
+
+func (f CustomFields) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(map[string]string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+func (f *CustomFields) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into model.CustomFields", src)
+	}
+
+	*f = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, f)
+}
+
+// ParseTodoStatus accepts open and done in any case. An empty cell is open.
+func ParseTodoStatus(s string) (TodoStatus, error) {
+
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return TodoOpen, nil
+	}
+
+	status := TodoStatus(s)
+	if !status.Valid() {
+		return "", fmt.Errorf("must be one of %s, %s", TodoOpen, TodoDone)
+	}
+
+	return status, nil
+}
+
+// ParseTodoPriority accepts low, medium and high in any case. An empty cell
+// has no priority.
+func ParseTodoPriority(s string) (TodoPriority, error) {
+
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return "", nil
+	}
+
+	priority := TodoPriority(s)
+	if !priority.Valid() {
+		return "", fmt.Errorf("must be one of %s, %s, %s", PriorityLow, PriorityMedium, PriorityHigh)
+	}
+
+	return priority, nil
+}
+
+// DateLayout is the layout due dates without a time of day are exported in.
+const DateLayout = "2006-01-02"
+
+// dueDateLayouts are tried in order. Numeric day/month orders other than
+// year first are ambiguous and deliberately not accepted.
+var dueDateLayouts = []string{
+	time.RFC3339,
+	DateLayout,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006/01/02",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// ParseDueDate accepts ISO 8601 dates and timestamps, and dates with an
+// English month name such as "3 Mar 2025" or "March 3, 2025". Times without
+// an offset are UTC. An empty cell has no due date.
+func ParseDueDate(s string) (*time.Time, error) {
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	for _, layout := range dueDateLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("is not a date, use e.g. %s", DateLayout)
+}
+
+// FormatDueDate is the inverse of ParseDueDate: dates at midnight UTC are
+// written without a time of day.
+func FormatDueDate(t *time.Time) string {
+
+	if t == nil {
+		return ""
+	}
+
+	u := t.UTC()
+	if u.Equal(u.Truncate(24 * time.Hour)) {
+		return u.Format(DateLayout)
+	}
+
+	return u.Format(time.RFC3339)
+}
+
+// ParseEmail accepts a bare address and returns it in lower case. An empty
+// cell has no assignee.
+func ParseEmail(s string) (string, error) {
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return "", fmt.Errorf("is not an email address")
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
+
+// ParseTags splits a comma-separated list into lower case tags, dropping
+// blanks and duplicates.
+func ParseTags(s string) (Tags, error) {
+
+	var tags Tags
+	seen := map[string]bool{}
+
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		if len(tag) > MaxTagLength {
+			return nil, fmt.Errorf("has a tag longer than %d characters", MaxTagLength)
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	if len(tags) > MaxTags {
+		return nil, fmt.Errorf("has more than %d tags", MaxTags)
+	}
+
+	return tags, nil
+}
+
+// TodoFilter narrows the todos of an event. Zero values match everything.
+type TodoFilter struct {
+	Status        TodoStatus
+	Priority      TodoPriority
+	AssigneeEmail string
+	Tag           string
+	DueBefore     *time.Time
+	DueAfter      *time.Time
+	// CustomFields match todos whose custom fields have all of these values.
+	CustomFields map[string]string
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTodoStatus(t *testing.T) {
+	status, err := ParseTodoStatus("")
+	assert.NoError(t, err)
+	assert.Equal(t, TodoOpen, status, "Empty cells are open")
+
+	status, err = ParseTodoStatus(" Done ")
+	assert.NoError(t, err)
+	assert.Equal(t, TodoDone, status)
+
+	_, err = ParseTodoStatus("closed")
+	assert.EqualError(t, err, "must be one of open, done")
+}
+
+func TestParseTodoPriority(t *testing.T) {
+	priority, err := ParseTodoPriority("")
+	assert.NoError(t, err)
+	assert.Empty(t, priority)
+
+	priority, err = ParseTodoPriority("HIGH")
+	assert.NoError(t, err)
+	assert.Equal(t, PriorityHigh, priority)
+
+	_, err = ParseTodoPriority("urgent")
+	assert.Error(t, err)
+}
+
+func TestParseDueDate(t *testing.T) {
+	march3 := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2025-03-03", march3},
+		{"2025/03/03", march3},
+		{"3 Mar 2025", march3},
+		{"3 March 2025", march3},
+		{"Mar 3, 2025", march3},
+		{"March 3, 2025", march3},
+		{"2025-03-03 14:30", march3.Add(14*time.Hour + 30*time.Minute)},
+		{"2025-03-03T14:30:00+07:00", march3.Add(7*time.Hour + 30*time.Minute)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			due, err := ParseDueDate(tc.input)
+			require.NoError(t, err)
+			assert.True(t, tc.expected.Equal(*due), due.String())
+			assert.Equal(t, time.UTC, due.Location())
+		})
+	}
+
+	due, err := ParseDueDate(" ")
+	assert.NoError(t, err)
+	assert.Nil(t, due)
+
+	for _, input := range []string{"03/03/2025", "tomorrow", "2025-13-01"} {
+		_, err := ParseDueDate(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestFormatDueDate(t *testing.T) {
+	date := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2025-03-03", FormatDueDate(&date))
+
+	timestamp := date.Add(90 * time.Minute)
+	assert.Equal(t, "2025-03-03T01:30:00Z", FormatDueDate(&timestamp))
+
+	assert.Empty(t, FormatDueDate(nil))
+}
+
+func TestParseEmail(t *testing.T) {
+	email, err := ParseEmail(" Alice@Example.com ")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", email)
+
+	email, err = ParseEmail("")
+	assert.NoError(t, err)
+	assert.Empty(t, email)
+
+	for _, input := range []string{"alice", "Alice <alice@example.com>", "alice@"} {
+		_, err := ParseEmail(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags(" Venue, catering,,venue ")
+	assert.NoError(t, err)
+	assert.Equal(t, Tags{"venue", "catering"}, tags)
+
+	tags, err = ParseTags("")
+	assert.NoError(t, err)
+	assert.Nil(t, tags)
+
+	_, err = ParseTags("a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s,t,u")
+	assert.EqualError(t, err, "has more than 20 tags")
+}
+
+func TestTags_ValueAndScan(t *testing.T) {
+	value, err := Tags{"venue", "catering"}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "venue,catering", value)
+
+	value, err = Tags(nil).Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	var tags Tags
+	assert.NoError(t, tags.Scan([]byte("venue,catering")))
+	assert.Equal(t, Tags{"venue", "catering"}, tags)
+
+	assert.NoError(t, tags.Scan(nil))
+	assert.Nil(t, tags)
+}
+
+func TestCustomFields_ValueAndScan(t *testing.T) {
+	value, err := CustomFields{"region": "north", "cost": "12"}.Value()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"region":"north","cost":"12"}`, value.(string))
+
+	value, err = CustomFields{}.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	var fields CustomFields
+	assert.NoError(t, fields.Scan([]byte(`{"region":"north"}`)))
+	assert.Equal(t, CustomFields{"region": "north"}, fields)
+
+	assert.NoError(t, fields.Scan(nil))
+	assert.Nil(t, fields)
+
+	assert.Error(t, fields.Scan(42))
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Upload statuses.
+const (
+	UploadReceiving = "receiving"
+	UploadImporting = "importing"
+	UploadImported  = "imported"
+	UploadFailed    = "failed"
+)
+
+// ErrUploadMoved reports that another request appended to an upload, or
+// completed it, since it was read.
+var ErrUploadMoved = errors.New("upload offset moved")
+
+// Upload is a file received in chunks through the tus protocol. Chunks holds
+// the blob keys of the chunks received so far, in order; once Offset reaches
+// Length the file is imported and Result holds the response the upload of
+// the whole file would have had.
+type Upload struct {
+	ID         string         `gorm:"column:id" json:"id"`
+	TenantID   string         `gorm:"column:tenant_id" json:"-"`
+	Length     int64          `gorm:"column:length" json:"length"`
+	Offset     int64          `gorm:"column:upload_offset" json:"offset"`
+	Metadata   UploadMetadata `gorm:"column:metadata" json:"metadata"`
+	Chunks     UploadChunks   `gorm:"column:chunks" json:"-"`
+	Status     string         `gorm:"column:status" json:"status"`
+	EventID    *string        `gorm:"column:event_id" json:"event_id,omitempty"`
+	Result     *UploadResult  `gorm:"column:result" json:"result,omitempty"`
+	CreatedBy  string         `gorm:"column:created_by" json:"created_by"`
+	CreateDate time.Time      `gorm:"column:create_date" json:"create_date"`
+	UpdateDate time.Time      `gorm:"column:update_date" json:"update_date"`
+}
+
+func (m *Upload) TableName() string {
+	return "uploads"
+}
+
+// UploadMetadata are the Upload-Metadata pairs of an upload, stored as a
+// JSON object.
+type UploadMetadata map[string]string
+
+// Get returns the value of key, empty when it is missing.
+func (m UploadMetadata) Get(key string) string {
+	return m[key]
+}
+
+func (UploadMetadata) GormDataType() string {
+	return "json"
+}
+
+func (m UploadMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		m = UploadMetadata{}
+	}
+	return jsonValue(map[string]string(m))
+}
+
+func (m *UploadMetadata) Scan(src any) error {
+	return jsonScan(src, m)
+}
+
+// UploadChunks are stored as a JSON array.
+type UploadChunks []string
+
+func (UploadChunks) GormDataType() string {
+	return "json"
+}
+
+func (c UploadChunks) Value() (driver.Value, error) {
+	if c == nil {
+		c = UploadChunks{}
+	}
+	return jsonValue([]string(c))
+}
+
+func (c *UploadChunks) Scan(src any) error {
+	return jsonScan(src, c)
+}
+
+// UploadResult is the response to the import of a complete upload.
+type UploadResult BaseResponse
+
+func (UploadResult) GormDataType() string {
+	return "json"
+}
+
+func (r UploadResult) Value() (driver.Value, error) {
+	return jsonValue(BaseResponse(r))
+}
+
+func (r *UploadResult) Scan(src any) error {
+	return jsonScan(src, (*BaseResponse)(r))
+}
+
+func jsonValue(v any) (driver.Value, error) {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+func jsonScan(src any, v any) error {
+	var data []byte
+	switch s := src.(type) {
+	case []byte:
+		data = s
+	case string:
+		data = []byte(s)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, v)
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -43,7 +43,9 @@ func TestPerformance_ConcurrentEventCreation(t *testing.T) {
 	for i := 0; i < totalEvents; i++ {
 		mock.ExpectBegin()
 		mock.ExpectExec(`INSERT INTO "events"`).
-			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+		mock.ExpectExec(`INSERT INTO "audit_log"`).
 			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
 		mock.ExpectCommit()
 	}
@@ -423,7 +425,9 @@ func BenchmarkRepository_CreateEvent(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		mock.ExpectBegin()
 		mock.ExpectExec(`INSERT INTO "events"`).
-			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+		mock.ExpectExec(`INSERT INTO "audit_log"`).
 			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
 		mock.ExpectCommit()
 	}
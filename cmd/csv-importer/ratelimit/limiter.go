@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// Limiter is a set of token buckets, one per key, that all share the same
+// refill rate and capacity.
+type Limiter struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// NewLimiter allows perMinute requests per key on average with bursts of up
+// to burst requests.
+func NewLimiter(perMinute int, burst int) *Limiter {
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   burst,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (l *Limiter) Allow(key string) Decision {
+	return l.take(key, 1)
+}
+
+// Peek reports whether key has a token left without taking it, so that a
+// caller can charge only the requests that turn out to count.
+func (l *Limiter) Peek(key string) Decision {
+	return l.take(key, 0)
+}
+
+func (l *Limiter) take(key string, cost float64) Decision {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		// Peeking at a full bucket does not keep one around
+		if cost == 0 {
+			return Decision{Allowed: true, Limit: l.burst, Remaining: l.burst}
+		}
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	d := Decision{
+		Limit: l.burst,
+	}
+
+	if b.tokens >= 1 {
+		b.tokens -= cost
+		d.Allowed = true
+	} else {
+		d.RetryAfter = l.durationFor(1 - b.tokens)
+	}
+
+	d.Remaining = int(math.Floor(b.tokens))
+	d.Reset = l.durationFor(float64(l.burst) - b.tokens)
+
+	return d
+}
+
+func (l *Limiter) durationFor(tokens float64) time.Duration {
+	if l.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely; they are
+// indistinguishable from new ones, so this bounds memory without changing
+// behaviour.
+func (l *Limiter) sweep(now time.Time) {
+
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(perMinute int, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(perMinute, burst)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestLimiter_BurstThenDeny(t *testing.T) {
+	l, _ := newTestLimiter(60, 3)
+
+	for i := 0; i < 3; i++ {
+		d := l.Allow("client")
+		assert.True(t, d.Allowed, "request %d should be allowed", i+1)
+		assert.Equal(t, 3, d.Limit)
+		assert.Equal(t, 2-i, d.Remaining)
+	}
+
+	d := l.Allow("client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	assert.Equal(t, time.Second, d.RetryAfter)
+	assert.Equal(t, 3*time.Second, d.Reset)
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	l, clock := newTestLimiter(60, 2)
+
+	assert.True(t, l.Allow("client").Allowed)
+	assert.True(t, l.Allow("client").Allowed)
+	assert.False(t, l.Allow("client").Allowed)
+
+	clock.advance(500 * time.Millisecond)
+	d := l.Allow("client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+	clock.advance(500 * time.Millisecond)
+	assert.True(t, l.Allow("client").Allowed)
+
+	// Idle time never accumulates more than the burst
+	clock.advance(time.Hour)
+	assert.Equal(t, 1, l.Allow("client").Remaining)
+}
+
+func TestLimiter_KeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(1, 1)
+
+	assert.True(t, l.Allow("client-a").Allowed)
+	assert.False(t, l.Allow("client-a").Allowed)
+	assert.True(t, l.Allow("client-b").Allowed)
+}
+
+func TestLimiter_SweepsRefilledBuckets(t *testing.T) {
+	l, clock := newTestLimiter(60, 1)
+
+	l.Allow("client-a")
+	l.Allow("client-b")
+	assert.Len(t, l.buckets, 2)
+
+	clock.advance(2 * sweepInterval)
+	l.Allow("client-c")
+
+	assert.Len(t, l.buckets, 1)
+	assert.Contains(t, l.buckets, "client-c")
+}
+
+func TestLimiter_Peek(t *testing.T) {
+	l, _ := newTestLimiter(60, 2)
+
+	d := l.Peek("client")
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining)
+	assert.Empty(t, l.buckets, "Peeking keeps no bucket")
+
+	l.Allow("client")
+	assert.Equal(t, 1, l.Peek("client").Remaining)
+	assert.Equal(t, 1, l.Peek("client").Remaining, "Peeking takes no token")
+
+	l.Allow("client")
+	d = l.Peek("client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Second, d.RetryAfter)
+}
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	HeaderLimit     = "X-RateLimit-Limit"
+	HeaderRemaining = "X-RateLimit-Remaining"
+	HeaderReset     = "X-RateLimit-Reset"
+)
+
+// Middleware consumes one token of limiter per request. Requests selected by
+// skipper bypass this limiter, which lets several budgets share one group.
+func Middleware(limiter *Limiter, skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			d := limiter.Allow(ClientKey(c))
+
+			h := c.Response().Header()
+			h.Set(HeaderLimit, strconv.Itoa(d.Limit))
+			h.Set(HeaderRemaining, strconv.Itoa(d.Remaining))
+			h.Set(HeaderReset, seconds(d.Reset))
+
+			if !d.Allowed {
+				h.Set(echo.HeaderRetryAfter, seconds(d.RetryAfter))
+				return apperr.RateLimited("rate limit exceeded").
+					WithDetails(map[string]int{"retry_after_seconds": retryAfter(d.RetryAfter)})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// FailedAuthLimit charges the client address one token of limiter for every
+// request the rest of the chain rejects as unauthenticated, and turns away
+// every request from an address that has none left, before its key is
+// checked. Keys therefore cannot be guessed faster than limiter allows,
+// while clients that authenticate spend nothing.
+func FailedAuthLimit(limiter *Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			key := "ip:" + c.RealIP()
+
+			d := limiter.Peek(key)
+			if !d.Allowed {
+				c.Response().Header().Set(echo.HeaderRetryAfter, seconds(d.RetryAfter))
+				return apperr.RateLimited("too many failed authentication attempts").
+					WithDetails(map[string]int{"retry_after_seconds": retryAfter(d.RetryAfter)})
+			}
+
+			err := next(c)
+			if err != nil && apperr.From(err).Code == apperr.CodeUnauthorized {
+				limiter.Allow(key)
+			}
+
+			return err
+		}
+	}
+}
+
+// Slots caps how many imports run at once. Requests that find every slot
+// taken are turned away rather than queued.
+type Slots struct {
+	ch chan struct{}
+}
+
+func NewSlots(max int) *Slots {
+	return &Slots{ch: make(chan struct{}, max)}
+}
+
+// TryAcquire takes a free slot, to be given back with Release, and reports
+// false when there is none.
+func (s *Slots) TryAcquire() bool {
+
+	select {
+	case s.ch <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Slots) Release() {
+	<-s.ch
+}
+
+// Busy is the response to a request that found every slot taken.
+func Busy(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, "1")
+	return apperr.RateLimited("too many concurrent imports")
+}
+
+// ConcurrencyLimit holds one of slots for every request not selected by
+// skipper, and rejects them while none is free.
+func ConcurrencyLimit(slots *Slots, skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			if !slots.TryAcquire() {
+				return Busy(c)
+			}
+			defer slots.Release()
+
+			return next(c)
+		}
+	}
+}
+
+// ClientKey identifies the caller by the principal that authenticated the
+// request, and by client IP when there is none. Headers the client sends
+// itself never pick the budget.
+func ClientKey(c echo.Context) string {
+
+	if p, ok := auth.FromContext(c.Request().Context()); ok {
+		return "principal:" + p.TenantID + "/" + p.Subject
+	}
+
+	return "ip:" + c.RealIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(retryAfter(d))
+}
+
+func retryAfter(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_Headers(t *testing.T) {
+	l, _ := newTestLimiter(60, 2)
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, Middleware(l, nil))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderLimit))
+	assert.Equal(t, "1", rec.Header().Get(HeaderRemaining))
+	assert.Equal(t, "1", rec.Header().Get(HeaderReset))
+	assert.Empty(t, rec.Header().Get(echo.HeaderRetryAfter))
+
+	send()
+
+	rec = send()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(HeaderRemaining))
+	assert.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+}
+
+func TestMiddleware_Skipper(t *testing.T) {
+	l, _ := newTestLimiter(1, 1)
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, Middleware(l, func(c echo.Context) bool { return true }))
+
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(HeaderLimit))
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	e := echo.New()
+
+	byPrincipal := httptest.NewRequest(http.MethodGet, "/", nil)
+	byPrincipal = byPrincipal.WithContext(auth.NewContext(byPrincipal.Context(), auth.Principal{Subject: "alice", TenantID: "tenant-a"}))
+
+	byIP := httptest.NewRequest(http.MethodGet, "/", nil)
+	byIP.RemoteAddr = "10.0.0.1:1234"
+	byIP.Header.Set(auth.APIKeyHeader, "unknown")
+
+	assert.Equal(t, "principal:tenant-a/alice", ClientKey(e.NewContext(byPrincipal, httptest.NewRecorder())))
+	assert.Equal(t, "ip:10.0.0.1", ClientKey(e.NewContext(byIP, httptest.NewRecorder())), "Keys nobody checked pick no budget")
+}
+
+func TestFailedAuthLimit(t *testing.T) {
+	l, clock := newTestLimiter(60, 2)
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.GET("/", func(c echo.Context) error {
+		if c.Request().Header.Get(auth.APIKeyHeader) != "secret" {
+			return apperr.Unauthorized("missing or invalid api key")
+		}
+		return c.NoContent(http.StatusOK)
+	}, FailedAuthLimit(l))
+
+	send := func(apiKey string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, send("secret", "10.0.0.1:1234").Code, "Authenticated requests spend nothing")
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("guess-1", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusUnauthorized, send("guess-2", "10.0.0.1:1234").Code)
+
+	rec := send("secret", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "Keys are not checked once the budget is spent")
+	assert.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+
+	assert.Equal(t, http.StatusOK, send("secret", "10.0.0.2:1234").Code, "Other addresses have budgets of their own")
+
+	clock.advance(time.Second)
+	assert.Equal(t, http.StatusOK, send("secret", "10.0.0.1:1234").Code)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.POST("/", func(c echo.Context) error {
+		entered <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusOK)
+	}, ConcurrencyLimit(NewSlots(1), nil))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+
+	close(release)
+	wg.Wait()
+
+	// The slot is released once the first import finishes
+	go func() { <-entered }()
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/requestid"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	auditSystemActor = "system"
+
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+)
+
+type AuditRepo struct {
+	db *gorm.DB
+}
+
+func NewAuditRepo(db *gorm.DB) *AuditRepo {
+	return &AuditRepo{
+		db: db,
+	}
+}
+
+func (r *AuditRepo) ListAudit(ctx context.Context, filter model.AuditFilter) ([]model.AuditLog, error) {
+
+	var logs []model.AuditLog
+
+	query := r.db.
+		WithContext(ctx).
+		Model(&model.AuditLog{}).
+		Scopes(tenantScope(ctx))
+
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("create_date >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("create_date < ?", filter.To)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	}
+	if limit > MaxAuditLimit {
+		limit = MaxAuditLimit
+	}
+
+	result := query.
+		Order("create_date DESC, id DESC").
+		Limit(limit).
+		Offset(filter.Offset).
+		Find(&logs)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return logs, nil
+}
+
+// recordAudit writes an audit entry through tx so that it commits or rolls
+// back together with the change it describes.
+func recordAudit(
+	ctx context.Context,
+	tx *gorm.DB,
+	action model.AuditAction,
+	targetType string,
+	targetID string,
+	before any,
+	after any,
+) error {
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	actor := auditSystemActor
+	if p, ok := auth.FromContext(ctx); ok {
+		actor = p.Subject
+	}
+
+	beforeJSON, err := toAuditJSON(before)
+	if err != nil {
+		return err
+	}
+
+	afterJSON, err := toAuditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	diff, err := auditDiff(beforeJSON, afterJSON)
+	if err != nil {
+		return err
+	}
+
+	entry := model.AuditLog{
+		ID:         id.String(),
+		TenantID:   auth.TenantID(ctx),
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  requestid.FromContext(ctx),
+		Before:     beforeJSON,
+		After:      afterJSON,
+		Diff:       diff,
+		CreateDate: time.Now(),
+	}
+
+	return tx.
+		Model(&model.AuditLog{}).
+		Create(&entry).
+		Error
+}
+
+func toAuditJSON(v any) (model.JSON, error) {
+
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.JSON(data), nil
+}
+
+type auditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// auditDiff returns the top level fields whose values differ between two JSON
+// objects, keyed by field name.
+func auditDiff(before model.JSON, after model.JSON) (model.JSON, error) {
+
+	beforeFields := map[string]any{}
+	afterFields := map[string]any{}
+
+	if len(before) > 0 {
+		err := json.Unmarshal(before, &beforeFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(after) > 0 {
+		err := json.Unmarshal(after, &afterFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes := map[string]auditChange{}
+	for key, b := range beforeFields {
+		a, ok := afterFields[key]
+		if !ok || !reflect.DeepEqual(a, b) {
+			changes[key] = auditChange{Before: b, After: a}
+		}
+	}
+	for key, a := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			changes[key] = auditChange{After: a}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	return toAuditJSON(changes)
+}
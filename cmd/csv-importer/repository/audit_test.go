@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/requestid"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRepo_ListAudit_Filters(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewAuditRepo(gormDB)
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT \* FROM "audit_log" WHERE actor = \$1 AND action = \$2 AND target_id = \$3 AND create_date >= \$4 AND create_date < \$5 AND tenant_id = \$6 ORDER BY create_date DESC, id DESC LIMIT \$7 OFFSET \$8`).
+		WithArgs("alice", model.AuditEventDelete, "event-1", from, to, "tenant-a", MaxAuditLimit, 10).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "tenant_id", "actor", "action", "target_type", "target_id", "before"}).
+				AddRow("audit-1", "tenant-a", "alice", "event.delete", "event", "event-1", []byte(`{"id":"event-1"}`)),
+		)
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a"})
+	logs, err := repo.ListAudit(ctx, model.AuditFilter{
+		Actor:    "alice",
+		Action:   model.AuditEventDelete,
+		TargetID: "event-1",
+		From:     from,
+		To:       to,
+		Limit:    5000,
+		Offset:   10,
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+	assert.JSONEq(t, `{"id":"event-1"}`, string(logs[0].Before))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditRepo_ListAudit_DefaultLimit(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewAuditRepo(gormDB)
+
+	mock.ExpectQuery(`SELECT \* FROM "audit_log" WHERE tenant_id = \$1 ORDER BY create_date DESC, id DESC LIMIT \$2`).
+		WithArgs(auth.DefaultTenant, DefaultAuditLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	logs, err := repo.ListAudit(context.Background(), model.AuditFilter{})
+
+	assert.NoError(t, err)
+	assert.Empty(t, logs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordAudit_CapturesActorAndRequestID(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a"})
+	ctx = requestid.NewContext(ctx, "req-123")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "audit_log"`).
+		WithArgs(
+			sqlmock.AnyArg(), "tenant-a", "alice", model.AuditImportRun, "event", "event-1", "req-123",
+			nil, `{"todos_imported":3}`, sqlmock.AnyArg(), sqlmock.AnyArg(),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx := gormDB.Begin()
+	err := recordAudit(ctx, tx, model.AuditImportRun, "event", "event-1", nil, map[string]any{"todos_imported": 3})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit().Error)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := model.JSON(`{"id":"event-1","status":"draft","name":"Event"}`)
+	after := model.JSON(`{"id":"event-1","status":"start","name":"Event","delete_date":"2025-01-01T00:00:00Z"}`)
+
+	diff, err := auditDiff(before, after)
+	require.NoError(t, err)
+
+	var changes map[string]map[string]any
+	require.NoError(t, json.Unmarshal(diff, &changes))
+
+	assert.Len(t, changes, 2)
+	assert.Equal(t, "draft", changes["status"]["before"])
+	assert.Equal(t, "start", changes["status"]["after"])
+	assert.Nil(t, changes["delete_date"]["before"])
+	assert.Equal(t, "2025-01-01T00:00:00Z", changes["delete_date"]["after"])
+}
+
+func TestAuditDiff_NoChanges(t *testing.T) {
+	diff, err := auditDiff(model.JSON(`{"a":1}`), model.JSON(`{"a":1}`))
+	assert.NoError(t, err)
+	assert.Nil(t, diff)
+}
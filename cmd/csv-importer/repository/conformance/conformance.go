@@ -0,0 +1,696 @@
+// Package conformance is the behaviour every storage backend of the
+// repositories must show. Backend tests call Run with a factory for empty,
+// fully migrated databases.
+package conformance
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/requestid"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// OpenFunc returns an empty database with the current schema.
+type OpenFunc func(t *testing.T) *gorm.DB
+
+func Run(t *testing.T, open OpenFunc) {
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, db *gorm.DB)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"UnknownEvent", testUnknownEvent},
+		{"TenantIsolation", testTenantIsolation},
+		{"UpdateEventStatus", testUpdateEventStatus},
+		{"DeleteEvent", testDeleteEvent},
+		{"TodoFilters", testTodoFilters},
+		{"Records", testRecords},
+		{"Scan", testScan},
+		{"RejectedRows", testRejectedRows},
+		{"ImportReports", testImportReports},
+		{"ImportSources", testImportSources},
+		{"Uploads", testUploads},
+		{"CountEventsByStatus", testCountEventsByStatus},
+		{"AuditTrail", testAuditTrail},
+		{"AuditFilters", testAuditFilters},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, open(t))
+		})
+	}
+}
+
+func tenantCtx(tenant string, subject string) context.Context {
+	return auth.NewContext(context.Background(), auth.Principal{
+		Subject:  subject,
+		TenantID: tenant,
+		Role:     auth.RoleAdmin,
+	})
+}
+
+func newEvent(id string, name string, created time.Time) model.Event {
+	return model.Event{
+		ID:         id,
+		Name:       name,
+		Status:     model.Created,
+		CreateDate: created,
+		UpdateDate: created,
+	}
+}
+
+func newTodo(id string, name string, created time.Time) model.TodoEvent {
+	return model.TodoEvent{
+		ID:         id,
+		Name:       name,
+		Note:       "note for " + name,
+		CreateDate: created,
+		UpdateDate: created,
+	}
+}
+
+func testCreateAndGet(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	err := repo.CreateEvent(ctx, newEvent("event-1", "Offsite", now),
+		newTodo("todo-2", "Book venue", now.Add(time.Second)),
+		newTodo("todo-1", "Send invites", now),
+	)
+	require.NoError(t, err)
+
+	event, err := repo.GetEvent(ctx, "event-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Offsite", event.Name)
+	assert.Equal(t, "tenant-a", event.TenantID)
+	assert.Equal(t, model.Created, event.Status)
+	assert.WithinDuration(t, now, event.CreateDate, time.Millisecond)
+	assert.Nil(t, event.DeleteDate)
+
+	events, err := repo.ListEvents(ctx)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "event-1", events[0].ID)
+
+	todos, err := repo.ListTodos(ctx, "event-1", model.TodoFilter{})
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	assert.Equal(t, "todo-1", todos[0].ID, "Todos are ordered by creation")
+	assert.Equal(t, "todo-2", todos[1].ID)
+	assert.Equal(t, "event-1", todos[0].EventID)
+	assert.Equal(t, "tenant-a", todos[0].TenantID)
+	assert.Equal(t, "note for Send invites", todos[0].Note)
+}
+
+func testUnknownEvent(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+
+	_, err := repo.GetEvent(ctx, "missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = repo.UpdateEventStatus(ctx, "missing", model.Start)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = repo.DeleteEvent(ctx, "missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	events, err := repo.ListEvents(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func testTenantIsolation(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	tenantA := tenantCtx("tenant-a", "alice")
+	tenantB := tenantCtx("tenant-b", "bob")
+	now := time.Now().UTC()
+
+	require.NoError(t, repo.CreateEvent(tenantA, newEvent("event-a", "A", now), newTodo("todo-a", "A1", now)))
+	require.NoError(t, repo.CreateEvent(tenantB, newEvent("event-b", "B", now)))
+
+	events, err := repo.ListEvents(tenantB)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "event-b", events[0].ID)
+
+	_, err = repo.GetEvent(tenantB, "event-a")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	todos, err := repo.ListTodos(tenantB, "event-a", model.TodoFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+
+	assert.ErrorIs(t, repo.UpdateEventStatus(tenantB, "event-a", model.End), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.DeleteEvent(tenantB, "event-a"), gorm.ErrRecordNotFound)
+
+	event, err := repo.GetEvent(tenantA, "event-a")
+	require.NoError(t, err)
+	assert.Equal(t, model.Created, event.Status, "Foreign tenants must not change the event")
+}
+
+func testUpdateEventStatus(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	created := time.Now().UTC().Add(-time.Hour)
+
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-1", "Offsite", created)))
+
+	err := repo.UpdateEventStatus(ctx, "event-1", model.Start)
+	require.NoError(t, err)
+
+	event, err := repo.GetEvent(ctx, "event-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.Start, event.Status)
+	assert.True(t, event.UpdateDate.After(created))
+}
+
+func testDeleteEvent(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC()
+
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-1", "Offsite", now), newTodo("todo-1", "Book venue", now)))
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-2", "Launch", now)))
+
+	require.NoError(t, repo.DeleteEvent(ctx, "event-1"))
+
+	_, err := repo.GetEvent(ctx, "event-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	events, err := repo.ListEvents(ctx)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "event-2", events[0].ID)
+
+	todos, err := repo.ListTodos(ctx, "event-1", model.TodoFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, todos, "Todos are deleted with their event")
+
+	assert.ErrorIs(t, repo.DeleteEvent(ctx, "event-1"), gorm.ErrRecordNotFound, "Deleting twice reports not found")
+}
+
+func testTodoFilters(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC()
+	march := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2025, time.April, 1, 9, 30, 0, 0, time.UTC)
+
+	venue := newTodo("todo-1", "Book venue", now)
+	venue.Status = model.TodoDone
+	venue.Priority = model.PriorityHigh
+	venue.DueDate = &march
+	venue.AssigneeEmail = "alice@example.com"
+	venue.Tags = model.Tags{"venue", "budget"}
+	venue.CustomFields = model.CustomFields{"region": "north", "cost center": "ops \"east\""}
+
+	invites := newTodo("todo-2", "Send invites", now.Add(time.Second))
+	invites.DueDate = &april
+	invites.Tags = model.Tags{"venues_100%"}
+	invites.CustomFields = model.CustomFields{"region": "south"}
+
+	food := newTodo("todo-3", "Order food", now.Add(2*time.Second))
+
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-1", "Offsite", now), venue, invites, food))
+
+	todos, err := repo.ListTodos(ctx, "event-1", model.TodoFilter{})
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+	assert.Equal(t, model.TodoDone, todos[0].Status)
+	assert.Equal(t, model.PriorityHigh, todos[0].Priority)
+	assert.True(t, march.Equal(*todos[0].DueDate))
+	assert.Equal(t, "alice@example.com", todos[0].AssigneeEmail)
+	assert.Equal(t, model.Tags{"venue", "budget"}, todos[0].Tags)
+	assert.Equal(t, model.TodoOpen, todos[2].Status, "Todos without a status are open")
+	assert.Nil(t, todos[2].DueDate)
+	assert.Nil(t, todos[2].Tags)
+	assert.Equal(t, venue.CustomFields, todos[0].CustomFields)
+	assert.Nil(t, todos[2].CustomFields)
+
+	ids := func(filter model.TodoFilter) []string {
+		todos, err := repo.ListTodos(ctx, "event-1", filter)
+		require.NoError(t, err)
+
+		ids := []string{}
+		for _, todo := range todos {
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"todo-2", "todo-3"}, ids(model.TodoFilter{Status: model.TodoOpen}))
+	assert.Equal(t, []string{"todo-1"}, ids(model.TodoFilter{Priority: model.PriorityHigh}))
+	assert.Equal(t, []string{"todo-1"}, ids(model.TodoFilter{AssigneeEmail: "Alice@example.com"}))
+	assert.Equal(t, []string{"todo-1"}, ids(model.TodoFilter{Tag: "venue"}), "Tags match whole")
+	assert.Equal(t, []string{"todo-1"}, ids(model.TodoFilter{Tag: "BUDGET"}))
+	assert.Equal(t, []string{"todo-2"}, ids(model.TodoFilter{Tag: "venues_100%"}))
+	assert.Empty(t, ids(model.TodoFilter{Tag: "venues%"}), "Wildcards are literal")
+	assert.Equal(t, []string{"todo-1"}, ids(model.TodoFilter{DueBefore: &april}))
+	assert.Equal(t, []string{"todo-2"}, ids(model.TodoFilter{DueAfter: &april}))
+	assert.Equal(t, []string{"todo-2"}, ids(model.TodoFilter{Status: model.TodoOpen, DueAfter: &march}))
+	assert.Equal(t, []string{"todo-2"}, ids(model.TodoFilter{CustomFields: map[string]string{"region": "south"}}))
+	assert.Equal(t, []string{"todo-1"}, ids(model.TodoFilter{CustomFields: map[string]string{"region": "north", "cost center": `ops "east"`}}))
+	assert.Empty(t, ids(model.TodoFilter{CustomFields: map[string]string{"region": "north", "cost center": "ops"}}))
+	assert.Empty(t, ids(model.TodoFilter{CustomFields: map[string]string{"Region": "north"}}), "Names are case sensitive")
+}
+
+func testRecords(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+	require.NoError(t, schemas.Ensure(ctx, db), "Every built-in import type has its table")
+
+	attendees, ok := schemas.Get("attendees")
+	require.True(t, ok)
+
+	event := newEvent("event-1", "Summit", now)
+	event.ImportType = attendees.Name
+
+	err = repo.CreateEventRecords(ctx, event, attendees.Table, []map[string]any{
+		{"id": "row-2", "create_date": now.Add(time.Second), "name": "Grace", "email": "grace@example.com", "company": nil, "ticket_type": nil, "checked_in": false, "registered_on": nil},
+		{"id": "row-1", "create_date": now, "name": "Ada", "email": "ada@example.com", "company": "Engines", "ticket_type": "vip", "checked_in": true, "registered_on": time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	})
+	require.NoError(t, err)
+
+	stored, err := repo.GetEvent(ctx, "event-1")
+	require.NoError(t, err)
+	assert.Equal(t, "attendees", stored.ImportType)
+
+	records, err := repo.ListRecords(ctx, "event-1", attendees.Table)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	format := func(record map[string]any) map[string]string {
+		formatted := map[string]string{}
+		for _, c := range attendees.Columns {
+			formatted[c.Name] = c.Format(record[c.Name])
+		}
+		return formatted
+	}
+
+	assert.Equal(t, "row-1", records[0]["id"], "Records are ordered by creation")
+	assert.Equal(t, "tenant-a", records[0]["tenant_id"])
+	assert.Equal(t, "event-1", records[0]["event_id"])
+	assert.Equal(t, map[string]string{
+		"name": "Ada", "email": "ada@example.com", "company": "Engines", "ticket_type": "vip", "checked_in": "true", "registered_on": "2025-03-01",
+	}, format(records[0]))
+	assert.Equal(t, map[string]string{
+		"name": "Grace", "email": "grace@example.com", "company": "", "ticket_type": "", "checked_in": "false", "registered_on": "",
+	}, format(records[1]))
+
+	others, err := repo.ListRecords(tenantCtx("tenant-b", "bob"), "event-1", attendees.Table)
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-2", "Offsite", now)))
+	todos, err := repo.GetEvent(ctx, "event-2")
+	require.NoError(t, err)
+	assert.Equal(t, model.ImportTypeTodos, todos.ImportType, "Events default to todos")
+}
+
+func testScan(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	tenantA := tenantCtx("tenant-a", "alice")
+	tenantB := tenantCtx("tenant-b", "bob")
+	now := time.Now().UTC()
+
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+	require.NoError(t, schemas.Ensure(tenantA, db))
+
+	attendees, ok := schemas.Get("attendees")
+	require.True(t, ok)
+
+	attendee := func(id string, name string) map[string]any {
+		return map[string]any{"id": id, "create_date": now, "name": name, "email": name + "@example.com", "checked_in": false}
+	}
+	records := func(ctx context.Context, eventID string, rows ...map[string]any) {
+		event := newEvent(eventID, eventID, now)
+		event.ImportType = attendees.Name
+		require.NoError(t, repo.CreateEventRecords(ctx, event, attendees.Table, rows))
+	}
+
+	require.NoError(t, repo.CreateEvent(tenantA, newEvent("event-1", "Offsite", now), newTodo("todo-1", "Book venue", now), newTodo("todo-2", "Send invites", now)))
+	require.NoError(t, repo.CreateEvent(tenantA, newEvent("event-2", "Launch", now), newTodo("todo-3", "Rent hall", now)))
+	require.NoError(t, repo.CreateEvent(tenantB, newEvent("event-3", "Other", now), newTodo("todo-4", "Hidden", now)))
+	records(tenantA, "event-4", attendee("row-1", "ada"), attendee("row-2", "grace"))
+	records(tenantA, "event-5", attendee("row-3", "linus"))
+	records(tenantB, "event-6", attendee("row-4", "ken"))
+	require.NoError(t, repo.DeleteEvent(tenantA, "event-2"))
+	require.NoError(t, repo.DeleteEvent(tenantA, "event-5"))
+
+	var todos []string
+	err = repo.ScanTodos(tenantA, func(batch []model.TodoEvent) error {
+		for _, todo := range batch {
+			todos = append(todos, todo.ID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"todo-1", "todo-2"}, todos, "Only live todos of the tenant are scanned")
+
+	var rows []string
+	err = repo.ScanRecords(tenantA, attendees.Table, func(batch []map[string]any) error {
+		for _, record := range batch {
+			rows = append(rows, record["id"].(string))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"row-1", "row-2"}, rows, "Only rows of live events of the tenant are scanned")
+
+	stop := errors.New("stop")
+	err = repo.ScanTodos(tenantA, func([]model.TodoEvent) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+func testRejectedRows(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	rejected := func(id string, row int, line string, reasons ...model.RejectReason) model.RejectedRow {
+		return model.RejectedRow{ID: id, Row: row, Header: "todo_name,note,priority", Line: line, Reasons: reasons, CreateDate: now}
+	}
+
+	event := newEvent("event-1", "Offsite", now)
+	event.Rejected = []model.RejectedRow{
+		rejected("reject-2", 4, "Order food,Lunch", model.RejectReason{Reason: "row has 2 fields, the header has 3"}),
+		rejected("reject-1", 2, "Send invites,\"Guests,\nplus ones\",urgent", model.RejectReason{Column: "priority", Reason: "priority must be one of low, medium, high"}),
+	}
+	require.NoError(t, repo.CreateEvent(ctx, event, newTodo("todo-1", "Book venue", now)))
+
+	rows, err := repo.ListRejectedRows(ctx, "event-1")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "reject-1", rows[0].ID, "Rejected rows are ordered by row")
+	assert.Equal(t, "tenant-a", rows[0].TenantID)
+	assert.Equal(t, "event-1", rows[0].EventID)
+	assert.Equal(t, 2, rows[0].Row)
+	assert.Equal(t, "todo_name,note,priority", rows[0].Header)
+	assert.Equal(t, "Send invites,\"Guests,\nplus ones\",urgent", rows[0].Line)
+	assert.Equal(t, model.RejectReasons{{Column: "priority", Reason: "priority must be one of low, medium, high"}}, rows[0].Reasons)
+	assert.Equal(t, model.RejectReasons{{Reason: "row has 2 fields, the header has 3"}}, rows[1].Reasons)
+
+	others, err := repo.ListRejectedRows(tenantCtx("tenant-b", "bob"), "event-1")
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+	attendees, ok := schemas.Get("attendees")
+	require.True(t, ok)
+
+	event = newEvent("event-2", "Summit", now)
+	event.ImportType = attendees.Name
+	event.Rejected = []model.RejectedRow{rejected("reject-3", 1, "Bob,bob", model.RejectReason{Column: "email", Reason: "email is not an email address"})}
+	require.NoError(t, repo.CreateEventRecords(ctx, event, attendees.Table, nil), "Every row may be rejected")
+
+	rows, err = repo.ListRejectedRows(ctx, "event-2")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "Bob,bob", rows[0].Line)
+}
+
+func testImportReports(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	content := "todo_name,note,priority,_errors\nBook venue,,high,\nSend invites,,urgent,\"priority must be one of low, medium, high\"\n"
+
+	event := newEvent("event-1", "Offsite", now)
+	event.Report = &model.ImportReport{Content: content, CreateDate: now}
+	require.NoError(t, repo.CreateEvent(ctx, event, newTodo("todo-1", "Book venue", now)))
+
+	report, err := repo.GetImportReport(ctx, "event-1")
+	require.NoError(t, err)
+	assert.Equal(t, "event-1", report.EventID)
+	assert.Equal(t, "tenant-a", report.TenantID)
+	assert.Equal(t, content, report.Content)
+	assert.True(t, now.Equal(report.CreateDate))
+
+	_, err = repo.GetImportReport(tenantCtx("tenant-b", "bob"), "event-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-2", "Clean", now)))
+	_, err = repo.GetImportReport(ctx, "event-2")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "Imports without rejected rows have no report")
+
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+	attendees, ok := schemas.Get("attendees")
+	require.True(t, ok)
+
+	event = newEvent("event-3", "Summit", now)
+	event.ImportType = attendees.Name
+	event.Report = &model.ImportReport{Content: "name,email,_errors\nBob,bob,email is not an email address\n", CreateDate: now}
+	require.NoError(t, repo.CreateEventRecords(ctx, event, attendees.Table, nil))
+
+	report, err = repo.GetImportReport(ctx, "event-3")
+	require.NoError(t, err)
+	assert.Equal(t, event.Report.Content, report.Content)
+}
+
+func testImportSources(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	event := newEvent("event-1", "Offsite", now)
+	event.Source = &model.ImportSource{
+		BlobKey:     "sources/one",
+		SHA256:      "9b2f5e6c4a0d1e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f",
+		Size:        5 << 30,
+		Filename:    "offsite.csv",
+		ContentType: "text/csv",
+		UploadedBy:  "alice",
+		CreateDate:  now,
+	}
+	require.NoError(t, repo.CreateEvent(ctx, event, newTodo("todo-1", "Book venue", now)))
+
+	source, err := repo.GetImportSource(ctx, "event-1")
+	require.NoError(t, err)
+	assert.Equal(t, "event-1", source.EventID)
+	assert.Equal(t, "tenant-a", source.TenantID)
+	assert.Equal(t, "sources/one", source.BlobKey)
+	assert.Equal(t, event.Source.SHA256, source.SHA256)
+	assert.Equal(t, int64(5<<30), source.Size, "Sizes beyond 32 bits are kept")
+	assert.Equal(t, "offsite.csv", source.Filename)
+	assert.Equal(t, "text/csv", source.ContentType)
+	assert.Equal(t, "alice", source.UploadedBy)
+	assert.True(t, now.Equal(source.CreateDate))
+
+	_, err = repo.GetImportSource(tenantCtx("tenant-b", "bob"), "event-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-2", "Command line", now)))
+	_, err = repo.GetImportSource(ctx, "event-2")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+	attendees, ok := schemas.Get("attendees")
+	require.True(t, ok)
+
+	event = newEvent("event-3", "Summit", now)
+	event.ImportType = attendees.Name
+	event.Source = &model.ImportSource{BlobKey: "sources/three", Filename: "summit.csv", CreateDate: now}
+	require.NoError(t, repo.CreateEventRecords(ctx, event, attendees.Table, nil))
+
+	source, err = repo.GetImportSource(ctx, "event-3")
+	require.NoError(t, err)
+	assert.Equal(t, "summit.csv", source.Filename)
+}
+
+func testUploads(t *testing.T, db *gorm.DB) {
+	repo := repository.NewUploadRepo(db)
+	events := repository.NewEventRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	require.NoError(t, repo.CreateUpload(ctx, model.Upload{
+		ID:         "upload-1",
+		Length:     5 << 30,
+		Metadata:   model.UploadMetadata{"name": "Offsite", "filename": "offsite.csv"},
+		Status:     model.UploadReceiving,
+		CreatedBy:  "alice",
+		CreateDate: now,
+		UpdateDate: now,
+	}))
+
+	upload, err := repo.GetUpload(ctx, "upload-1")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", upload.TenantID)
+	assert.Equal(t, int64(5<<30), upload.Length, "Lengths beyond 32 bits are kept")
+	assert.Equal(t, model.UploadMetadata{"name": "Offsite", "filename": "offsite.csv"}, upload.Metadata)
+	assert.Empty(t, upload.Chunks)
+	assert.Nil(t, upload.EventID)
+	assert.Nil(t, upload.Result)
+
+	_, err = repo.GetUpload(tenantCtx("tenant-b", "bob"), "upload-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	stale := *upload
+	require.NoError(t, repo.AppendChunk(ctx, upload, "uploads/upload-1/a", 3<<30))
+	assert.Equal(t, int64(3<<30), upload.Offset)
+	assert.Equal(t, model.UploadReceiving, upload.Status)
+
+	err = repo.AppendChunk(ctx, &stale, "uploads/upload-1/b", 2<<30)
+	assert.ErrorIs(t, err, model.ErrUploadMoved, "Chunks for an offset already passed are refused")
+
+	require.NoError(t, repo.AppendChunk(ctx, upload, "uploads/upload-1/c", 2<<30))
+	assert.Equal(t, model.UploadImporting, upload.Status)
+
+	upload, err = repo.GetUpload(ctx, "upload-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5<<30), upload.Offset)
+	assert.Equal(t, model.UploadChunks{"uploads/upload-1/a", "uploads/upload-1/c"}, upload.Chunks)
+	assert.Equal(t, model.UploadImporting, upload.Status)
+
+	err = repo.AppendChunk(ctx, upload, "uploads/upload-1/d", 1)
+	assert.ErrorIs(t, err, model.ErrUploadMoved, "Complete uploads take no more chunks")
+
+	require.NoError(t, events.CreateEvent(ctx, newEvent("event-1", "Offsite", now)))
+	eventID := "event-1"
+	upload.Status = model.UploadImported
+	upload.EventID = &eventID
+	upload.Result = &model.UploadResult{Message: "success", Details: map[string]any{"rows_imported": 1}}
+	require.NoError(t, repo.FinishUpload(ctx, upload))
+
+	upload, err = repo.GetUpload(ctx, "upload-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.UploadImported, upload.Status)
+	assert.Empty(t, upload.Chunks)
+	require.NotNil(t, upload.EventID)
+	assert.Equal(t, "event-1", *upload.EventID)
+	require.NotNil(t, upload.Result)
+	assert.Equal(t, "success", upload.Result.Message)
+	assert.Equal(t, map[string]any{"rows_imported": float64(1)}, upload.Result.Details)
+
+	assert.ErrorIs(t, repo.DeleteUpload(tenantCtx("tenant-b", "bob"), "upload-1"), gorm.ErrRecordNotFound)
+	require.NoError(t, repo.DeleteUpload(ctx, "upload-1"))
+	_, err = repo.GetUpload(ctx, "upload-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func testCountEventsByStatus(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	tenantA := tenantCtx("tenant-a", "alice")
+	tenantB := tenantCtx("tenant-b", "bob")
+	now := time.Now().UTC()
+
+	require.NoError(t, repo.CreateEvent(tenantA, newEvent("event-1", "One", now)))
+	require.NoError(t, repo.CreateEvent(tenantA, newEvent("event-2", "Two", now)))
+	require.NoError(t, repo.CreateEvent(tenantB, newEvent("event-3", "Three", now)))
+	require.NoError(t, repo.CreateEvent(tenantB, newEvent("event-4", "Four", now)))
+	require.NoError(t, repo.UpdateEventStatus(tenantA, "event-2", model.Start))
+	require.NoError(t, repo.DeleteEvent(tenantB, "event-4"))
+
+	counts, err := repo.CountEventsByStatus(tenantA)
+	require.NoError(t, err)
+	assert.Equal(t, map[model.EventStatus]int64{
+		model.Created: 2,
+		model.Start:   1,
+	}, counts, "Counts span every tenant and skip deleted events")
+}
+
+func testAuditTrail(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	auditRepo := repository.NewAuditRepo(db)
+	ctx := requestid.NewContext(tenantCtx("tenant-a", "alice"), "req-1")
+	now := time.Now().UTC()
+
+	require.NoError(t, repo.CreateEvent(ctx, newEvent("event-1", "Offsite", now), newTodo("todo-1", "Book venue", now)))
+	require.NoError(t, repo.UpdateEventStatus(ctx, "event-1", model.Start))
+	require.NoError(t, repo.DeleteEvent(ctx, "event-1"))
+
+	// Other tenants' trails stay separate
+	require.NoError(t, repo.CreateEvent(tenantCtx("tenant-b", "bob"), newEvent("event-b", "B", now)))
+
+	logs, err := auditRepo.ListAudit(ctx, model.AuditFilter{})
+	require.NoError(t, err)
+
+	actions := make([]model.AuditAction, 0, len(logs))
+	for _, log := range logs {
+		actions = append(actions, log.Action)
+		assert.Equal(t, "tenant-a", log.TenantID)
+		assert.Equal(t, "alice", log.Actor)
+		assert.Equal(t, "event-1", log.TargetID)
+		assert.Equal(t, "req-1", log.RequestID)
+	}
+
+	assert.ElementsMatch(t, []model.AuditAction{
+		model.AuditEventCreate,
+		model.AuditImportRun,
+		model.AuditEventStatusChange,
+		model.AuditEventDelete,
+	}, actions)
+
+	changes, err := auditRepo.ListAudit(ctx, model.AuditFilter{Action: model.AuditEventStatusChange})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	var diff map[string]struct {
+		Before any `json:"before"`
+		After  any `json:"after"`
+	}
+	require.NoError(t, json.Unmarshal(changes[0].Diff, &diff))
+	assert.Equal(t, "draft", diff["status"].Before)
+	assert.Equal(t, "start", diff["status"].After)
+}
+
+func testAuditFilters(t *testing.T, db *gorm.DB) {
+	repo := repository.NewEventRepo(db)
+	auditRepo := repository.NewAuditRepo(db)
+	ctx := tenantCtx("tenant-a", "alice")
+	now := time.Now().UTC()
+
+	start := time.Now().UTC().Add(-time.Second)
+	for _, id := range []string{"event-1", "event-2", "event-3"} {
+		require.NoError(t, repo.CreateEvent(ctx, newEvent(id, id, now)))
+	}
+
+	logs, err := auditRepo.ListAudit(ctx, model.AuditFilter{TargetID: "event-2"})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "event-2", logs[0].TargetID)
+
+	logs, err = auditRepo.ListAudit(ctx, model.AuditFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "event-3", logs[0].TargetID, "Newest entries come first")
+
+	logs, err = auditRepo.ListAudit(ctx, model.AuditFilter{Limit: 2, Offset: 2})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "event-1", logs[0].TargetID)
+
+	logs, err = auditRepo.ListAudit(ctx, model.AuditFilter{From: start, To: time.Now().UTC().Add(time.Second)})
+	require.NoError(t, err)
+	assert.Len(t, logs, 3)
+
+	logs, err = auditRepo.ListAudit(ctx, model.AuditFilter{From: time.Now().UTC().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+
+	logs, err = auditRepo.ListAudit(ctx, model.AuditFilter{Actor: "bob"})
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+}
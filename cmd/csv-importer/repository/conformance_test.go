@@ -0,0 +1,60 @@
+package repository_test
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/migration"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/repository/conformance"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := repository.Open(repository.DriverSQLite, ":memory:", &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	migrator, err := migration.New(db)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestSQLite_Conformance(t *testing.T) {
+	conformance.Run(t, openSQLite)
+}
+
+func TestSQLite_MigrationsRoundTrip(t *testing.T) {
+	db := openSQLite(t)
+
+	migrator, err := migration.New(db)
+	require.NoError(t, err)
+
+	reverted, err := migrator.Down(context.Background(), len(migrator.Migrations()))
+	require.NoError(t, err)
+	require.Len(t, reverted, len(migrator.Migrations()))
+	require.False(t, db.Migrator().HasTable("events"))
+
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	version, err := migrator.Version(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, migrator.Latest(), version)
+}
+
+func TestOpen_UnsupportedDriver(t *testing.T) {
+	_, err := repository.Open("mysql", "", nil)
+	require.Error(t, err)
+}
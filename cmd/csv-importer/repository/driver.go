@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Open connects to one of the supported storage backends. The repositories
+// use portable GORM queries apart from JSON lookups, so they work unchanged on
+// either of them.
+//
+// For sqlite, dsn is a file path or ":memory:". The pure-Go driver needs no
+// cgo, which makes it suitable for tests and single-node deployments.
+func Open(driver string, dsn string, config *gorm.Config) (*gorm.DB, error) {
+
+	if config == nil {
+		config = &gorm.Config{}
+	}
+
+	switch driver {
+	case DriverPostgres:
+		return gorm.Open(postgres.Open(dsn), config)
+
+	case DriverSQLite:
+		db, err := gorm.Open(sqlite.Open(sqliteDSN(dsn)), config)
+		if err != nil {
+			return nil, err
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+
+		// SQLite allows a single writer, and every connection to ":memory:"
+		// would open a separate empty database.
+		sqlDB.SetMaxOpenConns(1)
+
+		return db, nil
+	}
+
+	return nil, fmt.Errorf("unsupported database driver %q, expected %s or %s", driver, DriverPostgres, DriverSQLite)
+}
+
+func sqliteDSN(dsn string) string {
+
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + pragmas
+	}
+
+	return dsn + "?" + pragmas
+}
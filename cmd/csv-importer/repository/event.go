@@ -2,11 +2,21 @@ package repository
 
 import (
 	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
 	"csv-importer-backend/cmd/csv-importer/model"
+	"encoding/json"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	todoBatchSize = 500
+
+	auditTargetEvent = "event"
+)
+
 type EventRepo struct {
 	db *gorm.DB
 }
@@ -24,7 +34,7 @@ func (r *EventRepo) ListEvents(ctx context.Context) ([]model.Event, error) {
 	result := r.db.
 		WithContext(ctx).
 		Model(&model.Event{}).
-		Debug().
+		Scopes(tenantScope(ctx), notDeleted).
 		Find(&events)
 
 	if result.Error != nil {
@@ -34,18 +44,299 @@ func (r *EventRepo) ListEvents(ctx context.Context) ([]model.Event, error) {
 	return events, nil
 }
 
-func (r *EventRepo) CreateEvent(ctx context.Context, event model.Event) error {
+func (r *EventRepo) GetEvent(ctx context.Context, id string) (*model.Event, error) {
+
+	var event model.Event
 
 	result := r.db.
 		WithContext(ctx).
-		Model(&event).
-		Debug().
-		Create(event)
+		Model(&model.Event{}).
+		Scopes(tenantScope(ctx), notDeleted).
+		Where("id = ?", id).
+		Take(&event)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &event, nil
+}
+
+func (r *EventRepo) CreateEvent(ctx context.Context, event model.Event, todos ...model.TodoEvent) error {
+
+	tenantID := auth.TenantID(ctx)
+	event.TenantID = tenantID
+	if event.ImportType == "" {
+		event.ImportType = model.ImportTypeTodos
+	}
+
+	for i := range todos {
+		todos[i].TenantID = tenantID
+		todos[i].EventID = event.ID
+		if todos[i].Status == "" {
+			todos[i].Status = model.TodoOpen
+		}
+	}
+
+	return r.db.
+		WithContext(ctx).
+		Transaction(func(tx *gorm.DB) error {
+
+			result := tx.
+				Model(&event).
+				Create(event)
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			err := recordAudit(ctx, tx, model.AuditEventCreate, auditTargetEvent, event.ID, nil, event)
+			if err != nil {
+				return err
+			}
+
+			err = createRejectedRows(tx, event)
+			if err != nil {
+				return err
+			}
+
+			err = createImportReport(tx, event)
+			if err != nil {
+				return err
+			}
+
+			err = createImportSource(tx, event)
+			if err != nil {
+				return err
+			}
+
+			if len(todos) == 0 {
+				return nil
+			}
+
+			result = tx.
+				Model(&model.TodoEvent{}).
+				CreateInBatches(todos, todoBatchSize)
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			return recordAudit(ctx, tx, model.AuditImportRun, auditTargetEvent, event.ID, nil, map[string]any{
+				"todos_imported": len(todos),
+			})
+		})
+}
+
+func (r *EventRepo) ListTodos(ctx context.Context, eventID string, filter model.TodoFilter) ([]model.TodoEvent, error) {
+
+	var todos []model.TodoEvent
+
+	query := r.db.
+		WithContext(ctx).
+		Model(&model.TodoEvent{}).
+		Scopes(tenantScope(ctx), notDeleted).
+		Where("event_id = ?", eventID)
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Priority != "" {
+		query = query.Where("priority = ?", filter.Priority)
+	}
+	if filter.AssigneeEmail != "" {
+		query = query.Where("assignee_email = ?", strings.ToLower(filter.AssigneeEmail))
+	}
+	if filter.Tag != "" {
+		// Tags are stored comma-separated, so delimit both sides to match
+		// whole tags only
+		query = query.Where(`',' || tags || ',' LIKE ? ESCAPE '\'`, "%,"+escapeLike(strings.ToLower(filter.Tag))+",%")
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("due_date < ?", *filter.DueBefore)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("due_date >= ?", *filter.DueAfter)
+	}
+	for key, value := range filter.CustomFields {
+		query = query.Scopes(customFieldEquals(key, value))
+	}
+
+	result := query.
+		Order("create_date, id").
+		Find(&todos)
 
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
 	}
 
-	return nil
+	return todos, nil
+}
+
+// ScanTodos passes the live todos of every event of the tenant in ctx to fn,
+// in batches.
+func (r *EventRepo) ScanTodos(ctx context.Context, fn func(todos []model.TodoEvent) error) error {
+
+	var todos []model.TodoEvent
+
+	result := r.db.
+		WithContext(ctx).
+		Model(&model.TodoEvent{}).
+		Scopes(tenantScope(ctx), notDeleted).
+		FindInBatches(&todos, todoBatchSize, func(tx *gorm.DB, batch int) error {
+			return fn(todos)
+		})
 
+	return result.Error
+}
+
+func (r *EventRepo) UpdateEventStatus(ctx context.Context, id string, status model.EventStatus) error {
+
+	return r.db.
+		WithContext(ctx).
+		Transaction(func(tx *gorm.DB) error {
+
+			var before model.Event
+
+			result := tx.
+				Model(&model.Event{}).
+				Scopes(tenantScope(ctx), notDeleted).
+				Where("id = ?", id).
+				Take(&before)
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			after := before
+			after.Status = status
+			after.UpdateDate = time.Now()
+
+			result = tx.
+				Model(&model.Event{}).
+				Scopes(tenantScope(ctx), notDeleted).
+				Where("id = ?", id).
+				Updates(map[string]any{
+					"status":      after.Status,
+					"update_date": after.UpdateDate,
+				})
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+
+			return recordAudit(ctx, tx, model.AuditEventStatusChange, auditTargetEvent, id, before, after)
+		})
+}
+
+func (r *EventRepo) DeleteEvent(ctx context.Context, id string) error {
+
+	return r.db.
+		WithContext(ctx).
+		Transaction(func(tx *gorm.DB) error {
+
+			var before model.Event
+
+			result := tx.
+				Model(&model.Event{}).
+				Scopes(tenantScope(ctx), notDeleted).
+				Where("id = ?", id).
+				Take(&before)
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			now := time.Now()
+			after := before
+			after.DeleteDate = &now
+			after.UpdateDate = now
+
+			result = tx.
+				Model(&model.Event{}).
+				Scopes(tenantScope(ctx), notDeleted).
+				Where("id = ?", id).
+				Updates(map[string]any{
+					"delete_date": now,
+					"update_date": now,
+				})
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+
+			result = tx.
+				Model(&model.TodoEvent{}).
+				Scopes(tenantScope(ctx), notDeleted).
+				Where("event_id = ?", id).
+				Updates(map[string]any{
+					"delete_date": now,
+					"update_date": now,
+				})
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			return recordAudit(ctx, tx, model.AuditEventDelete, auditTargetEvent, id, before, after)
+		})
+}
+
+// CountEventsByStatus counts the live events of all tenants. It backs the
+// metrics endpoint and deliberately ignores the tenant in ctx.
+func (r *EventRepo) CountEventsByStatus(ctx context.Context) (map[model.EventStatus]int64, error) {
+
+	var rows []struct {
+		Status model.EventStatus
+		Count  int64
+	}
+
+	result := r.db.
+		WithContext(ctx).
+		Model(&model.Event{}).
+		Scopes(notDeleted).
+		Select("status, count(*) AS count").
+		Group("status").
+		Scan(&rows)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	counts := make(map[model.EventStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	return counts, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// customFieldEquals matches todos whose custom field key has value. JSON
+// support differs between the backends, so this is the one query written per
+// dialect; the postgres form can use the GIN index on custom_fields.
+func customFieldEquals(key string, value string) func(*gorm.DB) *gorm.DB {
+
+	return func(db *gorm.DB) *gorm.DB {
+
+		if db.Dialector.Name() == DriverPostgres {
+			contains, _ := json.Marshal(map[string]string{key: value})
+			return db.Where("custom_fields @> CAST(? AS jsonb)", string(contains))
+		}
+
+		return db.Where("EXISTS (SELECT 1 FROM json_each(custom_fields) WHERE key = ? AND value = ?)", key, value)
+	}
 }
@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
 	"csv-importer-backend/cmd/csv-importer/model"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -34,6 +37,15 @@ func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
 	return gormDB, mock
 }
 
+func expectAudit(mock sqlmock.Sqlmock, tenantID string, actor string, action model.AuditAction, targetID string) {
+	mock.ExpectExec(`INSERT INTO "audit_log"`).
+		WithArgs(
+			sqlmock.AnyArg(), tenantID, actor, action, "event", targetID,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 func TestEventRepo_ListEvents_Success(t *testing.T) {
 	gormDB, mock := setupMockDB(t)
 	defer func() {
@@ -147,8 +159,9 @@ func TestEventRepo_CreateEvent_Success(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "events"`).
-		WithArgs(event.ID, event.Name, event.Status, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WithArgs(event.ID, auth.DefaultTenant, event.Name, event.Status, model.ImportTypeTodos, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock, auth.DefaultTenant, "system", model.AuditEventCreate, event.ID)
 	mock.ExpectCommit()
 
 	ctx := context.Background()
@@ -177,7 +190,7 @@ func TestEventRepo_CreateEvent_DatabaseError(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "events"`).
-		WithArgs(event.ID, event.Name, event.Status, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WithArgs(event.ID, auth.DefaultTenant, event.Name, event.Status, model.ImportTypeTodos, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnError(errors.New("database insert failed"))
 	mock.ExpectRollback()
 
@@ -209,7 +222,7 @@ func TestEventRepo_CreateEvent_DuplicateID(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "events"`).
-		WithArgs(event.ID, event.Name, event.Status, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WithArgs(event.ID, auth.DefaultTenant, event.Name, event.Status, model.ImportTypeTodos, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnError(sql.ErrNoRows)
 	mock.ExpectRollback()
 
@@ -219,4 +232,260 @@ func TestEventRepo_CreateEvent_DuplicateID(t *testing.T) {
 	assert.Error(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
-}
\ No newline at end of file
+}
+
+func TestEventRepo_ListEvents_ScopedToTenant(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE tenant_id = \$1`).
+		WithArgs("tenant-a").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "name", "status", "create_date", "update_date", "delete_date"}))
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a"})
+	_, err := repo.ListEvents(ctx)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_GetEvent_OtherTenant(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id = \$1 AND tenant_id = \$2`).
+		WithArgs("event-of-tenant-a", "tenant-b", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "name", "status", "create_date", "update_date", "delete_date"}))
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "bob", TenantID: "tenant-b"})
+	event, err := repo.GetEvent(ctx, "event-of-tenant-a")
+
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Nil(t, event)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_CreateEvent_WithTodos(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	now := time.Now()
+	event := model.Event{
+		ID:         "event-123",
+		Name:       "New Test Event",
+		Status:     model.Created,
+		CreateDate: now,
+		UpdateDate: now,
+	}
+	todos := []model.TodoEvent{
+		{ID: "todo-1", Name: "Buy groceries", Note: "Milk", CreateDate: now, UpdateDate: now},
+		{ID: "todo-2", Name: "Call dentist", Note: "", CreateDate: now, UpdateDate: now},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WithArgs(event.ID, "tenant-a", event.Name, event.Status, model.ImportTypeTodos, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock, "tenant-a", "alice", model.AuditEventCreate, event.ID)
+	mock.ExpectExec(`INSERT INTO "todo_events"`).
+		WithArgs(
+			"todo-1", "tenant-a", event.ID, "Buy groceries", "Milk", model.TodoOpen, "", nil, "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil,
+			"todo-2", "tenant-a", event.ID, "Call dentist", "", model.TodoOpen, "", nil, "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil,
+		).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	expectAudit(mock, "tenant-a", "alice", model.AuditImportRun, event.ID)
+	mock.ExpectCommit()
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a"})
+	err := repo.CreateEvent(ctx, event, todos...)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_CreateEvent_TodoInsertFailureRollsBack(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	event := model.Event{
+		ID:         "event-123",
+		Name:       "New Test Event",
+		Status:     model.Created,
+		CreateDate: time.Now(),
+		UpdateDate: time.Now(),
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock, auth.DefaultTenant, "system", model.AuditEventCreate, event.ID)
+	mock.ExpectExec(`INSERT INTO "todo_events"`).
+		WillReturnError(errors.New("todo insert failed"))
+	mock.ExpectRollback()
+
+	err := repo.CreateEvent(context.Background(), event, model.TodoEvent{ID: "todo-1", Name: "Task"})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "todo insert failed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_ListTodos_ScopedToTenant(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	mock.ExpectQuery(`SELECT \* FROM "todo_events" WHERE event_id = \$1 AND tenant_id = \$2`).
+		WithArgs("event-1", "tenant-a").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "tenant_id", "event_id", "name", "note"}).
+				AddRow("todo-1", "tenant-a", "event-1", "Buy groceries", "Milk"),
+		)
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a"})
+	todos, err := repo.ListTodos(ctx, "event-1", model.TodoFilter{})
+
+	assert.NoError(t, err)
+	assert.Len(t, todos, 1)
+	assert.Equal(t, "Buy groceries", todos[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_UpdateEventStatus_Success(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id = \$1 AND tenant_id = \$2 AND delete_date IS NULL`).
+		WithArgs("event-1", "tenant-a", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "tenant_id", "name", "status"}).
+				AddRow("event-1", "tenant-a", "Event", "draft"),
+		)
+	mock.ExpectExec(`UPDATE "events" SET "status"=\$1,"update_date"=\$2 WHERE id = \$3 AND tenant_id = \$4 AND delete_date IS NULL`).
+		WithArgs(model.Start, sqlmock.AnyArg(), "event-1", "tenant-a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "audit_log"`).
+		WithArgs(
+			sqlmock.AnyArg(), "tenant-a", "alice", model.AuditEventStatusChange, "event", "event-1",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			jsonContains(`"status":{"before":"draft","after":"start"}`),
+			sqlmock.AnyArg(),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a"})
+	err := repo.UpdateEventStatus(ctx, "event-1", model.Start)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_UpdateEventStatus_NotFound(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	err := repo.UpdateEventStatus(context.Background(), "missing", model.End)
+
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_DeleteEvent_SoftDeletesTodos(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id = \$1 AND tenant_id = \$2 AND delete_date IS NULL`).
+		WithArgs("event-1", "tenant-a", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "tenant_id", "name", "status"}).
+				AddRow("event-1", "tenant-a", "Event", "draft"),
+		)
+	mock.ExpectExec(`UPDATE "events" SET "delete_date"=\$1,"update_date"=\$2 WHERE id = \$3 AND tenant_id = \$4 AND delete_date IS NULL`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "event-1", "tenant-a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "todo_events" SET "delete_date"=\$1,"update_date"=\$2 WHERE event_id = \$3 AND tenant_id = \$4 AND delete_date IS NULL`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "event-1", "tenant-a").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	expectAudit(mock, "tenant-a", "alice", model.AuditEventDelete, "event-1")
+	mock.ExpectCommit()
+
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", TenantID: "tenant-a"})
+	err := repo.DeleteEvent(ctx, "event-1")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepo_DeleteEvent_NotFound(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+	defer func() {
+		sqlDB, _ := gormDB.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewEventRepo(gormDB)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	err := repo.DeleteEvent(context.Background(), "missing")
+
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type jsonContains string
+
+func (j jsonContains) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.Contains(s, string(j))
+}
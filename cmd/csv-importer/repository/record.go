@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// CreateEventRecords stores an event of a declarative import type with its
+// rows, keyed by column, in table. It runs in one transaction like
+// CreateEvent. Every record needs an id and a create_date; the tenant and
+// event are set here. Records have no delete date of their own: they are
+// only read through their event.
+func (r *EventRepo) CreateEventRecords(ctx context.Context, event model.Event, table string, records []map[string]any) error {
+
+	tenantID := auth.TenantID(ctx)
+	event.TenantID = tenantID
+
+	for _, record := range records {
+		record["tenant_id"] = tenantID
+		record["event_id"] = event.ID
+	}
+
+	return r.db.
+		WithContext(ctx).
+		Transaction(func(tx *gorm.DB) error {
+
+			result := tx.
+				Model(&event).
+				Create(event)
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			err := recordAudit(ctx, tx, model.AuditEventCreate, auditTargetEvent, event.ID, nil, event)
+			if err != nil {
+				return err
+			}
+
+			err = createRejectedRows(tx, event)
+			if err != nil {
+				return err
+			}
+
+			err = createImportReport(tx, event)
+			if err != nil {
+				return err
+			}
+
+			err = createImportSource(tx, event)
+			if err != nil {
+				return err
+			}
+
+			if len(records) == 0 {
+				return nil
+			}
+
+			result = tx.
+				Table(table).
+				CreateInBatches(records, todoBatchSize)
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			return recordAudit(ctx, tx, model.AuditImportRun, auditTargetEvent, event.ID, nil, map[string]any{
+				"import_type":      event.ImportType,
+				"records_imported": len(records),
+			})
+		})
+}
+
+// ListRecords returns the rows an event stored in table, in import order.
+func (r *EventRepo) ListRecords(ctx context.Context, eventID string, table string) ([]map[string]any, error) {
+
+	records := []map[string]any{}
+
+	result := r.db.
+		WithContext(ctx).
+		Table(table).
+		Scopes(tenantScope(ctx)).
+		Where("event_id = ?", eventID).
+		Order("create_date, id").
+		Find(&records)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return records, nil
+}
+
+// ScanRecords passes the rows every live event of the tenant in ctx stored in
+// table to fn, in batches ordered by id.
+func (r *EventRepo) ScanRecords(ctx context.Context, table string, fn func(records []map[string]any) error) error {
+
+	events := r.db.
+		Model(&model.Event{}).
+		Select("id").
+		Scopes(tenantScope(ctx), notDeleted)
+
+	after := ""
+	for {
+		records := []map[string]any{}
+
+		result := r.db.
+			WithContext(ctx).
+			Table(table).
+			Scopes(tenantScope(ctx)).
+			Where("event_id IN (?)", events).
+			Where("id > ?", after).
+			Order("id").
+			Limit(todoBatchSize).
+			Find(&records)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if len(records) == 0 {
+			return nil
+		}
+
+		err := fn(records)
+		if err != nil || len(records) < todoBatchSize {
+			return err
+		}
+
+		after = fmt.Sprint(records[len(records)-1]["id"])
+	}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/model"
+
+	"gorm.io/gorm"
+)
+
+// createRejectedRows stores the rows a partial import left out through tx,
+// with the tenant and ID of their event.
+func createRejectedRows(tx *gorm.DB, event model.Event) error {
+
+	if len(event.Rejected) == 0 {
+		return nil
+	}
+
+	for i := range event.Rejected {
+		event.Rejected[i].TenantID = event.TenantID
+		event.Rejected[i].EventID = event.ID
+	}
+
+	return tx.
+		Model(&model.RejectedRow{}).
+		CreateInBatches(event.Rejected, todoBatchSize).
+		Error
+}
+
+// ListRejectedRows returns the rows the partial import of an event left out,
+// in file order.
+func (r *EventRepo) ListRejectedRows(ctx context.Context, eventID string) ([]model.RejectedRow, error) {
+
+	rows := []model.RejectedRow{}
+
+	result := r.db.
+		WithContext(ctx).
+		Model(&model.RejectedRow{}).
+		Scopes(tenantScope(ctx)).
+		Where("event_id = ?", eventID).
+		Order("row_number").
+		Find(&rows)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rows, nil
+}
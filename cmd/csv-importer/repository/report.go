@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/model"
+
+	"gorm.io/gorm"
+)
+
+// createImportReport stores the error report of a partial import through tx,
+// with the tenant and ID of its event.
+func createImportReport(tx *gorm.DB, event model.Event) error {
+
+	if event.Report == nil {
+		return nil
+	}
+
+	report := *event.Report
+	report.TenantID = event.TenantID
+	report.EventID = event.ID
+
+	return tx.Create(&report).Error
+}
+
+// GetImportReport returns the error report of the partial import of an event,
+// gorm.ErrRecordNotFound when it has none.
+func (r *EventRepo) GetImportReport(ctx context.Context, eventID string) (*model.ImportReport, error) {
+
+	var report model.ImportReport
+
+	result := r.db.
+		WithContext(ctx).
+		Model(&model.ImportReport{}).
+		Scopes(tenantScope(ctx)).
+		Where("event_id = ?", eventID).
+		Take(&report)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/model"
+
+	"gorm.io/gorm"
+)
+
+// createImportSource stores the description of the uploaded file of an
+// import through tx, with the tenant and ID of its event.
+func createImportSource(tx *gorm.DB, event model.Event) error {
+
+	if event.Source == nil {
+		return nil
+	}
+
+	source := *event.Source
+	source.TenantID = event.TenantID
+	source.EventID = event.ID
+
+	return tx.Create(&source).Error
+}
+
+// GetImportSource returns the description of the uploaded file of the import
+// of an event, gorm.ErrRecordNotFound when none was kept.
+func (r *EventRepo) GetImportSource(ctx context.Context, eventID string) (*model.ImportSource, error) {
+
+	var source model.ImportSource
+
+	result := r.db.
+		WithContext(ctx).
+		Model(&model.ImportSource{}).
+		Scopes(tenantScope(ctx)).
+		Where("event_id = ?", eventID).
+		Take(&source)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &source, nil
+}
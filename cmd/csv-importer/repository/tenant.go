@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+
+	"gorm.io/gorm"
+)
+
+// tenantScope restricts a query to the tenant of the principal in ctx. Every
+// EventRepo query goes through it so that one tenant can never observe or
+// mutate another tenant's rows.
+func tenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+
+	tenantID := auth.TenantID(ctx)
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
+
+func notDeleted(db *gorm.DB) *gorm.DB {
+	return db.Where("delete_date IS NULL")
+}
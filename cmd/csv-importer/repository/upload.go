@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UploadRepo struct {
+	db *gorm.DB
+}
+
+func NewUploadRepo(db *gorm.DB) *UploadRepo {
+	return &UploadRepo{
+		db: db,
+	}
+}
+
+func (r *UploadRepo) CreateUpload(ctx context.Context, upload model.Upload) error {
+
+	upload.TenantID = auth.TenantID(ctx)
+
+	return r.db.
+		WithContext(ctx).
+		Create(&upload).
+		Error
+}
+
+func (r *UploadRepo) GetUpload(ctx context.Context, id string) (*model.Upload, error) {
+
+	var upload model.Upload
+
+	result := r.db.
+		WithContext(ctx).
+		Model(&model.Upload{}).
+		Scopes(tenantScope(ctx)).
+		Where("id = ?", id).
+		Take(&upload)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &upload, nil
+}
+
+// AppendChunk records a chunk of size bytes, stored under key, at the offset
+// upload was read with. The upload moves on to importing once it is
+// complete. model.ErrUploadMoved reports that the offset changed in between, and
+// the chunk was not appended.
+func (r *UploadRepo) AppendChunk(ctx context.Context, upload *model.Upload, key string, size int64) error {
+
+	offset := upload.Offset + size
+	chunks := append(slices.Clip(upload.Chunks), key)
+	status := model.UploadReceiving
+	if offset == upload.Length {
+		status = model.UploadImporting
+	}
+	now := time.Now()
+
+	result := r.db.
+		WithContext(ctx).
+		Model(&model.Upload{}).
+		Scopes(tenantScope(ctx)).
+		Where("id = ? AND upload_offset = ? AND status = ?", upload.ID, upload.Offset, model.UploadReceiving).
+		Updates(map[string]any{
+			"upload_offset": offset,
+			"chunks":        chunks,
+			"status":        status,
+			"update_date":   now,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return model.ErrUploadMoved
+	}
+
+	upload.Offset = offset
+	upload.Chunks = chunks
+	upload.Status = status
+	upload.UpdateDate = now
+
+	return nil
+}
+
+// FinishUpload records the outcome of the import of a complete upload, whose
+// chunks are no longer kept.
+func (r *UploadRepo) FinishUpload(ctx context.Context, upload *model.Upload) error {
+
+	upload.Chunks = model.UploadChunks{}
+	upload.UpdateDate = time.Now()
+
+	return r.db.
+		WithContext(ctx).
+		Model(&model.Upload{}).
+		Scopes(tenantScope(ctx)).
+		Where("id = ?", upload.ID).
+		Updates(map[string]any{
+			"chunks":      upload.Chunks,
+			"status":      upload.Status,
+			"event_id":    upload.EventID,
+			"result":      upload.Result,
+			"update_date": upload.UpdateDate,
+		}).
+		Error
+}
+
+func (r *UploadRepo) DeleteUpload(ctx context.Context, id string) error {
+
+	result := r.db.
+		WithContext(ctx).
+		Scopes(tenantScope(ctx)).
+		Where("id = ?", id).
+		Delete(&model.Upload{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
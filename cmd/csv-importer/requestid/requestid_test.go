@@ -0,0 +1,51 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name       string
+		incoming   string
+		expectSame bool
+	}{
+		{"Generates an ID", "", false},
+		{"Keeps the client ID", "client-request-1", true},
+		{"Replaces oversized IDs", strings.Repeat("x", 129), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(Middleware())
+
+			var seen string
+			e.GET("/", func(c echo.Context) error {
+				seen = FromContext(c.Request().Context())
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.incoming != "" {
+				req.Header.Set(echo.HeaderXRequestID, tc.incoming)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, seen)
+			assert.Equal(t, seen, rec.Header().Get(echo.HeaderXRequestID))
+			if tc.expectSame {
+				assert.Equal(t, tc.incoming, seen)
+			} else {
+				assert.NotEqual(t, tc.incoming, seen)
+			}
+		})
+	}
+}
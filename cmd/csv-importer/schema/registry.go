@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The built-in import types. todos.yaml documents the columns of the todo
+// pipeline, which has its own Go implementation.
+//
+//go:embed builtin/*.yaml
+var builtin embed.FS
+
+// Registry holds the import types known to the server.
+type Registry struct {
+	schemas map[string]*Schema
+}
+
+// Builtin returns a registry with the import types shipped in the binary.
+func Builtin() (*Registry, error) {
+
+	r := &Registry{schemas: map[string]*Schema{}}
+
+	dir, err := fs.Sub(builtin, "builtin")
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.addDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Load returns the built-in import types plus those defined by the .json,
+// .yaml and .yml files in dir, one schema per file. An empty dir only loads
+// the built-in ones.
+func Load(dir string) (*Registry, error) {
+
+	r, err := Builtin()
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		return r, nil
+	}
+
+	err = r.addDir(os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("schema dir %s: %w", dir, err)
+	}
+
+	return r, nil
+}
+
+func (r *Registry) addDir(dir fs.FS) error {
+
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		switch path.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+
+		if entry.IsDir() {
+			continue
+		}
+
+		data, err := fs.ReadFile(dir, entry.Name())
+		if err != nil {
+			return err
+		}
+
+		s, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		if _, ok := r.schemas[s.Name]; ok {
+			return fmt.Errorf("%s: schema %s is already defined", entry.Name(), s.Name)
+		}
+
+		for _, other := range r.schemas {
+			if other.Table == s.Table {
+				return fmt.Errorf("%s: table %s is already used by schema %s", entry.Name(), s.Table, other.Name)
+			}
+		}
+
+		r.schemas[s.Name] = s
+	}
+
+	return nil
+}
+
+// Parse reads one schema definition. JSON is accepted as YAML; unknown keys
+// are rejected so that typos do not silently drop a constraint.
+func Parse(data []byte) (*Schema, error) {
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var s Schema
+	err := decoder.Decode(&s)
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("empty schema definition")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Get returns the import type called name. A nil registry has none.
+func (r *Registry) Get(name string) (*Schema, bool) {
+
+	if r == nil {
+		return nil, false
+	}
+
+	s, ok := r.schemas[name]
+	return s, ok
+}
+
+// List returns every import type, sorted by name.
+func (r *Registry) List() []*Schema {
+
+	schemas := make([]*Schema, 0, len(r.schemas))
+	for _, s := range r.schemas {
+		schemas = append(schemas, s)
+	}
+
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+
+	return schemas
+}
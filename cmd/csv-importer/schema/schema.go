@@ -0,0 +1,261 @@
+// Package schema describes import types declaratively: the columns a CSV
+// file of the type has, how every cell is validated and the table its rows
+// are stored in.
+package schema
+
+import (
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/transform"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column types.
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+	TypeEmail   = "email"
+)
+
+var types = []string{TypeString, TypeInteger, TypeNumber, TypeBoolean, TypeDate, TypeEmail}
+
+// identifier restricts schema, table and column names to what is safe to use
+// in SQL without quoting.
+var identifier = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// reservedColumns are filled in by the importer for every row.
+var reservedColumns = []string{"id", "tenant_id", "event_id", "create_date"}
+
+// reservedTables are the tables of the service itself. Only the todos
+// definition, which documents todo_events, may name one.
+var reservedTables = []string{
+	"events",
+	"todo_events",
+	"audit_log",
+	"rejected_rows",
+	"import_reports",
+	"import_sources",
+	"uploads",
+	"schema_migrations",
+}
+
+// Schema describes one import type. Transforms run on every file of the
+// type before its cells are validated, ahead of those of the upload.
+type Schema struct {
+	Name        string           `json:"name" yaml:"name"`
+	Description string           `json:"description,omitempty" yaml:"description"`
+	Table       string           `json:"table" yaml:"table"`
+	Columns     []Column         `json:"columns" yaml:"columns"`
+	Transforms  []transform.Rule `json:"transforms,omitempty" yaml:"transforms"`
+}
+
+// Column describes one CSV column. The CSV header is Name; the cells are
+// stored in the table column of the same name.
+type Column struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Type        string   `json:"type" yaml:"type"`
+	Required    bool     `json:"required,omitempty" yaml:"required"`
+	Pattern     string   `json:"pattern,omitempty" yaml:"pattern"`
+	Enum        []string `json:"enum,omitempty" yaml:"enum"`
+
+	pattern *regexp.Regexp
+}
+
+// compile checks the definition and prepares its patterns.
+func (s *Schema) compile() error {
+
+	if !identifier.MatchString(s.Name) {
+		return fmt.Errorf("schema name %q must be lower case letters, digits and underscores", s.Name)
+	}
+
+	if !identifier.MatchString(s.Table) {
+		return fmt.Errorf("schema %s: table %q must be lower case letters, digits and underscores", s.Name, s.Table)
+	}
+
+	if slices.Contains(reservedTables, s.Table) && !(s.Name == model.ImportTypeTodos && s.Table == "todo_events") {
+		return fmt.Errorf("schema %s: table %s is reserved", s.Name, s.Table)
+	}
+
+	if len(s.Columns) == 0 {
+		return fmt.Errorf("schema %s: no columns", s.Name)
+	}
+
+	seen := map[string]bool{}
+	for i := range s.Columns {
+		c := &s.Columns[i]
+
+		err := c.compile()
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", s.Name, err)
+		}
+
+		if seen[c.Name] {
+			return fmt.Errorf("schema %s: column %s is defined twice", s.Name, c.Name)
+		}
+		seen[c.Name] = true
+	}
+
+	_, err := transform.Compile(s.Transforms)
+	if err != nil {
+		return fmt.Errorf("schema %s: %w", s.Name, err)
+	}
+
+	return nil
+}
+
+func (c *Column) compile() error {
+
+	if !identifier.MatchString(c.Name) {
+		return fmt.Errorf("column name %q must be lower case letters, digits and underscores", c.Name)
+	}
+
+	if slices.Contains(reservedColumns, c.Name) {
+		return fmt.Errorf("column name %s is reserved", c.Name)
+	}
+
+	if !slices.Contains(types, c.Type) {
+		return fmt.Errorf("column %s: type %q must be one of %s", c.Name, c.Type, strings.Join(types, ", "))
+	}
+
+	if (c.Pattern != "" || len(c.Enum) > 0) && c.Type != TypeString {
+		return fmt.Errorf("column %s: pattern and enum are only supported for strings", c.Name)
+	}
+
+	if c.Pattern != "" {
+		// Patterns have to match the whole cell
+		pattern, err := regexp.Compile(`^(?:` + c.Pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("column %s: pattern: %w", c.Name, err)
+		}
+		c.pattern = pattern
+	}
+
+	return nil
+}
+
+// Parse validates a cell and converts it to the value stored for it: a
+// string, int64, float64, bool or time.Time. Empty cells are nil, and an
+// error for required columns.
+func (c *Column) Parse(cell string) (any, error) {
+
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		if c.Required {
+			return nil, errors.New("is required")
+		}
+		return nil, nil
+	}
+
+	switch c.Type {
+	case TypeInteger:
+		v, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return nil, errors.New("is not an integer")
+		}
+		return v, nil
+
+	case TypeNumber:
+		v, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return nil, errors.New("is not a number")
+		}
+		return v, nil
+
+	case TypeBoolean:
+		v, err := strconv.ParseBool(strings.ToLower(cell))
+		if err != nil {
+			return nil, errors.New("is not a boolean, use true or false")
+		}
+		return v, nil
+
+	case TypeDate:
+		v, err := model.ParseDueDate(cell)
+		if err != nil {
+			return nil, err
+		}
+		return *v, nil
+
+	case TypeEmail:
+		return model.ParseEmail(cell)
+	}
+
+	if len(c.Enum) > 0 && !slices.Contains(c.Enum, cell) {
+		return nil, fmt.Errorf("must be one of %s", strings.Join(c.Enum, ", "))
+	}
+
+	if c.pattern != nil && !c.pattern.MatchString(cell) {
+		return nil, fmt.Errorf("does not match %s", c.Pattern)
+	}
+
+	return cell, nil
+}
+
+// Normalize converts the values of record read back from the database to
+// the Go types Parse returns. Drivers hand out e.g. SQLite booleans as
+// integers and Postgres numerics as bytes.
+func (s *Schema) Normalize(record map[string]any) {
+
+	for _, c := range s.Columns {
+		if v, ok := record[c.Name]; ok {
+			record[c.Name] = c.normalize(v)
+		}
+	}
+}
+
+func (c *Column) normalize(v any) any {
+
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	switch c.Type {
+	case TypeBoolean:
+		if i, ok := v.(int64); ok {
+			return i != 0
+		}
+	case TypeInteger:
+		if s, ok := v.(string); ok {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i
+			}
+		}
+	case TypeNumber:
+		if s, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f
+			}
+		}
+	}
+
+	return v
+}
+
+// Format is the inverse of Parse for values read back from the database.
+func (c *Column) Format(v any) string {
+
+	switch v := c.normalize(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return model.FormatDueDate(&v)
+	}
+
+	return fmt.Sprint(v)
+}
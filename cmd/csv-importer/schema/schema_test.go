@@ -0,0 +1,228 @@
+package schema
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const inventory = `
+name: inventory
+table: inventory_items
+columns:
+  - name: sku
+    type: string
+    required: true
+    pattern: "[A-Z]{3}-[0-9]{4}"
+  - name: quantity
+    type: integer
+  - name: price
+    type: number
+  - name: unit
+    type: string
+    enum: [piece, box]
+`
+
+func TestBuiltin(t *testing.T) {
+	r, err := Builtin()
+	require.NoError(t, err)
+
+	var names []string
+	for _, s := range r.List() {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"attendees", "todos"}, names)
+
+	todos, ok := r.Get("todos")
+	require.True(t, ok)
+	assert.Equal(t, "todo_events", todos.Table)
+
+	_, ok = r.Get("inventory")
+	assert.False(t, ok)
+
+	var none *Registry
+	_, ok = none.Get("todos")
+	assert.False(t, ok)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inventory.yaml"), []byte(inventory), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vendors.json"), []byte(`{
+		"name": "vendors",
+		"table": "vendors",
+		"columns": [{"name": "vendor", "type": "string", "required": true}]
+	}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o600))
+
+	r, err := Load(dir)
+	require.NoError(t, err)
+	assert.Len(t, r.List(), 4)
+
+	vendors, ok := r.Get("vendors")
+	require.True(t, ok)
+	assert.True(t, vendors.Columns[0].Required)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "todos.yml"), []byte("name: todos\ntable: mine\ncolumns: [{name: a, type: string}]\n"), 0o600))
+	_, err = Load(dir)
+	assert.ErrorContains(t, err, "todos.yml: schema todos is already defined")
+	require.NoError(t, os.Remove(filepath.Join(dir, "todos.yml")))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stock.yaml"), []byte("name: stock\ntable: inventory_items\ncolumns: [{name: a, type: string}]\n"), 0o600))
+	_, err = Load(dir)
+	assert.ErrorContains(t, err, "table inventory_items is already used by schema")
+	require.NoError(t, os.Remove(filepath.Join(dir, "stock.yaml")))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "guests.yaml"), []byte("name: guests\ntable: attendees\ncolumns: [{name: a, type: string}]\n"), 0o600))
+	_, err = Load(dir)
+	assert.ErrorContains(t, err, "guests.yaml: table attendees is already used by schema attendees", "Built-in tables are taken too")
+	require.NoError(t, os.Remove(filepath.Join(dir, "guests.yaml")))
+
+	_, err = Load(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	testCases := []struct {
+		name       string
+		definition string
+		expected   string
+	}{
+		{"empty", "", "empty schema definition"},
+		{"unknown key", "name: a\ntable: a\nrequired: true\ncolumns: [{name: a, type: string}]", "field required not found"},
+		{"unsafe table", "name: a\ntable: a; drop table events\ncolumns: [{name: a, type: string}]", `table "a; drop table events"`},
+		{"core table", "name: a\ntable: todo_events\ncolumns: [{name: a, type: string}]", "schema a: table todo_events is reserved"},
+		{"migrations table", "name: a\ntable: schema_migrations\ncolumns: [{name: a, type: string}]", "table schema_migrations is reserved"},
+		{"no columns", "name: a\ntable: a", "no columns"},
+		{"unknown type", "name: a\ntable: a\ncolumns: [{name: a, type: money}]", `type "money" must be one of`},
+		{"reserved column", "name: a\ntable: a\ncolumns: [{name: event_id, type: string}]", "column name event_id is reserved"},
+		{"duplicate column", "name: a\ntable: a\ncolumns: [{name: a, type: string}, {name: a, type: integer}]", "column a is defined twice"},
+		{"pattern on integer", "name: a\ntable: a\ncolumns: [{name: a, type: integer, pattern: '[0-9]+'}]", "only supported for strings"},
+		{"bad pattern", "name: a\ntable: a\ncolumns: [{name: a, type: string, pattern: '[a-'}]", "column a: pattern"},
+		{"bad transform", "name: a\ntable: a\ncolumns: [{name: a, type: string}]\ntransforms: [{op: trim}]", "schema a: transform 1 (trim): column is required"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.definition))
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestColumn_Parse(t *testing.T) {
+	s, err := Parse([]byte(inventory))
+	require.NoError(t, err)
+
+	sku, quantity, price, unit := &s.Columns[0], &s.Columns[1], &s.Columns[2], &s.Columns[3]
+	boolean := &Column{Name: "active", Type: TypeBoolean}
+	date := &Column{Name: "since", Type: TypeDate}
+	email := &Column{Name: "contact", Type: TypeEmail}
+
+	testCases := []struct {
+		name     string
+		column   *Column
+		cell     string
+		expected any
+		err      string
+	}{
+		{"string", sku, " ABC-1234 ", "ABC-1234", ""},
+		{"required", sku, "  ", nil, "is required"},
+		{"pattern matches whole cell", sku, "ABC-12345", nil, "does not match [A-Z]{3}-[0-9]{4}"},
+		{"optional empty", quantity, "", nil, ""},
+		{"integer", quantity, "42", int64(42), ""},
+		{"not an integer", quantity, "4.2", nil, "is not an integer"},
+		{"number", price, "4.20", 4.2, ""},
+		{"not a number", price, "cheap", nil, "is not a number"},
+		{"enum", unit, "box", "box", ""},
+		{"enum is case sensitive", unit, "Box", nil, "must be one of piece, box"},
+		{"boolean", boolean, "TRUE", true, ""},
+		{"not a boolean", boolean, "yes", nil, "is not a boolean"},
+		{"date", date, "3 Mar 2025", time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), ""},
+		{"not a date", date, "someday", nil, "is not a date"},
+		{"email", email, "Ada@Example.com", "ada@example.com", ""},
+		{"not an email", email, "Ada <ada@example.com>", nil, "is not an email address"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := tc.column.Parse(tc.cell)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestSchema_Normalize(t *testing.T) {
+	s := &Schema{Columns: []Column{
+		{Name: "active", Type: TypeBoolean},
+		{Name: "quantity", Type: TypeInteger},
+		{Name: "price", Type: TypeNumber},
+		{Name: "note", Type: TypeString},
+	}}
+
+	record := map[string]any{"id": "row-1", "active": int64(1), "quantity": []byte("7"), "price": []byte("1.5"), "note": nil}
+	s.Normalize(record)
+
+	assert.Equal(t, map[string]any{"id": "row-1", "active": true, "quantity": int64(7), "price": 1.5, "note": nil}, record)
+	assert.Equal(t, "true", s.Columns[0].Format(int64(1)))
+	assert.Equal(t, "1.5", s.Columns[2].Format(1.5))
+	assert.Equal(t, "", s.Columns[3].Format(nil))
+}
+
+func TestRegistry_Ensure(t *testing.T) {
+	db, err := repository.Open(repository.DriverSQLite, ":memory:", &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	require.NoError(t, db.Exec("CREATE TABLE events (id varchar(100) PRIMARY KEY)").Error)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inventory.yaml"), []byte(inventory), 0o600))
+
+	r, err := Load(dir)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, r.Ensure(ctx, db))
+
+	migrator := db.Migrator()
+	for _, column := range []string{"id", "tenant_id", "event_id", "create_date", "sku", "quantity", "price", "unit"} {
+		assert.True(t, migrator.HasColumn("inventory_items", column), column)
+	}
+	assert.True(t, migrator.HasTable("attendees"))
+	assert.True(t, migrator.HasIndex("inventory_items", "inventory_items_tenant_event_idx"))
+	assert.False(t, migrator.HasTable("todos"), "The todos definition has a table of its own")
+
+	require.NoError(t, db.Exec("INSERT INTO events (id) VALUES ('event-1')").Error)
+	err = db.Exec("INSERT INTO inventory_items (id, tenant_id, event_id, create_date, quantity) VALUES ('row-1', 'tenant-a', 'event-1', CURRENT_TIMESTAMP, 7)").Error
+	assert.Error(t, err, "Required columns are NOT NULL")
+	require.NoError(t, db.Exec("INSERT INTO inventory_items (id, tenant_id, event_id, create_date, sku, quantity) VALUES ('row-1', 'tenant-a', 'event-1', CURRENT_TIMESTAMP, 'ABC-0001', 7)").Error)
+
+	// A column added to the definition is added to the table
+	s, _ := r.Get("inventory")
+	s.Columns = append(s.Columns, Column{Name: "location", Type: TypeString, Required: true})
+	require.NoError(t, r.Ensure(ctx, db))
+	assert.True(t, migrator.HasColumn("inventory_items", "location"))
+	require.NoError(t, r.Ensure(ctx, db), "Ensuring twice is harmless")
+
+	require.NoError(t, db.Exec("CREATE TABLE broken_items (id text, sku text)").Error)
+	s.Table = "broken_items"
+	assert.ErrorContains(t, r.Ensure(ctx, db), "schema inventory: table broken_items has no column tenant_id")
+}
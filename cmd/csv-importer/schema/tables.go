@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// columnTypes are the SQL types cells of every column type are stored as,
+// by database driver.
+var columnTypes = map[string]map[string]string{
+	repository.DriverPostgres: {
+		TypeString:  "text",
+		TypeInteger: "bigint",
+		TypeNumber:  "double precision",
+		TypeBoolean: "boolean",
+		TypeDate:    "timestamptz",
+		TypeEmail:   "varchar(254)",
+	},
+	repository.DriverSQLite: {
+		TypeString:  "text",
+		TypeInteger: "integer",
+		TypeNumber:  "real",
+		TypeBoolean: "boolean",
+		TypeDate:    "datetime",
+		TypeEmail:   "varchar(254)",
+	},
+}
+
+// Ensure creates the table of every declarative import type that has none,
+// and adds the columns a definition gained since, so that a new import type
+// only needs its definition. Columns are never changed or dropped; added
+// ones accept NULL, since earlier rows have no value for them.
+func (r *Registry) Ensure(ctx context.Context, db *gorm.DB) error {
+
+	dialect := db.Dialector.Name()
+	sqlTypes, ok := columnTypes[dialect]
+	if !ok {
+		return fmt.Errorf("import type tables are not supported on %s", dialect)
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+
+		migrator := tx.Migrator()
+
+		for _, s := range r.List() {
+			if s.Name == model.ImportTypeTodos {
+				continue
+			}
+
+			if !migrator.HasTable(s.Table) {
+				err := createTable(tx, s, sqlTypes)
+				if err != nil {
+					return fmt.Errorf("schema %s: creating table %s: %w", s.Name, s.Table, err)
+				}
+				continue
+			}
+
+			for _, column := range reservedColumns {
+				if !migrator.HasColumn(s.Table, column) {
+					return fmt.Errorf("schema %s: table %s has no column %s", s.Name, s.Table, column)
+				}
+			}
+
+			for _, c := range s.Columns {
+				if migrator.HasColumn(s.Table, c.Name) {
+					continue
+				}
+
+				err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s NULL", s.Table, c.Name, sqlTypes[c.Type])).Error
+				if err != nil {
+					return fmt.Errorf("schema %s: adding column %s to table %s: %w", s.Name, c.Name, s.Table, err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// createTable creates the table of s like the migrations create those of the
+// built-in types. Names were checked to be plain identifiers when s was
+// parsed.
+func createTable(tx *gorm.DB, s *Schema, sqlTypes map[string]string) error {
+
+	timestamp := sqlTypes[TypeDate]
+
+	lines := []string{
+		"id varchar(100) NOT NULL",
+		"tenant_id varchar(100) NOT NULL",
+		"event_id varchar(100) NOT NULL",
+	}
+	for _, c := range s.Columns {
+		null := "NULL"
+		if c.Required {
+			null = "NOT NULL"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", c.Name, sqlTypes[c.Type], null))
+	}
+	lines = append(lines,
+		"create_date "+timestamp+" NOT NULL",
+		fmt.Sprintf("CONSTRAINT %s_pk PRIMARY KEY (id)", s.Table),
+		fmt.Sprintf("CONSTRAINT %s_event_fk FOREIGN KEY (event_id) REFERENCES events (id)", s.Table),
+	)
+
+	err := tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", s.Table, strings.Join(lines, ",\n\t"))).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_tenant_event_idx ON %s (tenant_id, event_id)", s.Table, s.Table)).Error
+}
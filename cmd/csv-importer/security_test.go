@@ -1,12 +1,19 @@
 package main
 
 import (
+	"csv-importer-backend/cmd/csv-importer/apis"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
 	"csv-importer-backend/cmd/csv-importer/model"
+	"csv-importer-backend/cmd/csv-importer/ratelimit"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/gocarina/gocsv"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -233,21 +240,94 @@ func TestSecurity_InputSanitization(t *testing.T) {
 }
 
 func TestSecurity_RateLimiting(t *testing.T) {
-	// Test that simulates rate limiting for file uploads
+	// Uploads and reads have separate budgets, both kept per authenticated principal
 	maxUploadsPerMinute := 10
-	uploads := 0
-	
+
+	keys := auth.KeyStore{
+		"client-a": {Subject: "alice", TenantID: "tenant-a", Role: auth.RoleImporter},
+		"client-b": {Subject: "bob", TenantID: "tenant-a", Role: auth.RoleImporter},
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+	e.IPExtractor = EnvCfg{}.ipExtractor()
+	g := e.Group("/api/v1")
+	g.Use(ratelimit.FailedAuthLimit(ratelimit.NewLimiter(1, 3)))
+	g.Use(auth.Middleware(keys))
+	isRead := func(c echo.Context) bool { return !apis.IsImportRequest(c) }
+	g.Use(ratelimit.Middleware(ratelimit.NewLimiter(maxUploadsPerMinute, maxUploadsPerMinute), isRead))
+	g.Use(ratelimit.Middleware(ratelimit.NewLimiter(60, 100), apis.IsImportRequest))
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	g.POST("/event", ok)
+	g.GET("/events", ok)
+
+	send := func(method string, path string, apiKey string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.10:5555"
+		if apiKey != "" {
+			req.Header.Set(auth.APIKeyHeader, apiKey)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
 	for i := 0; i < 15; i++ {
-		uploads++
-		
-		if uploads <= maxUploadsPerMinute {
-			// Upload should be allowed
-			assert.LessOrEqual(t, uploads, maxUploadsPerMinute, "Upload %d should be within rate limit", i+1)
+		rec := send(http.MethodPost, "/api/v1/event", "client-a")
+
+		if i < maxUploadsPerMinute {
+			assert.Equal(t, http.StatusOK, rec.Code, "Upload %d should be within rate limit", i+1)
+			assert.Equal(t, fmt.Sprint(maxUploadsPerMinute-i-1), rec.Header().Get(ratelimit.HeaderRemaining))
 		} else {
-			// Upload should be rejected due to rate limiting
-			assert.Greater(t, uploads, maxUploadsPerMinute, "Upload %d should be rejected due to rate limiting", i+1)
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code, "Upload %d should be rejected due to rate limiting", i+1)
+			assert.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
 		}
 	}
+
+	// Reads are not charged against the upload budget
+	rec := send(http.MethodGet, "/api/v1/events", "client-a")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "100", rec.Header().Get(ratelimit.HeaderLimit))
+
+	// Headers the client picks itself do not reset its budget
+	for _, spoofed := range [][]string{
+		{echo.HeaderXForwardedFor, "203.0.113.7"},
+		{echo.HeaderXRealIP, "203.0.113.8"},
+		{auth.APIKeyHeader, "client-a", echo.HeaderAuthorization, "Bearer made-up"},
+	} {
+		rec = send(http.MethodPost, "/api/v1/event", "client-a", spoofed...)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, "Spoofed %v", spoofed)
+	}
+
+	// Other principals have budgets of their own
+	rec = send(http.MethodPost, "/api/v1/event", "client-b")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Unknown keys spend no principal's budget, but their address is throttled
+	for i := 0; i < 3; i++ {
+		rec = send(http.MethodPost, "/api/v1/event", fmt.Sprintf("guess-%d", i))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "Guess %d should be checked", i+1)
+	}
+	for _, spoofed := range [][]string{
+		nil,
+		{echo.HeaderXForwardedFor, "203.0.113.7"},
+		{echo.HeaderXRealIP, "203.0.113.8"},
+	} {
+		rec = send(http.MethodGet, "/api/v1/events", "guess-3", spoofed...)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, "Spoofed %v", spoofed)
+		assert.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil)
+	req.RemoteAddr = "198.51.100.20:5555"
+	req.Header.Set(auth.APIKeyHeader, "made-up")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "Other addresses are still answered")
 }
 
 func TestSecurity_FileNameValidation(t *testing.T) {
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apis"
+	"csv-importer-backend/cmd/csv-importer/apperr"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/lifecycle"
+	"csv-importer-backend/cmd/csv-importer/logging"
+	"csv-importer-backend/cmd/csv-importer/metrics"
+	"csv-importer-backend/cmd/csv-importer/ratelimit"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/requestid"
+	"csv-importer-backend/cmd/csv-importer/schema"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"gorm.io/gorm"
+)
+
+func runServe(ctx context.Context, cfg EnvCfg, db *gorm.DB, out io.Writer) error {
+
+	var settings []any
+	for _, line := range strings.Split(strings.TrimSpace(cfg.Summary()), "\n") {
+		name, value, _ := strings.Cut(line, "=")
+		settings = append(settings, slog.String(name, value))
+	}
+	slog.InfoContext(ctx, "configuration", settings...)
+
+	if cfg.MigrateOnStart {
+		err := runMigrate(ctx, db, nil, out)
+		if err != nil {
+			return err
+		}
+	}
+
+	e, drainer, err := newServer(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	return serve(ctx, cfg, e, drainer, db)
+}
+
+func newServer(cfg EnvCfg, db *gorm.DB) (*echo.Echo, *lifecycle.Drainer, error) {
+
+	apiKeys, err := auth.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(apiKeys) == 0 {
+		slog.Warn("CSV_IMPORTER_API_KEYS is empty, every /api/v1 request will be rejected")
+	}
+
+	e := echo.New()
+	// Startup is logged through slog instead
+	e.HideBanner = true
+	e.HidePort = true
+	e.IPExtractor = cfg.ipExtractor()
+
+	for _, s := range []*http.Server{e.Server, e.TLSServer} {
+		s.ReadTimeout = cfg.ReadTimeout
+		s.WriteTimeout = cfg.WriteTimeout
+		s.IdleTimeout = cfg.IdleTimeout
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schemas, err := schema.Load(cfg.SchemaDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Import types defined since the last start get their tables here
+	err = schemas.Ensure(context.Background(), db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eventRepo := repository.NewEventRepo(db)
+
+	sources, err := cfg.newBlobStore()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := metrics.New()
+	err = m.Register(
+		collectors.NewDBStatsCollector(sqlDB, db.Name()),
+		metrics.NewEventCollector(eventRepo),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Scrapes and probes are neither traced nor logged
+	isProbe := func(c echo.Context) bool {
+		switch c.Path() {
+		case "/metrics", "/livez", "/readyz":
+			return true
+		}
+		return false
+	}
+
+	e.HTTPErrorHandler = apperr.HTTPErrorHandler
+
+	// Outermost, because it restores the original request context on return
+	e.Use(otelecho.Middleware(tracing.ServiceName, otelecho.WithSkipper(isProbe)))
+	e.Use(m.Middleware(func(c echo.Context) bool { return c.Path() == "/metrics" }))
+	e.Use(requestid.Middleware())
+	e.Use(logging.Middleware(slog.Default(), isProbe))
+
+	// Requests that fail authentication have no principal to charge, so they
+	// are limited by client address ahead of the key check
+	var authenticate []echo.MiddlewareFunc
+	if cfg.RateLimitAuthFailuresPerMinute > 0 {
+		authenticate = append(authenticate, ratelimit.FailedAuthLimit(
+			ratelimit.NewLimiter(cfg.RateLimitAuthFailuresPerMinute, cfg.RateLimitAuthFailureBurst),
+		))
+	}
+	authenticate = append(authenticate, auth.Middleware(apiKeys))
+
+	// Metrics are not scoped to a tenant, so only admin keys may scrape them
+	e.GET("/metrics", echo.WrapHandler(m.Handler()),
+		append(authenticate, auth.Authorize(auth.DefaultPolicy, auth.ActionReadMetrics))...,
+	)
+
+	rootg := e.Group("")
+	v1g := rootg.Group("/api/v1")
+
+	isRead := func(c echo.Context) bool { return !apis.IsImportRequest(c) }
+
+	// Chunks of resumable uploads spend no upload budget of their own, but
+	// are drained like uploads, since the last one imports the file
+	isIdle := func(c echo.Context) bool { return isRead(c) && !apis.IsUploadChunk(c) }
+
+	drainer := lifecycle.New()
+	v1g.Use(lifecycle.Middleware(drainer, isIdle))
+
+	// Budgets are kept per principal, so the limits apply after the API key
+	// is checked
+	v1g.Use(authenticate...)
+
+	if cfg.RateLimitUploadsPerMinute > 0 {
+		v1g.Use(ratelimit.Middleware(
+			ratelimit.NewLimiter(cfg.RateLimitUploadsPerMinute, cfg.RateLimitUploadBurst),
+			isRead,
+		))
+	}
+
+	if cfg.RateLimitReadsPerMinute > 0 {
+		v1g.Use(ratelimit.Middleware(
+			ratelimit.NewLimiter(cfg.RateLimitReadsPerMinute, cfg.RateLimitReadBurst),
+			func(c echo.Context) bool { return apis.IsImportRequest(c) || apis.IsUploadChunk(c) },
+		))
+	}
+
+	// The chunk that completes an upload takes its slot only to import, not
+	// while it is received
+	var importSlots *ratelimit.Slots
+	if cfg.MaxConcurrentImports > 0 {
+		importSlots = ratelimit.NewSlots(cfg.MaxConcurrentImports)
+		v1g.Use(ratelimit.ConcurrencyLimit(importSlots, isRead))
+	}
+
+	apis.
+		NewHealthCheckAPI(db, drainer).
+		Setup(rootg)
+
+	// The contract is public, outside of the authenticated group
+	apis.
+		NewDocsAPI().
+		Setup(rootg.Group("/api/v1"))
+
+	eventAPI := apis.
+		NewEventAPI(eventRepo).
+		WithImportObserver(m).
+		WithSchemas(schemas, eventRepo).
+		WithSources(sources)
+	eventAPI.Setup(v1g)
+
+	apis.
+		NewUploadAPI(repository.NewUploadRepo(db), eventAPI).
+		WithMaxSize(cfg.UploadMaxSize).
+		WithBaseContext(drainer.Context()).
+		WithImportSlots(importSlots).
+		Setup(v1g)
+
+	apis.
+		NewSchemaAPI(schemas).
+		Setup(v1g)
+
+	apis.
+		NewInspectAPI().
+		Setup(v1g)
+
+	apis.
+		NewImportAPI(eventRepo).
+		WithSources(sources).
+		Setup(v1g)
+
+	auditRepo := repository.NewAuditRepo(db)
+
+	apis.
+		NewAuditAPI(auditRepo).
+		Setup(v1g)
+
+	return e, drainer, nil
+}
+
+// abortGracePeriod is how long aborted work may take to record its outcome.
+const abortGracePeriod = 5 * time.Second
+
+// serve runs e until ctx is cancelled. It then refuses new uploads, waits up
+// to the shutdown timeout for in-flight requests and background jobs, and
+// closes the database pool. Work still running at the deadline has its
+// context cancelled, which rolls back its import transaction, and is given
+// abortGracePeriod to record that it failed.
+func serve(ctx context.Context, cfg EnvCfg, e *echo.Echo, drainer *lifecycle.Drainer, db *gorm.DB) error {
+
+	reqCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+
+	for _, s := range []*http.Server{e.Server, e.TLSServer} {
+		s.BaseContext = func(net.Listener) context.Context {
+			return reqCtx
+		}
+	}
+
+	slog.InfoContext(ctx, "listening", "addr", cfg.ListenAddr, "tls", cfg.TLSCertFile != "")
+
+	started := make(chan error, 1)
+	go func() {
+		if cfg.TLSCertFile != "" {
+			started <- e.StartTLS(cfg.ListenAddr, cfg.TLSCertFile, cfg.TLSKeyFile)
+			return
+		}
+		started <- e.Start(cfg.ListenAddr)
+	}()
+
+	select {
+	case err := <-started:
+		return errors.Join(err, closeDB(db))
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err := drainer.Drain(shutdownCtx)
+	if err == nil {
+		err = e.Shutdown(shutdownCtx)
+	}
+
+	if err != nil {
+		slog.Warn("shutdown deadline reached, aborting in-flight imports", "in_flight", drainer.InFlight())
+		abort()
+		drainer.Abort()
+		e.Close()
+		err = fmt.Errorf("graceful shutdown: %w", err)
+
+		// Aborted imports still record that they failed before the pool closes
+		abortCtx, cancel := context.WithTimeout(context.Background(), abortGracePeriod)
+		defer cancel()
+		if drainer.Drain(abortCtx) != nil {
+			slog.Warn("aborted imports did not finish", "in_flight", drainer.InFlight())
+		}
+	}
+
+	startErr := <-started
+	if errors.Is(startErr, http.ErrServerClosed) {
+		startErr = nil
+	}
+
+	return errors.Join(err, startErr, closeDB(db))
+}
+
+func closeDB(db *gorm.DB) error {
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"csv-importer-backend/cmd/csv-importer/apis"
+	"csv-importer-backend/cmd/csv-importer/auth"
+	"csv-importer-backend/cmd/csv-importer/blobstore"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"csv-importer-backend/cmd/csv-importer/tracing"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestServe_Metrics(t *testing.T) {
+	s := startShutdownServer(t, 5*time.Second)
+
+	res := s.upload(t)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	metricsURL := strings.TrimSuffix(s.url, "/api/v1/event") + "/metrics"
+	res, err := http.Get(metricsURL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode, "Metrics need an admin API key")
+
+	req, err := http.NewRequest(http.MethodGet, metricsURL, nil)
+	require.NoError(t, err)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer shutdown-key")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), `csv_importer_http_requests_total{code="200",method="POST",route="/api/v1/event"} 1`)
+	assert.Contains(t, string(body), `csv_importer_imports_total{result="success"} 1`)
+	assert.Contains(t, string(body), `csv_importer_events{status="draft"} 1`)
+	assert.Contains(t, string(body), `go_sql_open_connections{db_name="sqlite"}`)
+	assert.NotContains(t, string(body), `route="/metrics"`, "Scrapes are not counted")
+}
+
+func TestServe_Traces(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	s := startShutdownServer(t, 5*time.Second)
+	require.NoError(t, s.db.Use(tracing.GormPlugin{}))
+
+	res := s.upload(t)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	health, err := http.Get(strings.TrimSuffix(s.url, "/api/v1/event") + "/readyz")
+	require.NoError(t, err)
+	health.Body.Close()
+
+	byID := make(map[trace.SpanID]sdktrace.ReadOnlySpan)
+	var server sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		byID[span.SpanContext().SpanID()] = span
+		if span.SpanKind() == trace.SpanKindServer {
+			require.Nil(t, server, "Probes are not traced")
+			server = span
+		}
+	}
+	require.NotNil(t, server)
+	assert.Contains(t, server.Attributes(), attribute.String("http.route", "/api/v1/event"))
+
+	// Every span of the upload belongs to the server span's trace
+	names := make(map[string]bool)
+	for _, span := range byID {
+		if span.SpanContext().TraceID() == server.SpanContext().TraceID() {
+			names[span.Name()] = true
+		}
+	}
+	for _, name := range []string{"upload.read", "import", "csv.parse", "import.validate", "import.store", "db.insert"} {
+		assert.True(t, names[name], "missing span %s in %v", name, names)
+	}
+}
+
+func TestServe_UploadChunksShareImportConcurrency(t *testing.T) {
+	db, err := repository.Open(repository.DriverSQLite, filepath.Join(t.TempDir(), "events.db"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { closeDB(db) })
+
+	var out bytes.Buffer
+	require.NoError(t, runMigrate(context.Background(), db, nil, &out))
+
+	e, drainer, err := newServer(EnvCfg{
+		APIKeys:              []string{"admin-key:tenant-a:alice:admin"},
+		MaxConcurrentImports: 1,
+		BlobStore:            blobstore.BackendFS,
+		BlobDir:              t.TempDir(),
+		UploadMaxSize:        1 << 20,
+	}, db)
+	require.NoError(t, err)
+
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		req.Header.Set(auth.APIKeyHeader, "admin-key")
+		req.Header.Set(apis.HeaderTusResumable, apis.TusVersion)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	csv := "todo_name,note\nBook venue,Downtown\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/uploads", nil)
+	req.Header.Set(apis.HeaderUploadLength, strconv.Itoa(len(csv)))
+	rec := do(req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	location := rec.Header().Get(echo.HeaderLocation)
+
+	chunk := func(offset int, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, location, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, apis.MIMEOffsetOctetStream)
+		req.Header.Set(apis.HeaderUploadOffset, strconv.Itoa(offset))
+		return do(req)
+	}
+
+	// An upload whose body never ends holds the only import slot
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	slow := httptest.NewRequest(http.MethodPost, "/api/v1/event", pr)
+	slow.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- do(slow) }()
+	require.Eventually(t, func() bool { return drainer.InFlight() == 1 }, 5*time.Second, time.Millisecond)
+
+	rec = chunk(0, csv[:10])
+	require.Equal(t, http.StatusNoContent, rec.Code, "Chunks that import nothing need no slot")
+
+	rec = chunk(10, csv[10:])
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "The last chunk imports, it needs a slot")
+
+	require.NoError(t, pw.CloseWithError(io.ErrUnexpectedEOF))
+	<-done
+
+	rec = chunk(10, csv[10:])
+	assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"context"
+	"csv-importer-backend/cmd/csv-importer/repository"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	result := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		result[kv.Key] = kv.Value
+	}
+	return result
+}
+
+func TestSetup_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, File: path, SampleRatio: 1})
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "csv.parse", attribute.Int("csv.rows", 3))
+	span.End()
+
+	require.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var exported struct {
+		Name string
+	}
+	require.NoError(t, json.NewDecoder(strings.NewReader(string(content))).Decode(&exported), string(content))
+	assert.Equal(t, "csv.parse", exported.Name)
+	assert.Contains(t, string(content), ServiceName)
+}
+
+func TestSetup_NoneAndUnknown(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.ErrorContains(t, err, `unsupported trace exporter "jaeger"`)
+}
+
+type todo struct {
+	ID   int
+	Note string
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := recordSpans(t)
+
+	db, err := repository.Open(repository.DriverSQLite, ":memory:", &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	require.NoError(t, db.Use(GormPlugin{}))
+	require.NoError(t, db.Exec("CREATE TABLE todos (id integer primary key, note text)").Error)
+
+	ctx, parent := Start(context.Background(), "import.store")
+
+	todos := []todo{{1, "secret one"}, {2, "secret two"}, {3, "secret three"}, {4, "secret four"}, {5, "secret five"}}
+	require.NoError(t, db.WithContext(ctx).CreateInBatches(todos, 2).Error)
+
+	var found todo
+	assert.ErrorIs(t, db.WithContext(ctx).First(&found, 42).Error, gorm.ErrRecordNotFound)
+	assert.Error(t, db.WithContext(ctx).Exec("INSERT INTO missing VALUES (?)", "secret").Error)
+
+	parent.End()
+
+	var inserts, selects, raws []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "db.insert":
+			inserts = append(inserts, span)
+		case "db.select":
+			selects = append(selects, span)
+		case "db.raw":
+			raws = append(raws, span)
+		}
+	}
+
+	require.Len(t, inserts, 3, "One span per batch")
+	for _, span := range inserts {
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Equal(t, "sqlite", attrs(span)["db.system"].AsString())
+		assert.Equal(t, "todos", attrs(span)["db.sql.table"].AsString())
+		assert.Contains(t, attrs(span)["db.query.text"].AsString(), "INSERT INTO")
+		assert.NotContains(t, attrs(span)["db.query.text"].AsString(), "secret")
+	}
+	assert.Equal(t, int64(2), attrs(inserts[0])["db.rows_affected"].AsInt64())
+
+	require.Len(t, selects, 1)
+	assert.Equal(t, codes.Unset, selects[0].Status().Code, "Missing records are not errors")
+
+	require.Len(t, raws, 2)
+	assert.Equal(t, codes.Error, raws[1].Status().Code)
+	assert.Contains(t, raws[1].Status().Description, "no such table")
+	assert.NotContains(t, attrs(raws[1])["db.query.text"].AsString(), "secret")
+}
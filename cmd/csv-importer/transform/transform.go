@@ -0,0 +1,392 @@
+// Package transform cleans up the cells of a CSV file before they are
+// validated: an ordered list of rules, each rewriting one or more columns of
+// every row.
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operations.
+const (
+	OpTrim               = "trim"
+	OpCollapseWhitespace = "collapse_whitespace"
+	OpTitleCase          = "title_case"
+	OpReplace            = "replace"
+	OpDefault            = "default"
+	OpSplit              = "split"
+	OpMerge              = "merge"
+	OpDateFormat         = "date_format"
+)
+
+var ops = []string{OpTrim, OpCollapseWhitespace, OpTitleCase, OpReplace, OpDefault, OpSplit, OpMerge, OpDateFormat}
+
+// AllColumns applies a cell-wise rule (trim, collapse_whitespace, title_case
+// and replace) to every column.
+const AllColumns = "*"
+
+// MaxRules caps the rules of one import.
+const MaxRules = 50
+
+const defaultDateFormat = "2006-01-02"
+
+// Rule is one step of a pipeline. Which fields apply depends on Op:
+//
+//	trim, collapse_whitespace, title_case  column
+//	replace      column, pattern, replacement ($1 expands to a group)
+//	default      column, value; the column is added when the file lacks it
+//	split        column, separator, into (two or more columns)
+//	merge        columns (two or more), into (one column), separator (" ")
+//	date_format  column, layout, format (2006-01-02)
+//
+// Columns named in into are added when the file lacks them.
+type Rule struct {
+	Op          string   `json:"op" yaml:"op"`
+	Column      string   `json:"column,omitempty" yaml:"column"`
+	Columns     []string `json:"columns,omitempty" yaml:"columns"`
+	Into        []string `json:"into,omitempty" yaml:"into"`
+	Pattern     string   `json:"pattern,omitempty" yaml:"pattern"`
+	Replacement string   `json:"replacement,omitempty" yaml:"replacement"`
+	Value       string   `json:"value,omitempty" yaml:"value"`
+	Separator   string   `json:"separator,omitempty" yaml:"separator"`
+	Layout      string   `json:"layout,omitempty" yaml:"layout"`
+	Format      string   `json:"format,omitempty" yaml:"format"`
+}
+
+// Applied reports what a rule did to a file.
+type Applied struct {
+	Rule
+	CellsChanged int `json:"cells_changed"`
+}
+
+// ColumnError reports a rule naming a column the file does not have. Rule
+// counts from 1.
+type ColumnError struct {
+	Rule   int
+	Op     string
+	Column string
+}
+
+func (e *ColumnError) Error() string {
+	return fmt.Sprintf("transform %d (%s): csvfile has no column %s", e.Rule, e.Op, e.Column)
+}
+
+// Pipeline is a checked list of rules.
+type Pipeline struct {
+	rules    []Rule
+	patterns []*regexp.Regexp
+}
+
+// Parse reads a list of rules. JSON is accepted as YAML; unknown keys are
+// rejected.
+func Parse(data []byte) ([]Rule, error) {
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var rules []Rule
+	err := decoder.Decode(&rules)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	_, err = Compile(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Compile checks rules and prepares their patterns.
+func Compile(rules []Rule) (*Pipeline, error) {
+
+	if len(rules) > MaxRules {
+		return nil, fmt.Errorf("more than %d transforms", MaxRules)
+	}
+
+	p := &Pipeline{
+		rules:    rules,
+		patterns: make([]*regexp.Regexp, len(rules)),
+	}
+
+	for n, r := range rules {
+		err := r.check()
+		if err != nil {
+			return nil, fmt.Errorf("transform %d (%s): %w", n+1, r.Op, err)
+		}
+
+		if r.Op == OpReplace {
+			p.patterns[n], err = regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("transform %d (%s): pattern: %w", n+1, r.Op, err)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+func (r *Rule) check() error {
+
+	if !slices.Contains(ops, r.Op) {
+		return fmt.Errorf("op must be one of %s", strings.Join(ops, ", "))
+	}
+
+	if r.Op == OpMerge {
+		if len(r.Columns) < 2 || len(r.Into) != 1 {
+			return errors.New("needs two or more columns and one into column")
+		}
+		return nil
+	}
+
+	if r.Column == "" {
+		return errors.New("column is required")
+	}
+
+	switch r.Op {
+	case OpTrim, OpCollapseWhitespace, OpTitleCase:
+	case OpReplace:
+		if r.Pattern == "" {
+			return errors.New("pattern is required")
+		}
+	case OpDefault:
+		if r.Value == "" {
+			return errors.New("value is required")
+		}
+	case OpSplit:
+		if r.Separator == "" || len(r.Into) < 2 {
+			return errors.New("needs a separator and two or more into columns")
+		}
+	case OpDateFormat:
+		if r.Layout == "" {
+			return errors.New("layout is required")
+		}
+	}
+
+	if r.Column == AllColumns && (r.Op == OpDefault || r.Op == OpSplit || r.Op == OpDateFormat) {
+		return fmt.Errorf("column %s is only supported by trim, collapse_whitespace, title_case and replace", AllColumns)
+	}
+
+	return nil
+}
+
+// Len returns the number of rules; a nil pipeline has none.
+func (p *Pipeline) Len() int {
+
+	if p == nil {
+		return 0
+	}
+
+	return len(p.rules)
+}
+
+// Apply runs every rule over the file, in order, and returns the header and
+// rows with the columns rules added. Rows are changed in place. Columns are
+// matched by their exact header.
+func (p *Pipeline) Apply(header []string, body [][]string) ([]string, [][]string, []Applied, error) {
+
+	if p.Len() == 0 {
+		return header, body, nil, nil
+	}
+
+	t := &table{header: header, body: body}
+	applied := make([]Applied, len(p.rules))
+
+	for n, r := range p.rules {
+		changed, err := p.apply(n, t)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		applied[n] = Applied{Rule: r, CellsChanged: changed}
+	}
+
+	return t.header, t.body, applied, nil
+}
+
+func (p *Pipeline) apply(n int, t *table) (int, error) {
+
+	r := &p.rules[n]
+	changed := 0
+	set := func(row []string, i int, v string) {
+		if row[i] != v {
+			row[i] = v
+			changed++
+		}
+	}
+
+	lookup := func(column string) (int, error) {
+		i := slices.Index(t.header, column)
+		if i < 0 {
+			return 0, &ColumnError{Rule: n + 1, Op: r.Op, Column: column}
+		}
+		return i, nil
+	}
+
+	switch r.Op {
+	case OpTrim, OpCollapseWhitespace, OpTitleCase, OpReplace:
+		columns := []int{}
+		if r.Column == AllColumns {
+			for i := range t.header {
+				columns = append(columns, i)
+			}
+		} else {
+			i, err := lookup(r.Column)
+			if err != nil {
+				return 0, err
+			}
+			columns = append(columns, i)
+		}
+
+		f := p.cellFunc(n)
+		for _, row := range t.body {
+			for _, i := range columns {
+				set(row, i, f(row[i]))
+			}
+		}
+
+	case OpDefault:
+		i := t.column(r.Column)
+		for _, row := range t.body {
+			if strings.TrimSpace(row[i]) == "" {
+				set(row, i, r.Value)
+			}
+		}
+
+	case OpDateFormat:
+		i, err := lookup(r.Column)
+		if err != nil {
+			return 0, err
+		}
+
+		format := r.Format
+		if format == "" {
+			format = defaultDateFormat
+		}
+
+		// Cells in another layout are left for validation to report
+		for _, row := range t.body {
+			if v, err := time.Parse(r.Layout, strings.TrimSpace(row[i])); err == nil {
+				set(row, i, v.Format(format))
+			}
+		}
+
+	case OpSplit:
+		i, err := lookup(r.Column)
+		if err != nil {
+			return 0, err
+		}
+
+		into := make([]int, len(r.Into))
+		for k, column := range r.Into {
+			into[k] = t.column(column)
+		}
+
+		for _, row := range t.body {
+			parts := strings.SplitN(row[i], r.Separator, len(into))
+			for k, column := range into {
+				v := ""
+				if k < len(parts) {
+					v = strings.TrimSpace(parts[k])
+				}
+				set(row, column, v)
+			}
+		}
+
+	case OpMerge:
+		from := make([]int, len(r.Columns))
+		for k, column := range r.Columns {
+			i, err := lookup(column)
+			if err != nil {
+				return 0, err
+			}
+			from[k] = i
+		}
+
+		separator := r.Separator
+		if separator == "" {
+			separator = " "
+		}
+
+		into := t.column(r.Into[0])
+		for _, row := range t.body {
+			var values []string
+			for _, column := range from {
+				if v := strings.TrimSpace(row[column]); v != "" {
+					values = append(values, v)
+				}
+			}
+			set(row, into, strings.Join(values, separator))
+		}
+	}
+
+	return changed, nil
+}
+
+// cellFunc returns the function rule n applies to every cell of its columns.
+func (p *Pipeline) cellFunc(n int) func(string) string {
+
+	switch p.rules[n].Op {
+	case OpCollapseWhitespace:
+		return func(v string) string { return strings.Join(strings.Fields(v), " ") }
+	case OpTitleCase:
+		return titleCase
+	case OpReplace:
+		pattern, replacement := p.patterns[n], p.rules[n].Replacement
+		return func(v string) string { return pattern.ReplaceAllString(v, replacement) }
+	}
+
+	return strings.TrimSpace
+}
+
+// titleCase upper cases the first letter of every word and lower cases the
+// rest. Words are separated by anything but letters, digits and apostrophes.
+func titleCase(v string) string {
+
+	var b strings.Builder
+	b.Grow(len(v))
+
+	start := true
+	for _, r := range v {
+		if start {
+			b.WriteRune(unicode.ToTitle(r))
+		} else {
+			b.WriteRune(unicode.ToLower(r))
+		}
+		start = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}
+
+	return b.String()
+}
+
+type table struct {
+	header []string
+	body   [][]string
+}
+
+// column returns the index of the named column, adding an empty one when
+// there is none.
+func (t *table) column(name string) int {
+
+	if i := slices.Index(t.header, name); i >= 0 {
+		return i
+	}
+
+	t.header = append(t.header, name)
+	for k := range t.body {
+		t.body[k] = append(t.body[k], "")
+	}
+
+	return len(t.header) - 1
+}
@@ -0,0 +1,159 @@
+package transform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func apply(t *testing.T, rules []Rule, header []string, body ...[]string) ([]string, [][]string, []Applied) {
+	t.Helper()
+
+	p, err := Compile(rules)
+	require.NoError(t, err)
+
+	header, body, applied, err := p.Apply(header, body)
+	require.NoError(t, err)
+
+	return header, body, applied
+}
+
+func TestPipeline_Apply(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     Rule
+		cells    []string
+		expected []string
+	}{
+		{"trim", Rule{Op: OpTrim, Column: "a"}, []string{"  x ", "y"}, []string{"x", "y"}},
+		{"collapse whitespace", Rule{Op: OpCollapseWhitespace, Column: "a"}, []string{" New \t  York  "}, []string{"New York"}},
+		{"title case", Rule{Op: OpTitleCase, Column: "a"}, []string{"ada LOVELACE", "o'neil-smith", "ärzte 2go"}, []string{"Ada Lovelace", "O'neil-Smith", "Ärzte 2go"}},
+		{"replace", Rule{Op: OpReplace, Column: "a", Pattern: `^\+?(\d{2})(\d+)$`, Replacement: "+$1 $2"}, []string{"4930123", "n/a"}, []string{"+49 30123", "n/a"}},
+		{"default", Rule{Op: OpDefault, Column: "a", Value: "standard"}, []string{"", " ", "vip"}, []string{"standard", "standard", "vip"}},
+		{"date format", Rule{Op: OpDateFormat, Column: "a", Layout: "2/1/2006"}, []string{"13/4/2025", " 1/12/2025 ", "someday"}, []string{"2025-04-13", "2025-12-01", "someday"}},
+		{"date format with format", Rule{Op: OpDateFormat, Column: "a", Layout: "2006-01-02", Format: "2 Jan 2006"}, []string{"2025-03-03"}, []string{"3 Mar 2025"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := make([][]string, len(tc.cells))
+			for n, cell := range tc.cells {
+				body[n] = []string{cell, "untouched "}
+			}
+
+			header, body, applied := apply(t, []Rule{tc.rule}, []string{"a", "b"}, body...)
+			assert.Equal(t, []string{"a", "b"}, header)
+
+			var cells []string
+			changed := 0
+			for n, row := range body {
+				cells = append(cells, row[0])
+				assert.Equal(t, "untouched ", row[1])
+				if row[0] != tc.cells[n] {
+					changed++
+				}
+			}
+			assert.Equal(t, tc.expected, cells)
+			assert.Equal(t, []Applied{{Rule: tc.rule, CellsChanged: changed}}, applied)
+		})
+	}
+}
+
+func TestPipeline_Apply_AllColumns(t *testing.T) {
+	_, body, applied := apply(t, []Rule{{Op: OpTrim, Column: AllColumns}}, []string{"a", "b"}, []string{" x", "y "}, []string{"z", ""})
+
+	assert.Equal(t, [][]string{{"x", "y"}, {"z", ""}}, body)
+	assert.Equal(t, 2, applied[0].CellsChanged)
+}
+
+func TestPipeline_Apply_SplitAndMerge(t *testing.T) {
+	rules := []Rule{
+		{Op: OpSplit, Column: "name", Separator: ",", Into: []string{"last_name", "first_name"}},
+		{Op: OpMerge, Columns: []string{"first_name", "last_name"}, Into: []string{"display_name"}},
+		{Op: OpDefault, Column: "ticket_type", Value: "standard"},
+	}
+
+	header, body, applied := apply(t, rules, []string{"name", "first_name"},
+		[]string{"Lovelace, Ada", "old"},
+		[]string{"Hopper", ""},
+	)
+
+	assert.Equal(t, []string{"name", "first_name", "last_name", "display_name", "ticket_type"}, header)
+	assert.Equal(t, [][]string{
+		{"Lovelace, Ada", "Ada", "Lovelace", "Ada Lovelace", "standard"},
+		{"Hopper", "", "Hopper", "Hopper", "standard"},
+	}, body)
+	assert.Equal(t, []int{3, 2, 2}, []int{applied[0].CellsChanged, applied[1].CellsChanged, applied[2].CellsChanged})
+}
+
+func TestPipeline_Apply_MissingColumn(t *testing.T) {
+	p, err := Compile([]Rule{{Op: OpTrim, Column: "a"}, {Op: OpTitleCase, Column: "nmae"}})
+	require.NoError(t, err)
+
+	_, _, _, err = p.Apply([]string{"a", "name"}, [][]string{{"x", "y"}})
+
+	var columnErr *ColumnError
+	require.ErrorAs(t, err, &columnErr)
+	assert.Equal(t, ColumnError{Rule: 2, Op: OpTitleCase, Column: "nmae"}, *columnErr)
+	assert.EqualError(t, err, "transform 2 (title_case): csvfile has no column nmae")
+}
+
+func TestPipeline_Apply_None(t *testing.T) {
+	var p *Pipeline
+	header, body, applied, err := p.Apply([]string{"a"}, [][]string{{" x "}})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, header)
+	assert.Equal(t, [][]string{{" x "}}, body)
+	assert.Nil(t, applied)
+}
+
+func TestParse(t *testing.T) {
+	rules, err := Parse([]byte(`[{"op": "trim", "column": "*"}, {"op": "default", "column": "ticket_type", "value": "standard"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{{Op: OpTrim, Column: "*"}, {Op: OpDefault, Column: "ticket_type", Value: "standard"}}, rules)
+
+	rules, err = Parse([]byte("- op: title_case\n  column: name\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{{Op: OpTitleCase, Column: "name"}}, rules)
+
+	rules, err = Parse(nil)
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tooMany := "["
+	for n := 0; n <= MaxRules; n++ {
+		tooMany += fmt.Sprintf(`{"op": "trim", "column": "c%d"},`, n)
+	}
+	tooMany += "]"
+
+	testCases := []struct {
+		name     string
+		rules    string
+		expected string
+	}{
+		{"not a list", `{"op": "trim"}`, "cannot unmarshal"},
+		{"unknown key", `[{"op": "trim", "colum": "a"}]`, "field colum not found"},
+		{"unknown op", `[{"op": "upper", "column": "a"}]`, "transform 1 (upper): op must be one of"},
+		{"no column", `[{"op": "trim"}]`, "column is required"},
+		{"no pattern", `[{"op": "replace", "column": "a"}]`, "pattern is required"},
+		{"bad pattern", `[{"op": "replace", "column": "a", "pattern": "(a"}]`, "transform 1 (replace): pattern:"},
+		{"no value", `[{"op": "default", "column": "a"}]`, "value is required"},
+		{"split into one", `[{"op": "split", "column": "a", "separator": " ", "into": ["b"]}]`, "two or more into columns"},
+		{"merge one", `[{"op": "merge", "columns": ["a"], "into": ["b"]}]`, "two or more columns and one into column"},
+		{"no layout", `[{"op": "date_format", "column": "a"}]`, "layout is required"},
+		{"default everywhere", `[{"op": "trim", "column": "a"}, {"op": "default", "column": "*", "value": "x"}]`, "transform 2 (default): column * is only supported"},
+		{"too many", tooMany, "more than 50 transforms"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.rules))
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}